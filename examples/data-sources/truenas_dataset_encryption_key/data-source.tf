@@ -0,0 +1,10 @@
+resource "truenas_dataset" "secret" {
+  pool         = "Tank"
+  name         = "secret"
+  encrypted    = true
+  generate_key = true
+}
+
+data "truenas_dataset_encryption_key" "secret" {
+  dataset_id = truenas_dataset.secret.id
+}
\ No newline at end of file
@@ -0,0 +1,4 @@
+data "truenas_periodic_snapshot_task" "hourly" {
+  dataset       = "Tank/data"
+  naming_schema = "hourly-%Y-%m-%d_%H-%M"
+}
\ No newline at end of file
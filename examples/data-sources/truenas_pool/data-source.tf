@@ -0,0 +1,3 @@
+data "truenas_pool" "tank" {
+  name = "Tank"
+}
\ No newline at end of file
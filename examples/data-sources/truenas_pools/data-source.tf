@@ -0,0 +1,9 @@
+data "truenas_pools" "all" {}
+
+locals {
+  # name of the pool with most free space
+  roomiest_pool = [
+    for p in data.truenas_pools.all.pools : p.name
+    if p.free == max(data.truenas_pools.all.pools[*].free...)
+  ][0]
+}
\ No newline at end of file
@@ -0,0 +1,5 @@
+data "truenas_system_info" "system" {}
+
+output "truenas_release" {
+  value = data.truenas_system_info.system.release
+}
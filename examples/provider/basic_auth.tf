@@ -0,0 +1,8 @@
+provider "truenas" {
+  base_url = "https://<your.truenas.hostname>/api/v2.0"
+  username = "root"
+  password = "<your truenas password>"
+
+  # authenticate once and use short-lived token for the rest of the run
+  generate_token = true
+}
@@ -0,0 +1,5 @@
+provider "truenas" {
+  api_key   = "<your truenas api key>"
+  base_url  = "https://<your.truenas.hostname>/api/v2.0"
+  transport = "websocket"
+}
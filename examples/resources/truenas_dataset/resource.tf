@@ -19,6 +19,19 @@ resource "truenas_dataset" "test" {
   readonly = "off"
   record_size = "256K"
   case_sensitivity = "mixed"
+  acl_type = "posix"
+  xattr = "sa"
+  dnode_size = "auto"
+  special_small_block_size = "64K"
+  log_bias = "inherit"
+  primary_cache = "all"
+  secondary_cache = "metadata"
+  checksum = "inherit"
+
+  user_properties = {
+    "org:owner"       = "storage-team"
+    "org:backup-tier" = "gold"
+  }
 
   inherit_encryption = false
   encrypted = true
@@ -26,4 +39,24 @@ resource "truenas_dataset" "test" {
   generate_key = false
   encryption_algorithm = "AES-128-CCM"
   encryption_key = "3e10193aa02f4167edc46c9f4b8ba723eed474deede646fded99628de1878d51"
-}
\ No newline at end of file
+}
+
+# Passphrase encrypted dataset, changing passphrase changes the key in place
+# and locked declares whether the dataset should be locked
+resource "truenas_dataset" "secret" {
+  pool = "<dataset pool>"
+  name = "secret"
+
+  encrypted  = true
+  passphrase = var.secret_passphrase
+  locked     = false
+}
+
+# Copy-on-write clone of production snapshot, origin snapshot is left in place when clone is destroyed
+resource "truenas_dataset" "staging" {
+  pool = "<dataset pool>"
+  name = "staging"
+
+  clone_from_snapshot = truenas_snapshot.prod.snapshot_id
+  comments            = "Staging copy of production data"
+}
@@ -0,0 +1,4 @@
+terraform import truenas_dataset_permissions.default {{dataset_id}}
+
+# Example:
+terraform import truenas_dataset_permissions.default "Tank/share"
\ No newline at end of file
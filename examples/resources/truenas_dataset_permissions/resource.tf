@@ -0,0 +1,46 @@
+resource "truenas_dataset" "apps" {
+  pool = "Tank"
+  name = "apps"
+}
+
+# Owner and POSIX mode
+resource "truenas_dataset_permissions" "apps" {
+  dataset_id = truenas_dataset.apps.id
+  user       = "apps"
+  group      = "apps"
+  mode       = "0750"
+  recursive  = true
+}
+
+resource "truenas_dataset" "share" {
+  pool       = "Tank"
+  name       = "share"
+  share_type = "smb"
+}
+
+# NFS4 ACL on SMB dataset
+resource "truenas_dataset_permissions" "share" {
+  dataset_id = truenas_dataset.share.id
+  group      = "apps"
+
+  acl {
+    tag   = "owner@"
+    type  = "ALLOW"
+    perms = ["FULL_CONTROL"]
+    flags = ["INHERIT"]
+  }
+
+  acl {
+    tag   = "GROUP"
+    id    = 568
+    type  = "ALLOW"
+    perms = ["MODIFY"]
+    flags = ["INHERIT"]
+  }
+
+  acl {
+    tag   = "everyone@"
+    type  = "ALLOW"
+    perms = ["READ_DATA", "READ_ATTRIBUTES", "READ_ACL", "EXECUTE"]
+  }
+}
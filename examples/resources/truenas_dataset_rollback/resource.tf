@@ -0,0 +1,10 @@
+# Reset test dataset to a known snapshot, changing triggers rolls it back again
+resource "truenas_dataset_rollback" "reset" {
+  dataset_id    = "Tank/test"
+  snapshot_name = "clean"
+  recursive     = true
+
+  triggers = {
+    run = var.test_run_id
+  }
+}
@@ -0,0 +1,4 @@
+terraform import truenas_dataset_user_quota.default {{dataset_id}}:{{quota_type}}:{{name}}
+
+# Example:
+terraform import truenas_dataset_user_quota.default "Tank/home:USER:alice"
\ No newline at end of file
@@ -0,0 +1,20 @@
+resource "truenas_dataset" "home" {
+  pool = "Tank"
+  name = "home"
+}
+
+# Limit space used by alice to 50 GiB
+resource "truenas_dataset_user_quota" "alice" {
+  dataset_id  = truenas_dataset.home.id
+  quota_type  = "USER"
+  name        = "alice"
+  quota_value = 53687091200
+}
+
+# Limit number of files owned by staff group
+resource "truenas_dataset_user_quota" "staff_objects" {
+  dataset_id  = truenas_dataset.home.id
+  quota_type  = "GROUPOBJ"
+  name        = "staff"
+  quota_value = 1000000
+}
@@ -0,0 +1,4 @@
+terraform import truenas_periodic_snapshot_task.default {{task_id}}
+
+# Example:
+terraform import truenas_periodic_snapshot_task.default "3"
\ No newline at end of file
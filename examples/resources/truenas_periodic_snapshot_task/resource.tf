@@ -0,0 +1,19 @@
+resource "truenas_dataset" "data" {
+  pool = "Tank"
+  name = "data"
+}
+
+resource "truenas_periodic_snapshot_task" "hourly" {
+  dataset        = truenas_dataset.data.id
+  recursive      = true
+  exclude        = ["Tank/data/scratch"]
+  lifetime_value = 2
+  lifetime_unit  = "DAY"
+  naming_schema  = "hourly-%Y-%m-%d_%H-%M"
+
+  schedule {
+    minute = "0"
+    begin  = "08:00"
+    end    = "20:00"
+  }
+}
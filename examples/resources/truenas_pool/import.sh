@@ -0,0 +1,4 @@
+terraform import truenas_pool.default {{pool_id}}
+
+# Example:
+terraform import truenas_pool.default "1"
\ No newline at end of file
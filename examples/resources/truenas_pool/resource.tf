@@ -0,0 +1,29 @@
+resource "truenas_pool" "tank" {
+  name     = "Tank"
+  checksum = "sha256"
+
+  topology {
+    data {
+      type  = "RAIDZ2"
+      disks = ["sda", "sdb", "sdc", "sdd"]
+    }
+
+    log {
+      type  = "MIRROR"
+      disks = ["nvme0n1", "nvme1n1"]
+    }
+
+    cache {
+      type  = "STRIPE"
+      disks = ["nvme2n1"]
+    }
+
+    spares = ["sde"]
+  }
+
+  encrypted            = true
+  encryption_algorithm = "AES-256-GCM"
+
+  # pool is only destroyed when this is set, use delete_action = "EXPORT" to disconnect pool instead
+  allow_destroy = false
+}
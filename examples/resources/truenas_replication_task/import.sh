@@ -0,0 +1,4 @@
+terraform import truenas_replication_task.default {{task_id}}
+
+# Example:
+terraform import truenas_replication_task.default "1"
\ No newline at end of file
@@ -0,0 +1,28 @@
+resource "truenas_periodic_snapshot_task" "hourly" {
+  dataset        = "Tank/data"
+  recursive      = true
+  lifetime_value = 2
+  lifetime_unit  = "DAY"
+  naming_schema  = "hourly-%Y-%m-%d_%H-%M"
+
+  schedule {
+    minute = "0"
+  }
+}
+
+resource "truenas_replication_task" "offsite" {
+  name                    = "data-offsite"
+  direction               = "PUSH"
+  transport               = "SSH"
+  ssh_credentials         = 1
+  source_datasets         = ["Tank/data"]
+  target_dataset          = "Backup/data"
+  recursive               = true
+  periodic_snapshot_tasks = [truenas_periodic_snapshot_task.hourly.task_id]
+  retention_policy        = "CUSTOM"
+  lifetime_value          = 2
+  lifetime_unit           = "WEEK"
+  compression             = "LZ4"
+  speed_limit             = 52428800
+  run_on_create           = true
+}
@@ -0,0 +1,4 @@
+terraform import truenas_snapshot.default {{snapshot_id}}
+
+# Example:
+terraform import truenas_snapshot.default "Tank/data@pre-upgrade"
\ No newline at end of file
@@ -0,0 +1,10 @@
+resource "truenas_dataset" "data" {
+  pool = "Tank"
+  name = "data"
+}
+
+resource "truenas_snapshot" "checkpoint" {
+  dataset   = truenas_dataset.data.id
+  name      = "pre-upgrade"
+  recursive = true
+}
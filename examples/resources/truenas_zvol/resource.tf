@@ -4,4 +4,22 @@ resource "truenas_zvol" "zv" {
   volsize = 1024 * 1024 * 1024 // 1GiB
   comments = "Test comment"
   compression = "lz4"
+  vol_mode = "dev"
+  snap_dev = "hidden"
+  log_bias = "throughput"
+  primary_cache = "metadata"
+
+  user_properties = {
+    "org:owner" = "virtualization"
+  }
+}
+
+
+resource "truenas_zvol" "clone" {
+  pool = "Tank"
+  name = "TestZVOLClone"
+  volsize = 1024 * 1024 * 1024 // 1GiB
+  compression = "lz4"
+  clone_from_snapshot = "Tank/TestZVOL@base"
+  promote = true
 }
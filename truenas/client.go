@@ -0,0 +1,197 @@
+package truenas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	api "github.com/dariusbakunas/truenas-go-sdk"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client is passed to resources and data sources as provider meta, it wraps
+// generated TrueNAS API client and holds state shared by all resources
+type Client struct {
+	*api.APIClient
+
+	locks *mutexKV
+
+	// caller is used for middleware methods not covered by truenas-go-sdk,
+	// it uses either REST or websocket transport
+	caller apiCaller
+
+	// jobPollInterval controls how often middleware jobs are polled
+	jobPollInterval time.Duration
+
+	// system is detected when provider is configured, nil if detection failed
+	system *systemInfo
+
+	capMu      sync.Mutex
+	smbPresets []string
+}
+
+func newClient(c *api.APIClient) *Client {
+	client := &Client{
+		APIClient:       c,
+		locks:           newMutexKV(),
+		jobPollInterval: 2 * time.Second,
+	}
+
+	client.caller = &restCaller{client: client}
+
+	return client
+}
+
+// call performs API request to endpoint that is not covered by truenas-go-sdk,
+// body is JSON encoded and response is decoded into out, unless out is nil.
+// Error responses are returned as *apiError
+func (c *Client) call(ctx context.Context, method string, path string, body interface{}, out interface{}) (*http.Response, error) {
+	cfg := c.GetConfig()
+
+	if len(cfg.Servers) == 0 {
+		return nil, fmt.Errorf("API base URL is not configured")
+	}
+
+	url := strings.TrimSuffix(cfg.Servers[0].URL, "/") + "/" + strings.TrimPrefix(path, "/")
+
+	var reqBody io.Reader
+
+	if body != nil {
+		data, err := json.Marshal(body)
+
+		if err != nil {
+			return nil, fmt.Errorf("error encoding request body: %s", err)
+		}
+
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for k, v := range cfg.DefaultHeader {
+		req.Header.Set(k, v)
+	}
+
+	if cfg.UserAgent != "" {
+		req.Header.Set("User-Agent", cfg.UserAgent)
+	}
+
+	httpClient := cfg.HTTPClient
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	if cfg.Debug {
+		log.Printf("[DEBUG] TrueNAS API request: %s %s", method, url)
+	}
+
+	resp, err := httpClient.Do(req)
+
+	if err != nil {
+		return resp, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	if err != nil {
+		return resp, err
+	}
+
+	if cfg.Debug {
+		log.Printf("[DEBUG] TrueNAS API response: %s %s", resp.Status, respBody)
+	}
+
+	if resp.StatusCode >= 300 {
+		return resp, decodeAPIErrorBody(resp.Status, respBody)
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return resp, fmt.Errorf("error decoding response body: %s", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// lock serializes operations on given middleware endpoint (eg. sharing/smb),
+// use it for operations that middleware can not run concurrently anyway
+func (c *Client) lock(key string) {
+	c.locks.Lock(key)
+}
+
+func (c *Client) unlock(key string) {
+	c.locks.Unlock(key)
+}
+
+// generateAuthToken logs in with client credentials and generates authentication token,
+// token stays valid for ttl seconds after it was last used
+func (c *Client) generateAuthToken(ctx context.Context, ttl int) (string, error) {
+	var token string
+
+	err := c.invoke(ctx, "auth.generate_token", []interface{}{ttl}, &token)
+
+	if err != nil {
+		return "", err
+	}
+
+	if token == "" {
+		return "", fmt.Errorf("empty token returned")
+	}
+
+	return token, nil
+}
+
+// mutexKV is a simple key/value store for arbitrary mutexes
+type mutexKV struct {
+	lock  sync.Mutex
+	store map[string]*sync.Mutex
+}
+
+func newMutexKV() *mutexKV {
+	return &mutexKV{
+		store: make(map[string]*sync.Mutex),
+	}
+}
+
+// Lock locks the mutex for given key, creating it if necessary
+func (m *mutexKV) Lock(key string) {
+	m.get(key).Lock()
+}
+
+// Unlock unlocks the mutex for given key
+func (m *mutexKV) Unlock(key string) {
+	m.get(key).Unlock()
+}
+
+func (m *mutexKV) get(key string) *sync.Mutex {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	mutex, ok := m.store[key]
+
+	if !ok {
+		mutex = &sync.Mutex{}
+		m.store[key] = mutex
+	}
+
+	return mutex
+}
@@ -0,0 +1,36 @@
+package truenas
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestMutexKV(t *testing.T) {
+	m := newMutexKV()
+	counter := 0
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			m.Lock("sharing/smb")
+			defer m.Unlock("sharing/smb")
+
+			counter++
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 50, counter)
+
+	// different keys must not block each other
+	m.Lock("sharing/smb")
+	m.Lock("sharing/nfs")
+	m.Unlock("sharing/nfs")
+	m.Unlock("sharing/smb")
+}
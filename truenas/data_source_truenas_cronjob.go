@@ -84,21 +84,17 @@ func dataSourceTrueNASCronjob() *schema.Resource {
 func dataSourceTrueNASCronjobRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id, err := strconv.Atoi(d.Get("cronjob_id").(string))
 
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	resp, _, err := c.CronjobApi.GetCronJob(ctx, int32(id)).Execute()
+	var resp api.CronJob
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error getting cronjob: %s\n%s", err, body)
+	if err := c.invoke(ctx, "cronjob.get_instance", []interface{}{id}, &resp); err != nil {
+		return apiErrorDiags(err, "error getting cronjob", nil)
 	}
 
 	if resp.User != nil {
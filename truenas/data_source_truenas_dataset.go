@@ -185,6 +185,14 @@ func dataSourceTrueNASDataset() *schema.Resource {
 				Type:        schema.TypeString,
 				Computed:    true,
 			},
+			"user_properties": &schema.Schema{
+				Description: "ZFS user properties, eg. `org:owner`, including inherited ones",
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 			"xattr": &schema.Schema{
 				Type:     schema.TypeString,
 				Computed: true,
@@ -453,23 +461,21 @@ func updateDatasetResourceFromResponse(resp *api.Dataset, d *schema.ResourceData
 		d.Set("encrypted", *resp.Encrypted)
 	}
 
+	d.Set("user_properties", datasetUserProperties(resp, false))
+
 	return diags
 }
 
 func dataSourceTrueNASDatasetRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id := d.Get("dataset_id").(string)
 
-	resp, _, err := c.DatasetApi.GetDataset(ctx, id).Execute()
+	var resp api.Dataset
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error getting dataset: %s\n%s", err, body)
+	if err := c.invoke(ctx, "pool.dataset.get_instance", []interface{}{id}, &resp); err != nil {
+		return apiErrorDiags(err, "error getting dataset", nil)
 	}
 
 	if resp.Type != "FILESYSTEM" {
@@ -486,7 +492,7 @@ func dataSourceTrueNASDatasetRead(ctx context.Context, d *schema.ResourceData, m
 
 	d.Set("name", dpath.Name)
 
-	diags = append(diags, updateDatasetResourceFromResponse(resp, d)...)
+	diags = append(diags, updateDatasetResourceFromResponse(&resp, d)...)
 
 	d.SetId(resp.Id)
 
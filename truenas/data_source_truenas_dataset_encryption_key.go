@@ -0,0 +1,48 @@
+package truenas
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"log"
+)
+
+func dataSourceTrueNASDatasetEncryptionKey() *schema.Resource {
+	return &schema.Resource{
+		Description: "Export encryption key of key encrypted dataset, eg. to back up key generated with `generate_key`. " +
+			"Key is stored in Terraform state, so state must be protected accordingly",
+		ReadContext: dataSourceTrueNASDatasetEncryptionKeyRead,
+		Schema: map[string]*schema.Schema{
+			"dataset_id": &schema.Schema{
+				Description: "Encryption root dataset ID, eg. Tank/secret",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"key": &schema.Schema{
+				Description: "Hex encoded encryption key",
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+			},
+		},
+	}
+}
+
+func dataSourceTrueNASDatasetEncryptionKeyRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	id := d.Get("dataset_id").(string)
+
+	log.Printf("[DEBUG] Exporting TrueNAS dataset (%s) key", id)
+
+	var key string
+
+	// download = false returns key as job result
+	if err := c.invokeJob(ctx, "pool.dataset.export_key", []interface{}{id, false}, &key); err != nil {
+		return apiErrorDiags(err, "error exporting dataset key", nil)
+	}
+
+	d.Set("key", key)
+	d.SetId(id)
+
+	return nil
+}
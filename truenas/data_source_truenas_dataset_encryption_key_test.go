@@ -0,0 +1,68 @@
+package truenas
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"regexp"
+	"testing"
+)
+
+func TestAccDataSourceTruenasDatasetEncryptionKey_basic(t *testing.T) {
+	suffix := acctest.RandStringFromCharSet(5, acctest.CharSetAlphaNum)
+	name := fmt.Sprintf("%s-%s", testResourcePrefix, suffix)
+	resourceName := "data.truenas_dataset_encryption_key.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckDataSourceTruenasDatasetEncryptionKeyConfig(testPoolName, name, `generate_key = true`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", fmt.Sprintf("%s/%s", testPoolName, name)),
+					resource.TestMatchResourceAttr(resourceName, "key", regexp.MustCompile(`^[0-9a-f]{64}$`)),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitDataSourceTruenasDatasetEncryptionKey_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "data.truenas_dataset_encryption_key.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckDataSourceTruenasDatasetEncryptionKeyConfig(fakePoolName, "secret", `generate_key = true`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/secret"),
+					resource.TestCheckResourceAttr(resourceName, "key", fakeGeneratedKey),
+				),
+			},
+			{
+				Config:      f.providerConfig() + testAccCheckDataSourceTruenasDatasetEncryptionKeyConfig(fakePoolName, "secret", `passphrase = "passphrase"`),
+				ExpectError: regexp.MustCompile("Only datasets which are encrypted with key can be exported"),
+			},
+		},
+	})
+}
+
+func testAccCheckDataSourceTruenasDatasetEncryptionKeyConfig(pool string, name string, key string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "test" {
+		name = "%s"
+		pool = "%s"
+		encrypted = true
+		%s
+	}
+
+	data "truenas_dataset_encryption_key" "test" {
+		dataset_id = truenas_dataset.test.id
+	}
+	`, name, pool, key)
+}
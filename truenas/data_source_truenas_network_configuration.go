@@ -95,16 +95,12 @@ func dataSourceTrueNASNetworkConfiguration() *schema.Resource {
 func dataSourceTrueNASNetworkConfigurationRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 
 	config, _, err := c.NetworkApi.GetNetworkConfiguration(ctx).Execute()
 
 	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error getting network configuration: %s\n%s", err, body)
+		return apiErrorDiags(err, "error getting network configuration", nil)
 	}
 
 	if config.Hostname != nil {
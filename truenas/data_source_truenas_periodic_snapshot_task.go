@@ -0,0 +1,136 @@
+package truenas
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"strconv"
+)
+
+func dataSourceTrueNASPeriodicSnapshotTask() *schema.Resource {
+	return &schema.Resource{
+		Description: "Get information about periodic snapshot task of a dataset",
+		ReadContext: dataSourceTrueNASPeriodicSnapshotTaskRead,
+		Schema: map[string]*schema.Schema{
+			"dataset": &schema.Schema{
+				Description: "Dataset or zvol the task snapshots, eg. Tank/data",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"naming_schema": &schema.Schema{
+				Description: "Snapshot name format, required if dataset has more than one periodic snapshot task",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+			},
+			"task_id": &schema.Schema{
+				Description: "Periodic snapshot task ID",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"recursive": &schema.Schema{
+				Description: "Snapshot child datasets as well",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"exclude": &schema.Schema{
+				Description: "Child datasets excluded from recursive snapshots",
+				Type:        schema.TypeSet,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"lifetime_value": &schema.Schema{
+				Description: "How long snapshots are kept, in `lifetime_unit` units",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"lifetime_unit": &schema.Schema{
+				Description: "Snapshot lifetime unit: HOUR, DAY, WEEK, MONTH or YEAR",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"allow_empty": &schema.Schema{
+				Description: "Take snapshots even if dataset has not changed since the last one",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"enabled": &schema.Schema{
+				Description: "`true` if task is enabled",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"schedule": &schema.Schema{
+				Description: "Task schedule",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"minute": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"hour": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"dom": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"month": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"dow": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"begin": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"end": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceTrueNASPeriodicSnapshotTaskRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	dataset := d.Get("dataset").(string)
+	filters := queryFilter("dataset", dataset)
+
+	if namingSchema, ok := d.GetOk("naming_schema"); ok {
+		filters = append(filters, []interface{}{"naming_schema", "=", namingSchema.(string)})
+	}
+
+	var tasks []periodicSnapshotTask
+
+	if err := c.invoke(ctx, "pool.snapshottask.query", []interface{}{filters}, &tasks); err != nil {
+		return apiErrorDiags(err, "error getting periodic snapshot tasks", nil)
+	}
+
+	switch len(tasks) {
+	case 0:
+		return diag.Errorf("no periodic snapshot task found for dataset %s", dataset)
+	case 1:
+	default:
+		return diag.Errorf("found %d periodic snapshot tasks for dataset %s, set naming_schema to select one", len(tasks), dataset)
+	}
+
+	if err := flattenPeriodicSnapshotTask(d, &tasks[0]); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(strconv.Itoa(tasks[0].ID))
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package truenas
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"regexp"
+	"testing"
+)
+
+func TestAccDataSourceTruenasPeriodicSnapshotTask_basic(t *testing.T) {
+	suffix := acctest.RandStringFromCharSet(5, acctest.CharSetAlphaNum)
+	datasetName := fmt.Sprintf("%s-%s", testResourcePrefix, suffix)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckDataSourceTruenasPeriodicSnapshotTaskConfig(testPoolName, datasetName),
+				Check:  testAccCheckDataSourceTruenasPeriodicSnapshotTask(),
+			},
+		},
+	})
+}
+
+func TestUnitDataSourceTruenasPeriodicSnapshotTask_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckDataSourceTruenasPeriodicSnapshotTaskConfig(fakePoolName, "data"),
+				Check:  testAccCheckDataSourceTruenasPeriodicSnapshotTask(),
+			},
+			{
+				Config: f.providerConfig() + testAccCheckDataSourceTruenasPeriodicSnapshotTaskConfig(fakePoolName, "data") + `
+				resource "truenas_periodic_snapshot_task" "hourly" {
+					dataset = truenas_dataset.test.id
+					naming_schema = "hourly-%Y%m%d-%H%M"
+					schedule {}
+				}
+
+				data "truenas_periodic_snapshot_task" "ambiguous" {
+					dataset = truenas_periodic_snapshot_task.hourly.dataset
+				}
+				`,
+				ExpectError: regexp.MustCompile("found 2 periodic snapshot tasks for dataset Tank/data"),
+			},
+		},
+	})
+}
+
+func testAccCheckDataSourceTruenasPeriodicSnapshotTask() resource.TestCheckFunc {
+	resourceName := "data.truenas_periodic_snapshot_task.test"
+
+	return resource.ComposeTestCheckFunc(
+		resource.TestCheckResourceAttrPair(resourceName, "task_id", "truenas_periodic_snapshot_task.test", "task_id"),
+		resource.TestCheckResourceAttr(resourceName, "lifetime_value", "1"),
+		resource.TestCheckResourceAttr(resourceName, "lifetime_unit", "MONTH"),
+		resource.TestCheckResourceAttr(resourceName, "schedule.0.hour", "0"),
+		resource.TestCheckResourceAttr(resourceName, "schedule.0.end", "23:59"),
+	)
+}
+
+func testAccCheckDataSourceTruenasPeriodicSnapshotTaskConfig(pool string, datasetName string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "test" {
+		name = "%s"
+		pool = "%s"
+	}
+
+	resource "truenas_periodic_snapshot_task" "test" {
+		dataset = truenas_dataset.test.id
+		lifetime_value = 1
+		lifetime_unit = "MONTH"
+
+		schedule {
+			hour = "0"
+		}
+	}
+
+	data "truenas_periodic_snapshot_task" "test" {
+		dataset = truenas_periodic_snapshot_task.test.dataset
+		naming_schema = truenas_periodic_snapshot_task.test.naming_schema
+	}
+	`, datasetName, pool)
+}
@@ -0,0 +1,341 @@
+package truenas
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// poolDetailsSchema returns computed pool attributes shared by truenas_pool and truenas_pools data sources
+func poolDetailsSchema() map[string]*schema.Schema {
+	vdevs := func(description string) *schema.Schema {
+		return &schema.Schema{
+			Description: description,
+			Type:        schema.TypeList,
+			Computed:    true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": &schema.Schema{
+						Description: "Vdev name, eg. mirror-0",
+						Type:        schema.TypeString,
+						Computed:    true,
+					},
+					"type": &schema.Schema{
+						Description: "Vdev type, eg. MIRROR, RAIDZ2 or DISK for single disk vdevs",
+						Type:        schema.TypeString,
+						Computed:    true,
+					},
+					"status": &schema.Schema{
+						Description: "Vdev status, eg. ONLINE",
+						Type:        schema.TypeString,
+						Computed:    true,
+					},
+					"disks": &schema.Schema{
+						Description: "Vdev disks",
+						Type:        schema.TypeList,
+						Computed:    true,
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"name": &schema.Schema{
+									Description: "Disk name, eg. sda",
+									Type:        schema.TypeString,
+									Computed:    true,
+								},
+								"device": &schema.Schema{
+									Description: "Device used by pool, eg. sda2",
+									Type:        schema.TypeString,
+									Computed:    true,
+								},
+								"status": &schema.Schema{
+									Description: "Disk status, eg. ONLINE or FAULTED",
+									Type:        schema.TypeString,
+									Computed:    true,
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	return map[string]*schema.Schema{
+		"pool_id": &schema.Schema{
+			Description: "Pool ID",
+			Type:        schema.TypeInt,
+			Computed:    true,
+		},
+		"name": &schema.Schema{
+			Description: "Pool name",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+		"guid": &schema.Schema{
+			Description: "Pool GUID",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+		"path": &schema.Schema{
+			Description: "Pool mount path",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+		"status": &schema.Schema{
+			Description: "Pool status, eg. ONLINE or DEGRADED",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+		"healthy": &schema.Schema{
+			Description: "`true` if pool is healthy",
+			Type:        schema.TypeBool,
+			Computed:    true,
+		},
+		"size": &schema.Schema{
+			Description: "Pool size in bytes",
+			Type:        schema.TypeInt,
+			Computed:    true,
+		},
+		"allocated": &schema.Schema{
+			Description: "Allocated space in bytes",
+			Type:        schema.TypeInt,
+			Computed:    true,
+		},
+		"free": &schema.Schema{
+			Description: "Free space in bytes",
+			Type:        schema.TypeInt,
+			Computed:    true,
+		},
+		"fragmentation": &schema.Schema{
+			Description: "Free space fragmentation in percent",
+			Type:        schema.TypeInt,
+			Computed:    true,
+		},
+		"autotrim": &schema.Schema{
+			Description: "Automatic TRIM of pool disks: on or off",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+		"encrypted": &schema.Schema{
+			Description: "`true` if pool root dataset is encrypted",
+			Type:        schema.TypeBool,
+			Computed:    true,
+		},
+		"locked": &schema.Schema{
+			Description: "`true` if pool is encrypted and its key is not loaded",
+			Type:        schema.TypeBool,
+			Computed:    true,
+		},
+		"scan": &schema.Schema{
+			Description: "State of the last scrub or resilver",
+			Type:        schema.TypeList,
+			Computed:    true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"function": &schema.Schema{
+						Description: "Scan function: SCRUB or RESILVER",
+						Type:        schema.TypeString,
+						Computed:    true,
+					},
+					"state": &schema.Schema{
+						Description: "Scan state: SCANNING, FINISHED or CANCELED",
+						Type:        schema.TypeString,
+						Computed:    true,
+					},
+					"start_time": &schema.Schema{
+						Description: "Scan start time in RFC3339 format",
+						Type:        schema.TypeString,
+						Computed:    true,
+					},
+					"end_time": &schema.Schema{
+						Description: "Scan end time in RFC3339 format, empty while scan is running",
+						Type:        schema.TypeString,
+						Computed:    true,
+					},
+					"percentage": &schema.Schema{
+						Description: "Scan progress in percent",
+						Type:        schema.TypeFloat,
+						Computed:    true,
+					},
+					"errors": &schema.Schema{
+						Description: "Number of errors found by scan",
+						Type:        schema.TypeInt,
+						Computed:    true,
+					},
+				},
+			},
+		},
+		"topology": &schema.Schema{
+			Description: "Pool vdevs and disks",
+			Type:        schema.TypeList,
+			Computed:    true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"data":    vdevs("Data vdevs"),
+					"log":     vdevs("SLOG vdevs"),
+					"cache":   vdevs("L2ARC vdevs"),
+					"spare":   vdevs("Hot spares"),
+					"special": vdevs("Special allocation class vdevs"),
+					"dedup":   vdevs("Deduplication table vdevs"),
+				},
+			},
+		},
+	}
+}
+
+func dataSourceTrueNASPool() *schema.Resource {
+	s := poolDetailsSchema()
+
+	s["pool_id"].Optional = true
+	s["pool_id"].ExactlyOneOf = []string{"pool_id", "name"}
+	s["name"].Optional = true
+	s["name"].ExactlyOneOf = []string{"pool_id", "name"}
+
+	return &schema.Resource{
+		Description: "Get information about a storage pool, looked up by ID or name",
+		ReadContext: dataSourceTrueNASPoolRead,
+		Schema:      s,
+	}
+}
+
+func dataSourceTrueNASPoolRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	var resp storagePool
+
+	if id, ok := d.GetOk("pool_id"); ok {
+		if err := c.invoke(ctx, "pool.get_instance", []interface{}{id}, &resp); err != nil {
+			return apiErrorDiags(err, "error getting pool", nil)
+		}
+	} else {
+		name := d.Get("name").(string)
+
+		var pools []storagePool
+
+		if err := c.invoke(ctx, "pool.query", []interface{}{queryFilter("name", name)}, &pools); err != nil {
+			return apiErrorDiags(err, "error getting pool", nil)
+		}
+
+		if len(pools) == 0 {
+			return diag.Errorf("pool %s not found", name)
+		}
+
+		resp = pools[0]
+	}
+
+	for k, v := range flattenPoolDetails(&resp) {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	d.SetId(strconv.Itoa(resp.ID))
+
+	return nil
+}
+
+// flattenPoolDetails converts pool into poolDetailsSchema attributes
+func flattenPoolDetails(p *storagePool) map[string]interface{} {
+	result := map[string]interface{}{
+		"pool_id":   p.ID,
+		"name":      p.Name,
+		"guid":      p.GUID,
+		"path":      p.Path,
+		"status":    p.Status,
+		"healthy":   p.Healthy,
+		"encrypted": p.Encrypt > 0,
+		"locked":    p.Encrypt > 0 && !p.IsDecrypted,
+		"scan":      []interface{}{},
+		"topology":  []interface{}{},
+	}
+
+	for attr, v := range map[string]*int64{"size": p.Size, "allocated": p.Allocated, "free": p.Free} {
+		if v != nil {
+			result[attr] = int(*v)
+		}
+	}
+
+	if fragmentation, err := p.Fragmentation.Int64(); err == nil {
+		result["fragmentation"] = int(fragmentation)
+	}
+
+	if p.Autotrim.Value != nil {
+		result["autotrim"] = strings.ToLower(*p.Autotrim.Value)
+	}
+
+	if p.Scan != nil {
+		scan := map[string]interface{}{
+			"function":   derefString(p.Scan.Function),
+			"state":      derefString(p.Scan.State),
+			"start_time": formatAPIDate(p.Scan.StartTime),
+			"end_time":   formatAPIDate(p.Scan.EndTime),
+		}
+
+		if p.Scan.Percentage != nil {
+			scan["percentage"] = *p.Scan.Percentage
+		}
+
+		if p.Scan.Errors != nil {
+			scan["errors"] = int(*p.Scan.Errors)
+		}
+
+		result["scan"] = []interface{}{scan}
+	}
+
+	if p.Topology != nil {
+		result["topology"] = []interface{}{map[string]interface{}{
+			"data":    flattenPoolVdevs(p.Topology.Data),
+			"log":     flattenPoolVdevs(p.Topology.Log),
+			"cache":   flattenPoolVdevs(p.Topology.Cache),
+			"spare":   flattenPoolVdevs(p.Topology.Spare),
+			"special": flattenPoolVdevs(p.Topology.Special),
+			"dedup":   flattenPoolVdevs(p.Topology.Dedup),
+		}}
+	}
+
+	return result
+}
+
+// flattenPoolVdevs converts vdevs as reported by middleware, single disk vdev is listed as its own disk
+func flattenPoolVdevs(vdevs []poolVdev) []interface{} {
+	result := make([]interface{}, 0, len(vdevs))
+
+	flattenDisk := func(disk poolVdev) interface{} {
+		return map[string]interface{}{
+			"name":   derefString(disk.Disk),
+			"device": derefString(disk.Device),
+			"status": disk.Status,
+		}
+	}
+
+	for _, vdev := range vdevs {
+		disks := make([]interface{}, 0, len(vdev.Children))
+
+		if vdev.Type == vdevTypeDisk {
+			disks = append(disks, flattenDisk(vdev))
+		}
+
+		for _, child := range vdev.Children {
+			disks = append(disks, flattenDisk(child))
+		}
+
+		result = append(result, map[string]interface{}{
+			"name":   vdev.Name,
+			"type":   vdev.Type,
+			"status": vdev.Status,
+			"disks":  disks,
+		})
+	}
+
+	return result
+}
+
+func formatAPIDate(date *apiDate) string {
+	if date == nil {
+		return ""
+	}
+
+	return time.UnixMilli(date.Date).UTC().Format(time.RFC3339)
+}
@@ -5,13 +5,12 @@ import (
 	api "github.com/dariusbakunas/truenas-go-sdk"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"strconv"
-	"time"
 )
 
 func dataSourceTrueNASPoolIDs() *schema.Resource {
 	return &schema.Resource{
-		ReadContext: dataSourceTrueNASPoolsRead,
+		DeprecationMessage: "Use truenas_pools data source, that returns pool details along with IDs",
+		ReadContext:        dataSourceTrueNASPoolIdsRead,
 		Schema: map[string]*schema.Schema{
 			"ids": {
 				Type:     schema.TypeSet,
@@ -22,8 +21,8 @@ func dataSourceTrueNASPoolIDs() *schema.Resource {
 	}
 }
 
-func dataSourceTrueNASPoolsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+func dataSourceTrueNASPoolIdsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
 
 	// Warning or errors can be collected in a slice type
 	var diags diag.Diagnostics
@@ -31,11 +30,7 @@ func dataSourceTrueNASPoolsRead(ctx context.Context, d *schema.ResourceData, m i
 	pools, _, err := c.PoolApi.ListPools(ctx).Execute()
 
 	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error getting pool ids: %s\n%s", err, body)
+		return apiErrorDiags(err, "error getting pool ids", nil)
 	}
 
 	converted := flattenPoolsResponse(pools)
@@ -44,9 +39,7 @@ func dataSourceTrueNASPoolsRead(ctx context.Context, d *schema.ResourceData, m i
 		return diag.FromErr(err)
 	}
 
-	// always run
-	// TODO: not sure yet what should go here, find out
-	d.SetId(strconv.FormatInt(time.Now().Unix(), 10))
+	d.SetId("pool-ids")
 
 	return diags
 }
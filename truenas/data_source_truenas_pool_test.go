@@ -0,0 +1,86 @@
+package truenas
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"regexp"
+	"testing"
+)
+
+func TestAccDataSourceTruenasPool_basic(t *testing.T) {
+	resourceName := "data.truenas_pool.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckDataSourceTruenasPoolConfig(testPoolName),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "name", testPoolName),
+					resource.TestCheckResourceAttrSet(resourceName, "pool_id"),
+					resource.TestCheckResourceAttrSet(resourceName, "size"),
+					resource.TestCheckResourceAttrSet(resourceName, "free"),
+					resource.TestCheckResourceAttrSet(resourceName, "topology.0.data.0.type"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitDataSourceTruenasPool_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "data.truenas_pool.test"
+
+	check := resource.ComposeTestCheckFunc(
+		resource.TestCheckResourceAttr(resourceName, "pool_id", "1"),
+		resource.TestCheckResourceAttr(resourceName, "name", fakePoolName),
+		resource.TestCheckResourceAttr(resourceName, "path", "/mnt/Tank"),
+		resource.TestCheckResourceAttr(resourceName, "status", "ONLINE"),
+		resource.TestCheckResourceAttr(resourceName, "healthy", "true"),
+		resource.TestCheckResourceAttr(resourceName, "size", "10737418240"),
+		resource.TestCheckResourceAttr(resourceName, "free", "9663676416"),
+		resource.TestCheckResourceAttr(resourceName, "fragmentation", "1"),
+		resource.TestCheckResourceAttr(resourceName, "encrypted", "false"),
+		resource.TestCheckResourceAttr(resourceName, "locked", "false"),
+		resource.TestCheckResourceAttr(resourceName, "scan.0.function", "SCRUB"),
+		resource.TestCheckResourceAttr(resourceName, "scan.0.end_time", "2022-12-02T17:03:20Z"),
+		resource.TestCheckResourceAttr(resourceName, "topology.0.data.0.name", "mirror-0"),
+		resource.TestCheckResourceAttr(resourceName, "topology.0.data.0.type", "MIRROR"),
+		resource.TestCheckResourceAttr(resourceName, "topology.0.data.0.disks.#", "2"),
+		resource.TestCheckResourceAttr(resourceName, "topology.0.data.0.disks.1.name", "sdb"),
+		resource.TestCheckResourceAttr(resourceName, "topology.0.data.0.disks.1.device", "sdb2"),
+	)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckDataSourceTruenasPoolConfig(fakePoolName),
+				Check:  check,
+			},
+			{
+				Config: f.providerConfig() + `
+				data "truenas_pool" "test" {
+					pool_id = 1
+				}
+				`,
+				Check: check,
+			},
+			{
+				Config:      f.providerConfig() + testAccCheckDataSourceTruenasPoolConfig("Missing"),
+				ExpectError: regexp.MustCompile("pool Missing not found"),
+			},
+		},
+	})
+}
+
+func testAccCheckDataSourceTruenasPoolConfig(name string) string {
+	return fmt.Sprintf(`
+	data "truenas_pool" "test" {
+		name = "%s"
+	}
+	`, name)
+}
@@ -0,0 +1,48 @@
+package truenas
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceTrueNASPools() *schema.Resource {
+	return &schema.Resource{
+		Description: "Get information about all storage pools, eg. to pick the pool with most free space",
+		ReadContext: dataSourceTrueNASPoolsRead,
+		Schema: map[string]*schema.Schema{
+			"pools": &schema.Schema{
+				Description: "Storage pools ordered by ID",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: poolDetailsSchema(),
+				},
+			},
+		},
+	}
+}
+
+func dataSourceTrueNASPoolsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	var pools []storagePool
+
+	if err := c.invoke(ctx, "pool.query", nil, &pools); err != nil {
+		return apiErrorDiags(err, "error getting pools", nil)
+	}
+
+	result := make([]interface{}, 0, len(pools))
+
+	for i := range pools {
+		result = append(result, flattenPoolDetails(&pools[i]))
+	}
+
+	if err := d.Set("pools", result); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId("pools")
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package truenas
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"testing"
+)
+
+func TestAccDataSourceTruenasPools_basic(t *testing.T) {
+	resourceName := "data.truenas_pools.all"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckDataSourceTruenasPoolsConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "pools"),
+					resource.TestCheckResourceAttrSet(resourceName, "pools.0.name"),
+					resource.TestCheckResourceAttrSet(resourceName, "pools.0.free"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitDataSourceTruenasPools_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "data.truenas_pools.all"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + `
+				resource "truenas_pool" "backup" {
+					name = "Backup"
+					allow_destroy = true
+
+					topology {
+						data {
+							type = "RAIDZ1"
+							disks = ["sdc", "sdd", "sde"]
+						}
+						spares = ["sdf"]
+					}
+				}
+
+				data "truenas_pools" "all" {
+					depends_on = [truenas_pool.backup]
+				}
+
+				locals {
+					most_free = [for p in data.truenas_pools.all.pools : p.name if p.free == max(data.truenas_pools.all.pools[*].free...)][0]
+				}
+
+				output "most_free" {
+					value = local.most_free
+				}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "pools"),
+					resource.TestCheckResourceAttr(resourceName, "pools.#", "2"),
+					resource.TestCheckResourceAttr(resourceName, "pools.0.name", fakePoolName),
+					resource.TestCheckResourceAttr(resourceName, "pools.1.name", "Backup"),
+					resource.TestCheckResourceAttr(resourceName, "pools.1.topology.0.data.0.type", "RAIDZ1"),
+					resource.TestCheckResourceAttr(resourceName, "pools.1.topology.0.data.0.disks.#", "3"),
+					resource.TestCheckResourceAttr(resourceName, "pools.1.topology.0.spare.0.type", "DISK"),
+					resource.TestCheckResourceAttr(resourceName, "pools.1.topology.0.spare.0.disks.0.name", "sdf"),
+					resource.TestCheckOutput("most_free", "Backup"),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckDataSourceTruenasPoolsConfig() string {
+	return `
+	data "truenas_pools" "all" {}
+	`
+}
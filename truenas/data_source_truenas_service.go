@@ -2,7 +2,6 @@ package truenas
 
 import (
 	"context"
-	api "github.com/dariusbakunas/truenas-go-sdk"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"strconv"
@@ -49,17 +48,13 @@ func dataSourceTrueNASService() *schema.Resource {
 func dataSourceTrueNASServiceRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id := d.Get("service_id").(int)
 
 	resp, _, err := c.ServiceApi.GetService(ctx, int32(id)).Execute()
 
 	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error getting service: %s\n%s", err, body)
+		return apiErrorDiags(err, "error getting service", nil)
 	}
 
 	d.Set("name", resp.Service)
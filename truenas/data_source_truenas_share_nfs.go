@@ -108,17 +108,13 @@ func dataSourceTrueNASShareNFS() *schema.Resource {
 func dataSourceTrueNASShareNFSRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id := d.Get("sharenfs_id").(int)
 
-	resp, _, err := c.SharingApi.GetShareNFS(ctx, int32(id)).Execute()
+	var resp api.ShareNFS
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error getting share: %s\n%s", err, body)
+	if err := c.invoke(ctx, "sharing.nfs.get_instance", []interface{}{id}, &resp); err != nil {
+		return apiErrorDiags(err, "error getting share", nil)
 	}
 
 	if resp.Comment != nil {
@@ -151,17 +151,13 @@ func dataSourceTrueNASShareSMB() *schema.Resource {
 func dataSourceTrueNASShareSMBRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id := d.Get("sharesmb_id").(int)
 
-	resp, _, err := c.SharingApi.GetShareSMB(ctx, int32(id)).Execute()
+	var resp api.ShareSMB
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error getting share: %s\n%s", err, body)
+	if err := c.invoke(ctx, "sharing.smb.get_instance", []interface{}{id}, &resp); err != nil {
+		return apiErrorDiags(err, "error getting share", nil)
 	}
 
 	d.Set("path", resp.Path)
@@ -0,0 +1,151 @@
+package truenas
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"log"
+)
+
+func dataSourceTrueNASSystemInfo() *schema.Resource {
+	return &schema.Resource{
+		Description: "Get TrueNAS system information, including version and product type (CORE or SCALE)",
+		ReadContext: dataSourceTrueNASSystemInfoRead,
+		Schema: map[string]*schema.Schema{
+			"version": &schema.Schema{
+				Description: "Full TrueNAS version, eg. TrueNAS-SCALE-22.12.0",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"release": &schema.Schema{
+				Description: "Numeric TrueNAS release, eg. 22.12.0",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"product_type": &schema.Schema{
+				Description: "TrueNAS product type: CORE, ENTERPRISE, SCALE or SCALE_ENTERPRISE",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"scale": &schema.Schema{
+				Description: "`true` if system is running TrueNAS SCALE",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"hostname": &schema.Schema{
+				Description: "TrueNAS hostname",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"model": &schema.Schema{
+				Description: "CPU model",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"cores": &schema.Schema{
+				Description: "Number of logical CPU cores",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"physical_cores": &schema.Schema{
+				Description: "Number of physical CPU cores",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"physmem": &schema.Schema{
+				Description: "Physical memory in bytes",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"ecc_memory": &schema.Schema{
+				Description: "`true` if system has ECC memory",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"uptime_seconds": &schema.Schema{
+				Description: "System uptime in seconds",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"timezone": &schema.Schema{
+				Description: "System timezone",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"system_manufacturer": &schema.Schema{
+				Description: "System manufacturer",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"system_product": &schema.Schema{
+				Description: "System product name",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"system_serial": &schema.Schema{
+				Description: "System serial number",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceTrueNASSystemInfoRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	c := m.(*Client)
+
+	info, err := c.getSystemInfo(ctx)
+
+	if err != nil {
+		return apiErrorDiags(err, "error getting system info", nil)
+	}
+
+	release, err := parseSystemRelease(info.Version)
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	productType, err := c.getProductType(ctx)
+
+	if err != nil {
+		log.Printf("[WARN] Unable to get TrueNAS product type: %s", err)
+	}
+
+	system := &systemInfo{
+		Version:     info.Version,
+		ProductType: productType,
+		Release:     release,
+	}
+
+	d.Set("version", info.Version)
+	d.Set("release", release.String())
+	d.Set("product_type", productType)
+	d.Set("scale", system.isSCALE())
+	d.Set("hostname", info.Hostname)
+	d.Set("model", info.Model)
+	d.Set("cores", info.Cores)
+	d.Set("physical_cores", info.PhysicalCores)
+	d.Set("physmem", info.Physmem)
+	d.Set("ecc_memory", info.EccMemory)
+	d.Set("uptime_seconds", int(info.UptimeSeconds))
+	d.Set("timezone", info.Timezone)
+
+	if info.SystemManufacturer != nil {
+		d.Set("system_manufacturer", *info.SystemManufacturer)
+	}
+
+	if info.SystemProduct != nil {
+		d.Set("system_product", *info.SystemProduct)
+	}
+
+	if info.SystemSerial != nil {
+		d.Set("system_serial", *info.SystemSerial)
+	}
+
+	d.SetId("system-info")
+
+	return diags
+}
@@ -0,0 +1,56 @@
+package truenas
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"regexp"
+	"testing"
+)
+
+func TestAccDataSourceTruenasSystemInfo_basic(t *testing.T) {
+	resourceName := "data.truenas_system_info.system"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckDataSourceTruenasSystemInfoConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr(resourceName, "version", regexp.MustCompile(`^TrueNAS-`)),
+					resource.TestMatchResourceAttr(resourceName, "release", regexp.MustCompile(`^\d+\.\d+\.\d+$`)),
+					resource.TestCheckResourceAttrSet(resourceName, "product_type"),
+					resource.TestCheckResourceAttrSet(resourceName, "hostname"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitDataSourceTruenasSystemInfo_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "data.truenas_system_info.system"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckDataSourceTruenasSystemInfoConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "version", "TrueNAS-SCALE-22.12.0"),
+					resource.TestCheckResourceAttr(resourceName, "release", "22.12.0"),
+					resource.TestCheckResourceAttr(resourceName, "product_type", "SCALE"),
+					resource.TestCheckResourceAttr(resourceName, "scale", "true"),
+					resource.TestCheckResourceAttr(resourceName, "uptime_seconds", "3600"),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckDataSourceTruenasSystemInfoConfig() string {
+	return `
+		data "truenas_system_info" "system" {}
+	`
+}
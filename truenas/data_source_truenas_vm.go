@@ -130,21 +130,17 @@ func dataSourceTrueNASVM() *schema.Resource {
 func dataSourceTrueNASVMRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id, err := strconv.Atoi(d.Get("vm_id").(string))
 
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	resp, _, err := c.VmApi.GetVM(ctx, int32(id)).Execute()
+	var resp api.VM
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error getting VM: %s\n%s", err, body)
+	if err := c.invoke(ctx, "vm.get_instance", []interface{}{id}, &resp); err != nil {
+		return apiErrorDiags(err, "error getting VM", nil)
 	}
 
 	d.Set("name", resp.Name)
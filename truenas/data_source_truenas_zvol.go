@@ -98,6 +98,14 @@ func dataSourceTrueNASZVOL() *schema.Resource {
 				Description: "Sets the data write synchronization. `inherit` takes the sync settings from the parent dataset, `standard` uses the settings that have been requested by the client software, `always` waits for data writes to complete, and `disabled` never waits for writes to complete.",
 				Computed:    true,
 			},
+			"user_properties": &schema.Schema{
+				Description: "ZFS user properties, eg. `org:owner`, including inherited ones",
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 			"volsize": &schema.Schema{
 				Type:     schema.TypeInt,
 				Computed: true,
@@ -109,17 +117,13 @@ func dataSourceTrueNASZVOL() *schema.Resource {
 func dataSourceTrueNASZVOLRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id := d.Get("zvol_id").(string)
 
-	resp, _, err := c.DatasetApi.GetDataset(ctx, id).Execute()
+	var resp api.Dataset
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error getting zvol: %s\n%s", err, body)
+	if err := c.invoke(ctx, "pool.dataset.get_instance", []interface{}{id}, &resp); err != nil {
+		return apiErrorDiags(err, "error getting zvol", nil)
 	}
 
 	if resp.Type != "VOLUME" {
@@ -231,6 +235,8 @@ func dataSourceTrueNASZVOLRead(ctx context.Context, d *schema.ResourceData, m in
 		d.Set("encrypted", *resp.Encrypted)
 	}
 
+	d.Set("user_properties", datasetUserProperties(&resp, false))
+
 	d.SetId(resp.Id)
 
 	return diags
@@ -0,0 +1,38 @@
+package truenas
+
+import (
+	"context"
+	"log"
+)
+
+// cloneSnapshot creates dataset or zvol id from snapshot, clone depends on its origin snapshot until it is promoted
+func cloneSnapshot(ctx context.Context, c *Client, snapshot string, id string) error {
+	input := map[string]interface{}{
+		"snapshot":    snapshot,
+		"dataset_dst": id,
+	}
+
+	log.Printf("[DEBUG] Cloning TrueNAS snapshot: %+v", input)
+
+	if err := c.invoke(ctx, "zfs.snapshot.clone", []interface{}{input}, nil); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] TrueNAS snapshot (%s) cloned to %s", snapshot, id)
+
+	return nil
+}
+
+// promoteDataset reverses clone dependency, origin snapshot and snapshots taken before it are moved
+// to the clone, so that origin dataset can be destroyed
+func promoteDataset(ctx context.Context, c *Client, id string) error {
+	log.Printf("[DEBUG] Promoting TrueNAS dataset: %s", id)
+
+	if err := c.invoke(ctx, "pool.dataset.promote", []interface{}{id}, nil); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] TrueNAS dataset (%s) promoted", id)
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package truenas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	api "github.com/dariusbakunas/truenas-go-sdk"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"log"
+	"sort"
+	"strings"
+)
+
+// datasetKeyAttrs are dataset attributes changed in place with pool.dataset.change_key
+var datasetKeyAttrs = []string{"passphrase", "encryption_key", "generate_key", "pbkdf2iters", "inherit_encryption"}
+
+// datasetUnlockResult is pool.dataset.unlock job result
+type datasetUnlockResult struct {
+	Unlocked []string `json:"unlocked"`
+	Failed   map[string]struct {
+		Error   string   `json:"error"`
+		Skipped []string `json:"skipped"`
+	} `json:"failed"`
+}
+
+// expandDatasetEncryptionOptions returns encryption options of pool.dataset.create for datasets and zvols
+func expandDatasetEncryptionOptions(d *schema.ResourceData) *api.CreateDatasetParamsEncryptionOptions {
+	encOptions := &api.CreateDatasetParamsEncryptionOptions{}
+
+	if algorithm, ok := d.GetOk("encryption_algorithm"); ok {
+		encOptions.Algorithm = getStringPtr(algorithm.(string))
+	}
+
+	if genKey, ok := d.GetOk("generate_key"); ok {
+		encOptions.GenerateKey = getBoolPtr(genKey.(bool))
+	}
+
+	if passphrase, ok := d.GetOk("passphrase"); ok {
+		encOptions.Passphrase = getStringPtr(passphrase.(string))
+	}
+
+	if key, ok := d.GetOk("encryption_key"); ok {
+		encOptions.Key = getStringPtr(key.(string))
+	}
+
+	return encOptions
+}
+
+// updateDatasetEncryption unlocks dataset first, so that its key can be changed, then makes it inherit parent
+// encryption or changes its key. Locking is left to the caller, once other properties are updated
+func updateDatasetEncryption(ctx context.Context, c *Client, d *schema.ResourceData, attrs apiAttrMap) diag.Diagnostics {
+	id := d.Id()
+
+	if d.HasChange("locked") && !d.Get("locked").(bool) {
+		// dataset is unlocked with current key, before it is changed
+		passphrase, _ := d.GetChange("passphrase")
+		key, _ := d.GetChange("encryption_key")
+
+		if err := unlockDataset(ctx, c, id, passphrase.(string), key.(string)); err != nil {
+			return apiErrorDiags(err, "error unlocking dataset", nil)
+		}
+	}
+
+	if d.HasChange("inherit_encryption") && d.Get("inherit_encryption").(bool) {
+		log.Printf("[DEBUG] Inheriting TrueNAS dataset (%s) encryption from parent", id)
+
+		if err := c.invoke(ctx, "pool.dataset.inherit_parent_encryption_properties", []interface{}{id}, nil); err != nil {
+			return apiErrorDiags(err, "error inheriting parent encryption", nil)
+		}
+	} else if datasetKeyChanged(d) {
+		options, err := expandDatasetKeyOptions(d)
+
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		if err := changeDatasetKey(ctx, c, id, options); err != nil {
+			return apiErrorDiags(err, "error changing dataset key", attrs)
+		}
+	}
+
+	return nil
+}
+
+// customizeDiffDatasetEncryption validates encryption changes, that depend on dataset lock state
+func customizeDiffDatasetEncryption(d *schema.ResourceDiff) error {
+	if d.HasChange("locked") && d.Get("locked").(bool) && d.NewValueKnown("passphrase") && d.Get("passphrase").(string) == "" {
+		return errors.New("only passphrase encrypted datasets can be locked, passphrase is required")
+	}
+
+	if d.Get("inherit_encryption").(bool) && d.Get("passphrase").(string) != "" {
+		return errors.New("passphrase can not be set when inheriting encryption")
+	}
+
+	if d.Id() == "" {
+		return nil
+	}
+
+	oldLocked, newLocked := d.GetChange("locked")
+
+	if oldLocked.(bool) && newLocked.(bool) {
+		for _, attr := range datasetKeyAttrs {
+			if d.HasChange(attr) {
+				return fmt.Errorf("dataset %s is locked, set locked = false to change %s", d.Id(), attr)
+			}
+		}
+	}
+
+	if d.HasChange("pbkdf2iters") && d.NewValueKnown("passphrase") && d.Get("passphrase").(string) == "" {
+		return errors.New("pbkdf2iters can only be changed for passphrase encrypted datasets, passphrase is required")
+	}
+
+	if d.Get("inherit_encryption").(bool) || !datasetKeyChanged(d) {
+		return nil
+	}
+
+	// existing key is never rotated implicitly, key change must come with a passphrase, key or generate_key
+	hasPassphrase := !d.NewValueKnown("passphrase") || d.Get("passphrase").(string) != ""
+	hasKey := d.HasChange("encryption_key") && (!d.NewValueKnown("encryption_key") || d.Get("encryption_key").(string) != "")
+
+	if !hasPassphrase && !hasKey && !datasetKeyGenerated(d) {
+		return fmt.Errorf("dataset %s key can not be changed without new passphrase, encryption_key or generate_key = true", d.Id())
+	}
+
+	return nil
+}
+
+// datasetKeyDiff is implemented by both schema.ResourceData and schema.ResourceDiff
+type datasetKeyDiff interface {
+	HasChange(key string) bool
+	Get(key string) interface{}
+}
+
+// datasetKeyChanged returns true if dataset key has to be changed, turning generate_key off keeps current key
+func datasetKeyChanged(d datasetKeyDiff) bool {
+	for _, attr := range []string{"passphrase", "encryption_key", "pbkdf2iters", "inherit_encryption"} {
+		if d.HasChange(attr) {
+			return true
+		}
+	}
+
+	return datasetKeyGenerated(d)
+}
+
+// datasetKeyGenerated returns true if generate_key is switched on
+func datasetKeyGenerated(d datasetKeyDiff) bool {
+	return d.HasChange("generate_key") && d.Get("generate_key").(bool)
+}
+
+// expandDatasetKeyOptions returns pool.dataset.change_key options, passphrase takes precedence over key,
+// new key is generated only if generate_key is switched on
+func expandDatasetKeyOptions(d *schema.ResourceData) (map[string]interface{}, error) {
+	options := make(map[string]interface{})
+
+	if passphrase, ok := d.GetOk("passphrase"); ok {
+		options["passphrase"] = passphrase.(string)
+
+		if iters, ok := d.GetOk("pbkdf2iters"); ok {
+			options["pbkdf2iters"] = iters.(int)
+		}
+	} else if datasetKeyGenerated(d) {
+		options["generate_key"] = true
+	} else if key, ok := d.GetOk("encryption_key"); ok && d.HasChange("encryption_key") {
+		options["key"] = key.(string)
+	} else {
+		return nil, fmt.Errorf("dataset %s key can not be changed without new passphrase, encryption_key or generate_key = true", d.Id())
+	}
+
+	return options, nil
+}
+
+// changeDatasetKey changes dataset key or passphrase, dataset that inherits encryption becomes encryption root
+func changeDatasetKey(ctx context.Context, c *Client, id string, options map[string]interface{}) error {
+	log.Printf("[DEBUG] Changing TrueNAS dataset (%s) key", id)
+
+	if err := c.invokeJob(ctx, "pool.dataset.change_key", []interface{}{id, options}, nil); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] TrueNAS dataset (%s) key changed", id)
+
+	return nil
+}
+
+func lockDataset(ctx context.Context, c *Client, id string) error {
+	log.Printf("[DEBUG] Locking TrueNAS dataset: %s", id)
+
+	if err := c.invokeJob(ctx, "pool.dataset.lock", []interface{}{id, map[string]interface{}{"force_umount": false}}, nil); err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] TrueNAS dataset (%s) locked", id)
+
+	return nil
+}
+
+// unlockDataset unlocks dataset with passphrase or key, unlock job succeeds even if dataset could not be
+// unlocked, so failures are reported from job result
+func unlockDataset(ctx context.Context, c *Client, id string, passphrase string, key string) error {
+	entry := map[string]interface{}{"name": id}
+
+	if passphrase != "" {
+		entry["passphrase"] = passphrase
+	} else if key != "" {
+		entry["key"] = key
+	}
+
+	options := map[string]interface{}{
+		"recursive": false,
+		"datasets":  []interface{}{entry},
+	}
+
+	log.Printf("[DEBUG] Unlocking TrueNAS dataset: %s", id)
+
+	var result datasetUnlockResult
+
+	if err := c.invokeJob(ctx, "pool.dataset.unlock", []interface{}{id, options}, &result); err != nil {
+		return err
+	}
+
+	if len(result.Failed) > 0 {
+		failures := make([]string, 0, len(result.Failed))
+
+		for name, f := range result.Failed {
+			failures = append(failures, fmt.Sprintf("%s: %s", name, f.Error))
+		}
+
+		sort.Strings(failures)
+
+		return fmt.Errorf("failed to unlock %s", strings.Join(failures, ", "))
+	}
+
+	log.Printf("[INFO] TrueNAS dataset (%s) unlocked", id)
+
+	return nil
+}
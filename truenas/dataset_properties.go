@@ -0,0 +1,374 @@
+package truenas
+
+import (
+	"encoding/json"
+	"fmt"
+	api "github.com/dariusbakunas/truenas-go-sdk"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// propertyInherit is accepted by inheritable properties, property is then reset to value of parent dataset
+const propertyInherit = "inherit"
+
+var aclTypes = []string{"off", "nfsv4", "posix"}
+var cacheModes = []string{"all", "none", "metadata"}
+var dnodeSizes = []string{"legacy", "auto", "1k", "2k", "4k", "8k", "16k"}
+var logBiasModes = []string{"latency", "throughput"}
+var snapDevModes = []string{"hidden", "visible"}
+var specialSmallBlockSizes = []string{"0", "512", "1K", "2K", "4K", "8K", "16K", "32K", "64K", "128K", "256K", "512K", "1M"}
+var volModes = []string{"default", "full", "geom", "dev", "none"}
+var xattrModes = []string{"on", "sa"}
+
+// userPropertyKey matches ZFS user property names, namespace is separated from name by colon, eg. org:owner
+var userPropertyKey = regexp.MustCompile(`^[a-z0-9_.-]+:[a-z0-9_.:-]+$`)
+
+// datasetInheritableProperties maps truenas_dataset attributes, that are part of SDK dataset models, to
+// inheritable ZFS properties
+var datasetInheritableProperties = map[string]string{
+	"acl_mode":      "aclmode",
+	"atime":         "atime",
+	"compression":   "compression",
+	"copies":        "copies",
+	"deduplication": "deduplication",
+	"exec":          "exec",
+	"readonly":      "readonly",
+	"record_size":   "recordsize",
+	"snap_dir":      "snapdir",
+	"sync":          "sync",
+}
+
+// datasetExtraProperties maps truenas_dataset attributes to ZFS properties, that are not part of SDK
+// dataset models and are sent and read as additional properties
+var datasetExtraProperties = map[string]string{
+	"acl_type":                 "acltype",
+	"checksum":                 "checksum",
+	"dnode_size":               "dnodesize",
+	"log_bias":                 "logbias",
+	"primary_cache":            "primarycache",
+	"secondary_cache":          "secondarycache",
+	"special_small_block_size": "special_small_block_size",
+	"xattr":                    "xattr",
+}
+
+// zvolExtraProperties maps truenas_zvol attributes to ZFS properties, same as datasetExtraProperties
+var zvolExtraProperties = map[string]string{
+	"checksum":        "checksum",
+	"log_bias":        "logbias",
+	"primary_cache":   "primarycache",
+	"secondary_cache": "secondarycache",
+	"snap_dev":        "snapdev",
+	"vol_mode":        "volmode",
+}
+
+// withInherit returns allowed property values along with inherit
+func withInherit(values []string) []string {
+	return append(append([]string{}, values...), propertyInherit)
+}
+
+// expandDatasetProperties returns additional properties for pool.dataset.create or pool.dataset.update,
+// see expandInheritableProperty
+func expandDatasetProperties(d *schema.ResourceData, properties map[string]string, update bool) map[string]interface{} {
+	params := make(map[string]interface{})
+
+	for attr, name := range properties {
+		value := expandInheritableProperty(d, attr, update)
+
+		switch {
+		case value == nil:
+		case attr == "special_small_block_size" && *value != strings.ToUpper(propertyInherit):
+			params[name] = parseBlockSize(*value)
+		default:
+			params[name] = *value
+		}
+	}
+
+	return params
+}
+
+// expandInheritableProperty returns uppercase property value, only changed properties are sent on update, so that
+// inherited values do not become local. Inherit and properties removed from config are sent as INHERIT on update,
+// nil is returned for properties that are left as they are
+func expandInheritableProperty(d *schema.ResourceData, attr string, update bool) *string {
+	if update && inheritRemovedProperty(d, attr) {
+		return getStringPtr(strings.ToUpper(propertyInherit))
+	}
+
+	if update && !d.HasChange(attr) {
+		return nil
+	}
+
+	value, ok := d.GetOk(attr)
+
+	if !ok || (value.(string) == propertyInherit && !update) {
+		return nil
+	}
+
+	return getStringPtr(strings.ToUpper(value.(string)))
+}
+
+// inheritRemovedProperty returns true if attribute was removed from config, while property was set locally,
+// customizeDiffInheritRemoved plans such attributes as unknown
+func inheritRemovedProperty(d *schema.ResourceData, attr string) bool {
+	config, plan := d.GetRawConfig(), d.GetRawPlan()
+
+	if d.GetRawState().IsNull() || config.IsNull() || plan.IsNull() || !plan.IsKnown() {
+		return false
+	}
+
+	return config.GetAttr(attr).IsNull() && !plan.GetAttr(attr).IsKnown()
+}
+
+// customizeDiffInheritRemoved plans inherit for properties that are set locally, but are no longer in config,
+// otherwise optional computed attribute keeps its last value and property is never reset. Inherited value is
+// known only after apply
+func customizeDiffInheritRemoved(d *schema.ResourceDiff, properties map[string]string) error {
+	config := d.GetRawConfig()
+
+	if d.Id() == "" || config.IsNull() || !config.IsKnown() {
+		return nil
+	}
+
+	sources := d.Get("property_sources").(map[string]interface{})
+
+	for attr, name := range properties {
+		if !config.GetAttr(attr).IsNull() || sources[name] != "local" {
+			continue
+		}
+
+		if err := d.SetNewComputed(attr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flattenDatasetProperties sets properties mapped by datasetExtraProperties or zvolExtraProperties
+func flattenDatasetProperties(d *schema.ResourceData, properties map[string]string, resp *api.Dataset) {
+	props := datasetCompositeProperties(resp)
+
+	for attr, name := range properties {
+		prop, ok := props[name]
+
+		if !ok {
+			continue
+		}
+
+		value := strings.ToLower(derefString(prop.Value))
+
+		if attr == "special_small_block_size" {
+			value = formatBlockSize(prop.Rawvalue)
+		}
+
+		setInheritableProperty(d, attr, &prop, value)
+	}
+
+	sources := make(map[string]interface{}, len(props))
+
+	for name, prop := range props {
+		if prop.Source != nil {
+			sources[name] = strings.ToLower(*prop.Source)
+		}
+	}
+
+	d.Set("property_sources", sources)
+}
+
+// setInheritableProperty keeps inherit in state while property is not set locally, so that inherited
+// value does not show up as a diff
+func setInheritableProperty(d *schema.ResourceData, attr string, prop *api.CompositeValue, value string) {
+	if d.Get(attr).(string) == propertyInherit && !strings.EqualFold(derefString(prop.Source), "LOCAL") {
+		return
+	}
+
+	d.Set(attr, value)
+}
+
+// datasetCompositeProperties returns ZFS properties reported by pool.dataset.query keyed by property name,
+// including properties that are not part of SDK dataset model
+func datasetCompositeProperties(resp *api.Dataset) map[string]api.CompositeValue {
+	props := make(map[string]api.CompositeValue)
+
+	b, err := json.Marshal(resp)
+
+	if err != nil {
+		return props
+	}
+
+	var fields map[string]json.RawMessage
+
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return props
+	}
+
+	for name, raw := range fields {
+		var prop api.CompositeValue
+
+		// plain fields, eg. mountpoint, are not properties
+		if json.Unmarshal(raw, &prop) == nil && prop.Source != nil {
+			props[name] = prop
+		}
+	}
+
+	return props
+}
+
+// parseBlockSize converts block size, eg. 64K, to bytes
+func parseBlockSize(size string) int64 {
+	multiplier := int64(1)
+
+	switch {
+	case strings.HasSuffix(size, "K"):
+		multiplier = 1024
+	case strings.HasSuffix(size, "M"):
+		multiplier = 1024 * 1024
+	}
+
+	n, _ := strconv.ParseInt(strings.TrimRight(size, "KM"), 10, 64)
+
+	return n * multiplier
+}
+
+// formatBlockSize converts block size in bytes to the format used by specialSmallBlockSizes
+func formatBlockSize(raw string) string {
+	n, err := strconv.ParseInt(raw, 10, 64)
+
+	switch {
+	case err != nil:
+		return raw
+	case n >= 1024*1024 && n%(1024*1024) == 0:
+		return strconv.FormatInt(n/(1024*1024), 10) + "M"
+	case n >= 1024 && n%1024 == 0:
+		return strconv.FormatInt(n/1024, 10) + "K"
+	}
+
+	return strconv.FormatInt(n, 10)
+}
+
+// validateUserProperties checks user_properties keys, ZFS rejects names without namespace and values
+// longer than 8191 bytes
+func validateUserProperties(v interface{}, k string) ([]string, []error) {
+	var errs []error
+
+	for key, value := range v.(map[string]interface{}) {
+		if !userPropertyKey.MatchString(key) || len(key) > 255 {
+			errs = append(errs, fmt.Errorf("invalid %s key %q, expected format: namespace:name with lowercase letters, numbers, '-', '.', '_' or ':'", k, key))
+		}
+
+		if len(value.(string)) > 8191 {
+			errs = append(errs, fmt.Errorf("%s value of %q is longer than 8191 bytes", k, key))
+		}
+	}
+
+	return nil, errs
+}
+
+// expandUserProperties returns user_properties for pool.dataset.create
+func expandUserProperties(d *schema.ResourceData) []interface{} {
+	props := d.Get("user_properties").(map[string]interface{})
+	params := make([]interface{}, 0, len(props))
+
+	for _, key := range sortedKeys(props) {
+		params = append(params, map[string]interface{}{"key": key, "value": props[key]})
+	}
+
+	return params
+}
+
+// expandUserPropertiesUpdate returns user_properties_update for pool.dataset.update, only properties
+// declared in config are changed and properties removed from config are removed from dataset
+func expandUserPropertiesUpdate(d *schema.ResourceData) []interface{} {
+	if !d.HasChange("user_properties") {
+		return nil
+	}
+
+	o, n := d.GetChange("user_properties")
+	oldProps, newProps := o.(map[string]interface{}), n.(map[string]interface{})
+
+	var params []interface{}
+
+	for _, key := range sortedKeys(oldProps) {
+		if _, ok := newProps[key]; !ok {
+			params = append(params, map[string]interface{}{"key": key, "remove": true})
+		}
+	}
+
+	for _, key := range sortedKeys(newProps) {
+		if value, ok := oldProps[key]; !ok || value != newProps[key] {
+			params = append(params, map[string]interface{}{"key": key, "value": newProps[key]})
+		}
+	}
+
+	return params
+}
+
+// flattenUserProperties sets user_properties managed by resource, properties added outside of terraform are ignored,
+// managed properties that are gone or only inherited from parent are dropped from state, so that they are set again
+func flattenUserProperties(d *schema.ResourceData, resp *api.Dataset) {
+	all := datasetUserProperties(resp, true)
+	props := make(map[string]interface{})
+
+	for key := range d.Get("user_properties").(map[string]interface{}) {
+		if value, ok := all[key]; ok {
+			props[key] = value
+		}
+	}
+
+	d.Set("user_properties", props)
+}
+
+// datasetUserProperties returns user properties reported by pool.dataset.query, inherited ones are
+// skipped if local is set
+func datasetUserProperties(resp *api.Dataset, local bool) map[string]interface{} {
+	props := make(map[string]interface{})
+
+	raw, ok := resp.AdditionalProperties["user_properties"]
+
+	if !ok {
+		return props
+	}
+
+	b, err := json.Marshal(raw)
+
+	if err != nil {
+		return props
+	}
+
+	var values map[string]api.CompositeValue
+
+	if err := json.Unmarshal(b, &values); err != nil {
+		return props
+	}
+
+	for key, value := range values {
+		if local && !isLocalPropertySource(derefString(value.Source)) {
+			continue
+		}
+
+		props[key] = derefString(value.Value)
+	}
+
+	return props
+}
+
+// isLocalPropertySource checks if property is set on dataset itself, received properties are set by zfs receive
+func isLocalPropertySource(source string) bool {
+	return strings.EqualFold(source, "LOCAL") || strings.EqualFold(source, "RECEIVED")
+}
+
+// sortedKeys returns map keys in order, so that requests do not change between runs
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,42 @@
+package truenas
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_parseBlockSize(t *testing.T) {
+	testcases := []struct {
+		size     string
+		expected int64
+	}{
+		{size: "0", expected: 0},
+		{size: "512", expected: 512},
+		{size: "64K", expected: 65536},
+		{size: "1M", expected: 1048576},
+	}
+
+	for _, c := range testcases {
+		assert.Equal(t, c.expected, parseBlockSize(c.size), c.size)
+	}
+}
+
+func Test_formatBlockSize(t *testing.T) {
+	for _, size := range specialSmallBlockSizes {
+		assert.Equal(t, size, formatBlockSize(fmt.Sprint(parseBlockSize(size))), size)
+	}
+
+	assert.Equal(t, "1536", formatBlockSize("1536"))
+	assert.Equal(t, "64K", formatBlockSize("64K"))
+}
+
+func Test_validateUserProperties(t *testing.T) {
+	_, errs := validateUserProperties(map[string]interface{}{"org:owner": "ops", "com.example:backup-tier": "gold", "org:a:b": ""}, "user_properties")
+	assert.Empty(t, errs)
+
+	for _, key := range []string{"owner", ":owner", "org:", "Org:owner", "org:owner name"} {
+		_, errs := validateUserProperties(map[string]interface{}{key: "ops"}, "user_properties")
+		assert.NotEmpty(t, errs, key)
+	}
+}
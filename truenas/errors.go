@@ -0,0 +1,307 @@
+package truenas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	api "github.com/dariusbakunas/truenas-go-sdk"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// errno values reported by TrueNAS middleware, see errno(2)
+const (
+	errnoENOENT = 2
+	errnoEBUSY  = 16
+	errnoEEXIST = 17
+	errnoEINVAL = 22
+)
+
+var errnoNames = map[int]string{
+	errnoENOENT: "ENOENT",
+	errnoEBUSY:  "EBUSY",
+	errnoEEXIST: "EEXIST",
+	errnoEINVAL: "EINVAL",
+}
+
+// middleware sometimes embeds errno name into the message itself, eg. "[ENOENT] Dataset not found"
+var errnoMessagePrefix = regexp.MustCompile(`^\[(E[A-Z]+)\]`)
+
+// apiAttrMap maps middleware method argument names (without method prefix)
+// to resource attribute names, eg. "recordsize" -> "record_size"
+type apiAttrMap map[string]string
+
+// apiFieldError is a single validation error reported for a middleware method argument
+type apiFieldError struct {
+	Message string `json:"message"`
+	Errno   int    `json:"errno"`
+}
+
+// apiError is a decoded TrueNAS middleware error response. Middleware responds with either
+// {"message": "...", "errno": 2} for call errors or with validation errors keyed by
+// method argument: {"vm_update.name": [{"message": "...", "errno": 22}]}
+type apiError struct {
+	Status  string
+	Message string
+	Errno   int
+	Fields  map[string][]apiFieldError
+	Body    []byte
+}
+
+func (e *apiError) Error() string {
+	msg := e.Message
+
+	if msg == "" && len(e.Fields) > 0 {
+		keys := e.fieldKeys()
+		msgs := make([]string, 0, len(keys))
+
+		for _, key := range keys {
+			for _, fe := range e.Fields[key] {
+				msgs = append(msgs, fmt.Sprintf("%s: %s", key, fe.Message))
+			}
+		}
+
+		msg = strings.Join(msgs, "; ")
+	}
+
+	if msg == "" {
+		return e.Status
+	}
+
+	if name, ok := errnoNames[e.Errno]; ok && !errnoMessagePrefix.MatchString(msg) {
+		msg = fmt.Sprintf("[%s] %s", name, msg)
+	}
+
+	if e.Status == "" {
+		return msg
+	}
+
+	return fmt.Sprintf("%s: %s", e.Status, msg)
+}
+
+// hasErrno checks if either call error or any of validation errors carries given errno
+func (e *apiError) hasErrno(errno int) bool {
+	if e.Errno == errno {
+		return true
+	}
+
+	for _, errs := range e.Fields {
+		for _, fe := range errs {
+			if fe.Errno == errno {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (e *apiError) fieldKeys() []string {
+	keys := make([]string, 0, len(e.Fields))
+
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// newAPIError decodes error returned by TrueNAS API client, returns nil if err is nil
+func newAPIError(err error) *apiError {
+	if err == nil {
+		return nil
+	}
+
+	var decoded *apiError
+
+	if errors.As(err, &decoded) {
+		return decoded
+	}
+
+	var sdkErr *api.GenericOpenAPIError
+
+	if !errors.As(err, &sdkErr) {
+		return &apiError{Message: err.Error()}
+	}
+
+	return decodeAPIErrorBody(sdkErr.Error(), sdkErr.Body())
+}
+
+func decodeAPIErrorBody(status string, body []byte) *apiError {
+	e := &apiError{
+		Status: status,
+		Body:   body,
+	}
+
+	if len(body) == 0 {
+		return e
+	}
+
+	var raw map[string]json.RawMessage
+
+	if err := json.Unmarshal(body, &raw); err != nil {
+		// not a JSON object, middleware might have responded with a plain string
+		var msg string
+		if err := json.Unmarshal(body, &msg); err == nil {
+			e.Message = msg
+		} else {
+			e.Message = strings.TrimSpace(string(body))
+		}
+	} else if _, ok := raw["message"]; ok {
+		var callErr apiFieldError
+
+		if err := json.Unmarshal(body, &callErr); err == nil {
+			e.Message = callErr.Message
+			e.Errno = callErr.Errno
+		} else {
+			e.Message = strings.TrimSpace(string(body))
+		}
+	} else {
+		e.Fields = make(map[string][]apiFieldError)
+
+		for key, val := range raw {
+			var errs []apiFieldError
+
+			if err := json.Unmarshal(val, &errs); err != nil {
+				continue
+			}
+
+			e.Fields[key] = errs
+		}
+	}
+
+	if e.Errno == 0 {
+		e.Errno = errnoFromMessage(e.Message)
+	}
+
+	return e
+}
+
+// errnoFromMessage extracts errno from messages like "[ENOENT] Dataset not found"
+func errnoFromMessage(msg string) int {
+	m := errnoMessagePrefix.FindStringSubmatch(msg)
+
+	if m == nil {
+		return 0
+	}
+
+	for errno, name := range errnoNames {
+		if name == m[1] {
+			return errno
+		}
+	}
+
+	return 0
+}
+
+// isAPIErrno reports whether err is TrueNAS API error carrying given errno
+func isAPIErrno(err error, errno int) bool {
+	if e := newAPIError(err); e != nil {
+		return e.hasErrno(errno)
+	}
+
+	return false
+}
+
+// isNotFoundError checks if API call failed because requested object does not exist,
+// middleware responds either with HTTP 404 or with ENOENT error (422). resp might be nil
+// if request failed before getting any response
+func isNotFoundError(resp *http.Response, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return true
+	}
+
+	return isAPIErrno(err, errnoENOENT)
+}
+
+// apiErrorDiags converts TrueNAS API error into diagnostics. Validation errors are reported
+// one per argument, with attribute path set when argument can be mapped using attrs.
+// Failed jobs are reported with middleware exception as diagnostic detail
+func apiErrorDiags(err error, summary string, attrs apiAttrMap) diag.Diagnostics {
+	var jobErr *jobError
+
+	if errors.As(err, &jobErr) && len(jobErr.Fields) == 0 {
+		return diag.Diagnostics{
+			diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("%s: %s", summary, jobErr),
+				Detail:   jobErr.Exception,
+			},
+		}
+	}
+
+	var e *apiError
+
+	if jobErr != nil {
+		e = &apiError{Fields: jobErr.Fields}
+	} else {
+		e = newAPIError(err)
+	}
+
+	if e == nil {
+		return nil
+	}
+
+	if len(e.Fields) == 0 {
+		return diag.Diagnostics{
+			diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("%s: %s", summary, e),
+			},
+		}
+	}
+
+	var diags diag.Diagnostics
+
+	for _, key := range e.fieldKeys() {
+		attr, ok := attrs.lookup(key)
+
+		for _, fe := range e.Fields[key] {
+			d := diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("%s: %s", summary, fe.Message),
+				Detail:   fmt.Sprintf("%s: %s", key, fe.Message),
+			}
+
+			if ok {
+				d.AttributePath = cty.GetAttrPath(attr)
+			}
+
+			diags = append(diags, d)
+		}
+	}
+
+	return diags
+}
+
+// lookup resolves middleware validation error key, eg. "pool_dataset_create.encryption_options.passphrase"
+// to resource attribute, method prefix is dropped and the longest matching argument path wins
+func (m apiAttrMap) lookup(key string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+
+	parts := strings.Split(key, ".")
+
+	if len(parts) > 1 {
+		// first segment is always method name, eg. vm_update
+		parts = parts[1:]
+	}
+
+	for i := len(parts); i > 0; i-- {
+		if attr, ok := m[strings.Join(parts[:i], ".")]; ok {
+			return attr, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,137 @@
+package truenas
+
+import (
+	"errors"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_decodeAPIErrorBody(t *testing.T) {
+	testcases := []struct {
+		body     string
+		expected *apiError
+	}{
+		{
+			body: `{"message": "Dataset Tank/test does not exist", "errno": 2}`,
+			expected: &apiError{
+				Status:  "422 Unprocessable Entity",
+				Message: "Dataset Tank/test does not exist",
+				Errno:   errnoENOENT,
+			},
+		},
+		{
+			body: `{"vm_update.name": [{"message": "Only alphanumeric characters are allowed.", "errno": 22}]}`,
+			expected: &apiError{
+				Status: "422 Unprocessable Entity",
+				Fields: map[string][]apiFieldError{
+					"vm_update.name": {{Message: "Only alphanumeric characters are allowed.", Errno: errnoEINVAL}},
+				},
+			},
+		},
+		{
+			body: `"[EBUSY] Dataset is busy"`,
+			expected: &apiError{
+				Status:  "422 Unprocessable Entity",
+				Message: "[EBUSY] Dataset is busy",
+				Errno:   errnoEBUSY,
+			},
+		},
+		{
+			body: `Internal Server Error`,
+			expected: &apiError{
+				Status:  "422 Unprocessable Entity",
+				Message: "Internal Server Error",
+			},
+		},
+	}
+
+	for _, c := range testcases {
+		actual := decodeAPIErrorBody("422 Unprocessable Entity", []byte(c.body))
+		c.expected.Body = []byte(c.body)
+		assert.Equal(t, c.expected, actual)
+	}
+}
+
+func Test_apiErrorHasErrno(t *testing.T) {
+	e := decodeAPIErrorBody("422 Unprocessable Entity", []byte(`{"pool_dataset_create.name": [{"message": "Path Tank/test already exists", "errno": 17}]}`))
+
+	assert.True(t, e.hasErrno(errnoEEXIST))
+	assert.False(t, e.hasErrno(errnoENOENT))
+	assert.True(t, isAPIErrno(e, errnoEEXIST))
+	assert.False(t, isAPIErrno(errors.New("connection refused"), errnoEEXIST))
+	assert.False(t, isAPIErrno(nil, errnoEEXIST))
+}
+
+func Test_isNotFoundError(t *testing.T) {
+	enoent := decodeAPIErrorBody("422 Unprocessable Entity", []byte(`{"message": "VM 12 does not exist", "errno": 2}`))
+	einval := decodeAPIErrorBody("422 Unprocessable Entity", []byte(`{"message": "Invalid request", "errno": 22}`))
+
+	assert.True(t, isNotFoundError(&http.Response{StatusCode: http.StatusNotFound}, errors.New("404 Not Found")))
+	assert.True(t, isNotFoundError(&http.Response{StatusCode: http.StatusUnprocessableEntity}, enoent))
+	assert.False(t, isNotFoundError(&http.Response{StatusCode: http.StatusUnprocessableEntity}, einval))
+	assert.False(t, isNotFoundError(nil, errors.New("connection refused")))
+	assert.False(t, isNotFoundError(&http.Response{StatusCode: http.StatusOK}, nil))
+}
+
+func Test_apiAttrMapLookup(t *testing.T) {
+	testcases := []struct {
+		key      string
+		expected string
+		found    bool
+	}{
+		{key: "pool_dataset_create.recordsize", expected: "record_size", found: true},
+		{key: "pool_dataset_create.encryption_options.passphrase", expected: "passphrase", found: true},
+		{key: "vm_update.devices.0.attributes.path", expected: "device", found: true},
+		{key: "pool_dataset_create.unknown", found: false},
+		{key: "recordsize", expected: "record_size", found: true},
+	}
+
+	attrs := apiAttrMap{
+		"recordsize":                    "record_size",
+		"encryption_options.passphrase": "passphrase",
+		"devices":                       "device",
+	}
+
+	for _, c := range testcases {
+		actual, ok := attrs.lookup(c.key)
+		assert.Equal(t, c.found, ok, c.key)
+		assert.Equal(t, c.expected, actual, c.key)
+	}
+}
+
+func Test_apiErrorDiags(t *testing.T) {
+	e := decodeAPIErrorBody("422 Unprocessable Entity", []byte(`{
+		"vm_update.name": [{"message": "Only alphanumeric characters are allowed.", "errno": 22}],
+		"vm_update.cpu_mode": [{"message": "Invalid CPU mode", "errno": 22}]
+	}`))
+
+	diags := apiErrorDiags(e, "error updating VM", vmAPIAttrs)
+
+	assert.Equal(t, diag.Diagnostics{
+		{
+			Severity: diag.Error,
+			Summary:  "error updating VM: Invalid CPU mode",
+			Detail:   "vm_update.cpu_mode: Invalid CPU mode",
+		},
+		{
+			Severity:      diag.Error,
+			Summary:       "error updating VM: Only alphanumeric characters are allowed.",
+			Detail:        "vm_update.name: Only alphanumeric characters are allowed.",
+			AttributePath: cty.GetAttrPath("name"),
+		},
+	}, diags)
+
+	diags = apiErrorDiags(decodeAPIErrorBody("422 Unprocessable Entity", []byte(`{"message": "Dataset is busy", "errno": 16}`)), "error deleting dataset", nil)
+
+	assert.Equal(t, diag.Diagnostics{
+		{
+			Severity: diag.Error,
+			Summary:  "error deleting dataset: 422 Unprocessable Entity: [EBUSY] Dataset is busy",
+		},
+	}, diags)
+
+	assert.Nil(t, apiErrorDiags(nil, "error", nil))
+}
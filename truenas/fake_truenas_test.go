@@ -0,0 +1,2477 @@
+package truenas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	fakeAPIPrefix = "/api/v2.0/"
+	fakePoolName  = "Tank"
+)
+
+// fakeDisks are disks attached to fakeTrueNAS, fakePoolName pool is a mirror of the first two
+var fakeDisks = []string{"sda", "sdb", "sdc", "sdd", "sde", "sdf", "sdg", "sdh"}
+
+// fakeCollections are REST collections with integer ids served by fakeTrueNAS, each with item defaults
+var fakeCollections = map[string]func() map[string]interface{}{
+	"cronjob": func() map[string]interface{} {
+		return map[string]interface{}{
+			"description": "",
+			"enabled":     true,
+			"stdout":      true,
+			"stderr":      false,
+			"schedule": map[string]interface{}{
+				"minute": "00", "hour": "*", "dom": "*", "month": "*", "dow": "*",
+			},
+		}
+	},
+	"sharing/nfs": func() map[string]interface{} {
+		return map[string]interface{}{
+			"comment":  "",
+			"hosts":    []interface{}{},
+			"alldirs":  false,
+			"ro":       false,
+			"quiet":    false,
+			"security": []interface{}{},
+			"enabled":  true,
+			"locked":   false,
+			"paths":    []interface{}{},
+			"networks": []interface{}{},
+		}
+	},
+	"sharing/smb": func() map[string]interface{} {
+		return map[string]interface{}{
+			"path_suffix":        "",
+			"purpose":            "NO_PRESET",
+			"home":               false,
+			"timemachine":        false,
+			"comment":            "",
+			"ro":                 false,
+			"browsable":          true,
+			"recyclebin":         false,
+			"shadowcopy":         true,
+			"guestok":            false,
+			"abe":                false,
+			"hostsallow":         []interface{}{},
+			"hostsdeny":          []interface{}{},
+			"aapl_name_mangling": false,
+			"acl":                true,
+			"durablehandle":      true,
+			"streams":            true,
+			"fsrvp":              false,
+			"auxsmbconf":         "",
+			"enabled":            true,
+			"locked":             false,
+		}
+	},
+	"pool/snapshottask": func() map[string]interface{} {
+		return map[string]interface{}{
+			"recursive":      false,
+			"exclude":        []interface{}{},
+			"lifetime_value": 2,
+			"lifetime_unit":  "WEEK",
+			"naming_schema":  "auto-%Y-%m-%d_%H-%M",
+			"allow_empty":    true,
+			"enabled":        true,
+			"schedule": map[string]interface{}{
+				"minute": "00", "hour": "*", "dom": "*", "month": "*", "dow": "*", "begin": "00:00", "end": "23:59",
+			},
+			"state": map[string]interface{}{"state": "PENDING"},
+		}
+	},
+	"replication": func() map[string]interface{} {
+		return map[string]interface{}{
+			"ssh_credentials":         nil,
+			"sudo":                    false,
+			"recursive":               false,
+			"exclude":                 []interface{}{},
+			"properties":              true,
+			"properties_exclude":      []interface{}{},
+			"properties_override":     map[string]interface{}{},
+			"replicate":               false,
+			"encryption":              false,
+			"periodic_snapshot_tasks": []interface{}{},
+			"naming_schema":           []interface{}{},
+			"auto":                    true,
+			"schedule":                nil,
+			"restrict_schedule":       nil,
+			"readonly":                "SET",
+			"retention_policy":        "NONE",
+			"compression":             nil,
+			"speed_limit":             nil,
+			"large_block":             true,
+			"compressed":              true,
+			"retries":                 5,
+			"enabled":                 true,
+			"state":                   map[string]interface{}{"state": "PENDING"},
+		}
+	},
+	"vm": func() map[string]interface{} {
+		return map[string]interface{}{
+			"description":      "",
+			"vcpus":            1,
+			"cores":            1,
+			"threads":          1,
+			"memory":           536870912,
+			"autostart":        true,
+			"time":             "LOCAL",
+			"bootloader":       "UEFI",
+			"shutdown_timeout": 90,
+			"devices":          []interface{}{},
+			"status":           map[string]interface{}{"state": "STOPPED", "domain_state": "SHUTOFF"},
+		}
+	},
+}
+
+// fakeDatasetDefaults are ZFS properties of newly created datasets, keyed by dataset type
+var fakeDatasetDefaults = map[string]map[string]string{
+	"FILESYSTEM": {
+		"aclmode":                  "PASSTHROUGH",
+		"acltype":                  "POSIX",
+		"atime":                    "ON",
+		"casesensitivity":          "SENSITIVE",
+		"exec":                     "ON",
+		"quota_critical":           "0",
+		"quota_warning":            "0",
+		"quota":                    "0",
+		"refquota":                 "0",
+		"readonly":                 "OFF",
+		"recordsize":               "128K",
+		"snapdir":                  "HIDDEN",
+		"dnodesize":                "LEGACY",
+		"special_small_block_size": "0",
+		"xattr":                    "SA",
+	},
+	"VOLUME": {
+		"readonly":     "OFF",
+		"snapdev":      "HIDDEN",
+		"volblocksize": "16K",
+		"volmode":      "DEFAULT",
+		"volsize":      "0",
+	},
+}
+
+// fakeDatasetCommonDefaults are ZFS properties shared by filesystems and volumes
+var fakeDatasetCommonDefaults = map[string]string{
+	"checksum":       "ON",
+	"comments":       "",
+	"compression":    "LZ4",
+	"copies":         "1",
+	"deduplication":  "OFF",
+	"logbias":        "LATENCY",
+	"managedby":      "",
+	"pbkdf2iters":    "0",
+	"primarycache":   "ALL",
+	"refreservation": "0",
+	"reservation":    "0",
+	"secondarycache": "ALL",
+	"sync":           "STANDARD",
+}
+
+// fakeDatasetLocalProps are properties that are not inherited from parent dataset, they are always reported
+// with LOCAL source
+var fakeDatasetLocalProps = map[string]bool{
+	"casesensitivity": true,
+	"comments":        true,
+	"managedby":       true,
+	"pbkdf2iters":     true,
+	"quota":           true,
+	"quota_critical":  true,
+	"quota_warning":   true,
+	"refquota":        true,
+	"refreservation":  true,
+	"reservation":     true,
+	"volblocksize":    true,
+	"volsize":         true,
+}
+
+// fakeDatasetIgnoredParams are pool.dataset.create/update params that are not stored as properties
+var fakeDatasetIgnoredParams = map[string]bool{
+	"name":                   true,
+	"type":                   true,
+	"encryption":             true,
+	"encryption_options":     true,
+	"inherit_encryption":     true,
+	"force_size":             true,
+	"share_type":             true,
+	"user_properties":        true,
+	"user_properties_update": true,
+}
+
+// fakeFault makes fakeTrueNAS delay or fail matching requests
+type fakeFault struct {
+	// Method matches request HTTP method, empty matches any
+	Method string
+	// Path matches request path relative to API root by prefix, eg. "sharing/nfs/id/1"
+	Path string
+	// Status is response status code, request is served as usual if not set
+	Status int
+	// Body is response body sent along with Status
+	Body string
+	// Delay is applied before request is served
+	Delay time.Duration
+	// Times limits how many requests fault applies to, 0 means all
+	Times int
+}
+
+// fakeUsers and fakeGroups are local accounts known to fakeTrueNAS, keyed by name
+var fakeUsers = map[string]int{"root": 0, "apps": 568, "nobody": 65534}
+var fakeGroups = map[string]int{"root": 0, "apps": 568, "nogroup": 65534}
+
+type fakeDataset struct {
+	Type  string
+	Props map[string]string
+	// Local are inheritable properties set on the dataset itself, others are inherited or default
+	Local map[string]bool
+	// User are user properties set on the dataset itself, eg. org:owner
+	User map[string]string
+	// UID, GID and Mode are mount point owner and permissions, ACL is nil for trivial ACL
+	UID  int
+	GID  int
+	Mode int
+	ACL  []interface{}
+	// Quotas are user and group quotas keyed by quota type and numeric ID, eg. USEROBJ:568
+	Quotas map[string]int64
+	// EncryptionRoot is empty for unencrypted datasets, key format, key and lock state are kept by encryption roots
+	EncryptionRoot string
+	Algorithm      string
+	KeyFormat      string
+	Key            string
+	Locked         bool
+	// Origin is snapshot the dataset was cloned from
+	Origin string
+	// Rollback is the last snapshot dataset was rolled back to
+	Rollback string
+	// Mountpoint overrides default /mnt/<id> mount point of filesystems
+	Mountpoint string
+}
+
+type fakeSnapshot struct {
+	Created time.Time
+	// Txg is transaction group snapshot was created in, it orders snapshots taken within the same second
+	Txg   int
+	Holds int
+}
+
+// fakeTrueNAS is an in-process stand-in for TrueNAS REST API v2.0 keeping state in memory,
+// it covers endpoints used by provider resources and data sources, so resource lifecycles
+// can be tested with resource.UnitTest without a real system
+type fakeTrueNAS struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	nextID    int
+	datasets  map[string]*fakeDataset
+	snapshots map[string]*fakeSnapshot
+	items     map[string]map[int]map[string]interface{}
+	pools     map[int]map[string]interface{}
+	jobs      map[int]map[string]interface{}
+	faults    []*fakeFault
+}
+
+func newFakeTrueNAS(t *testing.T) *fakeTrueNAS {
+	f := &fakeTrueNAS{
+		nextID: 1,
+		datasets: map[string]*fakeDataset{
+			fakePoolName: newFakeDataset("FILESYSTEM"),
+		},
+		snapshots: make(map[string]*fakeSnapshot),
+		items:     make(map[string]map[int]map[string]interface{}),
+		pools: map[int]map[string]interface{}{
+			1: newFakePool(1, fakePoolName, map[string]interface{}{
+				"data": []interface{}{map[string]interface{}{"type": "MIRROR", "disks": []interface{}{"sda", "sdb"}}},
+			}),
+		},
+		jobs: make(map[int]map[string]interface{}),
+	}
+
+	for name := range fakeCollections {
+		f.items[name] = make(map[int]map[string]interface{})
+	}
+
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+
+	return f
+}
+
+// newFakeDataset returns dataset with default properties, mount point is owned by root with 0755 mode
+func newFakeDataset(datasetType string) *fakeDataset {
+	return &fakeDataset{Type: datasetType, Props: newFakeDatasetProps(datasetType), Local: make(map[string]bool), User: make(map[string]string), Mode: 0o755, Quotas: make(map[string]int64)}
+}
+
+func newFakeDatasetProps(datasetType string) map[string]string {
+	props := make(map[string]string)
+
+	for k, v := range fakeDatasetCommonDefaults {
+		props[k] = v
+	}
+
+	for k, v := range fakeDatasetDefaults[datasetType] {
+		props[k] = v
+	}
+
+	return props
+}
+
+// inject adds fault applied to subsequent requests
+func (f *fakeTrueNAS) inject(fault fakeFault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = append(f.faults, &fault)
+}
+
+// client returns provider client connected to fake server
+func (f *fakeTrueNAS) client() *Client {
+	return newTestClientForURL(f.URL)
+}
+
+// providerConfig returns provider configuration block pointing to fake server
+func (f *fakeTrueNAS) providerConfig() string {
+	return fmt.Sprintf(`
+	provider "truenas" {
+		api_key  = "fake"
+		base_url = "%s/api/v2.0"
+	}
+	`, f.URL)
+}
+
+// websocketProviderConfig returns provider configuration block using websocket transport, calls are
+// passed to fake server REST endpoints the same way restCaller maps them
+func (f *fakeTrueNAS) websocketProviderConfig(t *testing.T) string {
+	c := f.client()
+
+	_, srv := newTestMiddleware(t, func(method string, params []interface{}) (interface{}, map[string]interface{}) {
+		httpMethod, path, body, err := restRequest(method, params)
+
+		if err != nil {
+			return nil, map[string]interface{}{"error": errnoEINVAL, "reason": err.Error()}
+		}
+
+		var result interface{}
+
+		if _, err := c.call(context.Background(), httpMethod, path, body, &result); err != nil {
+			return nil, fakeWebsocketError(err)
+		}
+
+		return result, nil
+	})
+
+	return fmt.Sprintf(`
+	provider "truenas" {
+		api_key       = "test-key"
+		base_url      = "%s/api/v2.0"
+		transport     = "websocket"
+		websocket_url = "ws%s/api/current"
+	}
+	`, f.URL, strings.TrimPrefix(srv.URL, "http"))
+}
+
+// fakeWebsocketError converts fake server REST error into middleware call error
+func fakeWebsocketError(err error) map[string]interface{} {
+	e := newAPIError(err)
+	callErr := map[string]interface{}{"error": e.Errno, "reason": e.Message}
+
+	if len(e.Fields) > 0 {
+		var extra [][]interface{}
+
+		for _, key := range e.fieldKeys() {
+			for _, fe := range e.Fields[key] {
+				extra = append(extra, []interface{}{key, fe.Message, fe.Errno})
+			}
+		}
+
+		callErr["type"] = "VALIDATION"
+		callErr["extra"] = extra
+	}
+
+	return callErr
+}
+
+// fault returns fault matching the request, if any
+func (f *fakeTrueNAS) fault(r *http.Request, p string) *fakeFault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, fault := range f.faults {
+		if (fault.Method != "" && fault.Method != r.Method) || !strings.HasPrefix(p, fault.Path) {
+			continue
+		}
+
+		if fault.Times > 0 {
+			fault.Times--
+
+			if fault.Times == 0 {
+				f.faults = append(f.faults[:i], f.faults[i+1:]...)
+			}
+		}
+
+		return fault
+	}
+
+	return nil
+}
+
+func (f *fakeTrueNAS) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, fakeAPIPrefix) {
+		fakeError(w, http.StatusNotFound, errnoENOENT, "not found")
+		return
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, fakeAPIPrefix)
+
+	if fault := f.fault(r, p); fault != nil {
+		if fault.Delay > 0 {
+			select {
+			case <-time.After(fault.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if fault.Status != 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(fault.Status)
+			w.Write([]byte(fault.Body))
+			return
+		}
+	}
+
+	// most methods accept an object, single non-object params, eg. filesystem.stat path, are kept in raw
+	var raw interface{}
+
+	if r.Body != nil && r.ContentLength != 0 {
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+
+		if err := dec.Decode(&raw); err != nil {
+			fakeError(w, http.StatusBadRequest, errnoEINVAL, fmt.Sprintf("invalid request body: %s", err))
+			return
+		}
+	}
+
+	body, _ := raw.(map[string]interface{})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case p == "system/info":
+		fakeJSON(w, map[string]interface{}{
+			"version":        "TrueNAS-SCALE-22.12.0",
+			"hostname":       "truenas",
+			"model":          "Fake CPU",
+			"cores":          4,
+			"physical_cores": 2,
+			"physmem":        8589934592,
+			"uptime_seconds": 3600.5,
+			"timezone":       "UTC",
+			"ecc_memory":     false,
+		})
+	case p == "system/product_type":
+		fakeJSON(w, productTypeSCALE)
+	case p == "sharing/smb/presets":
+		fakeJSON(w, map[string]interface{}{"NO_PRESET": map[string]interface{}{}, "DEFAULT_SHARE": map[string]interface{}{}})
+	case p == "core/get_jobs":
+		list := make([]interface{}, 0, len(f.jobs))
+
+		for _, j := range f.jobs {
+			if fakeMatchQuery(j, r) {
+				list = append(list, j)
+			}
+		}
+
+		fakeJSON(w, list)
+	case strings.HasPrefix(p, "replication/id/") && strings.HasSuffix(p, "/run"):
+		f.runReplication(w, strings.TrimSuffix(strings.TrimPrefix(p, "replication/id/"), "/run"))
+	case p == "pool" || strings.HasPrefix(p, "pool/id/"):
+		f.servePool(w, r, strings.TrimPrefix(strings.TrimPrefix(p, "pool"), "/id/"), body)
+	case p == "service":
+		fakeJSON(w, fakeServices())
+	case strings.HasPrefix(p, "service/id/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(p, "service/id/"))
+
+		for _, s := range fakeServices() {
+			if s["id"] == id {
+				fakeJSON(w, s)
+				return
+			}
+		}
+
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("service %d does not exist", id))
+	case p == "network/configuration":
+		fakeJSON(w, map[string]interface{}{
+			"id":              1,
+			"hostname":        "truenas",
+			"domain":          "local",
+			"ipv4gateway":     "192.168.1.1",
+			"ipv6gateway":     "",
+			"nameserver1":     "1.1.1.1",
+			"nameserver2":     "",
+			"nameserver3":     "",
+			"httpproxy":       "",
+			"netwait_enabled": false,
+			"netwait_ip":      []interface{}{},
+			"service_announcement": map[string]interface{}{
+				"netbios": false, "mdns": true, "wsd": true,
+			},
+		})
+	case p == "zfs/snapshot" || strings.HasPrefix(p, "zfs/snapshot/id/"):
+		f.serveSnapshot(w, r, strings.TrimPrefix(strings.TrimPrefix(p, "zfs/snapshot"), "/id/"), body)
+	case p == "zfs/snapshot/clone":
+		f.cloneSnapshot(w, body)
+	case p == "zfs/snapshot/rollback":
+		id, _ := body["id"].(string)
+		options, _ := body["options"].(map[string]interface{})
+		f.rollbackSnapshot(w, id, options)
+	case p == "filesystem/stat":
+		path, _ := raw.(string)
+		f.serveFilesystemStat(w, path)
+	case p == "filesystem/getacl":
+		path, _ := body["path"].(string)
+		f.serveFilesystemACL(w, path)
+	case strings.HasPrefix(p, "pool/dataset/id/") && fakeDatasetEncryptionMethods[path.Base(p)]:
+		f.serveDatasetEncryption(w, path.Dir(strings.TrimPrefix(p, "pool/dataset/id/")), path.Base(p), body)
+	case strings.HasPrefix(p, "pool/dataset/id/") && strings.HasSuffix(p, "/permission"):
+		f.setDatasetPermission(w, strings.TrimSuffix(strings.TrimPrefix(p, "pool/dataset/id/"), "/permission"), body)
+	case strings.HasPrefix(p, "pool/dataset/id/") && strings.HasSuffix(p, "/rename"):
+		newName, _ := body["new_name"].(string)
+		f.renameDataset(w, strings.TrimSuffix(strings.TrimPrefix(p, "pool/dataset/id/"), "/rename"), newName)
+	case strings.HasPrefix(p, "pool/dataset/id/") && strings.HasSuffix(p, "/promote"):
+		f.promoteDataset(w, strings.TrimSuffix(strings.TrimPrefix(p, "pool/dataset/id/"), "/promote"))
+	case strings.HasPrefix(p, "pool/dataset/id/") && strings.HasSuffix(p, "/set_quota"):
+		quotas, err := fakeSetQuotaArgs(r.Method, raw)
+
+		if err != nil {
+			fakeValidationError(w, "pool_dataset_set_quota", err)
+			return
+		}
+
+		f.setDatasetQuota(w, strings.TrimSuffix(strings.TrimPrefix(p, "pool/dataset/id/"), "/set_quota"), quotas)
+	case strings.HasPrefix(p, "pool/dataset/id/") && strings.HasSuffix(p, "/get_quota"):
+		quotaType, err := fakeGetQuotaArgs(r.Method, raw)
+
+		if err != nil {
+			fakeValidationError(w, "pool_dataset_get_quota", err)
+			return
+		}
+
+		f.getDatasetQuota(w, strings.TrimSuffix(strings.TrimPrefix(p, "pool/dataset/id/"), "/get_quota"), quotaType)
+	case p == "pool/dataset" || strings.HasPrefix(p, "pool/dataset/id/"):
+		f.serveDataset(w, r, strings.TrimPrefix(strings.TrimPrefix(p, "pool/dataset"), "/id/"), body)
+	default:
+		for name := range fakeCollections {
+			if p == name {
+				f.serveCollection(w, r, name, "", body)
+				return
+			}
+
+			if strings.HasPrefix(p, name+"/id/") {
+				f.serveCollection(w, r, name, strings.TrimPrefix(p, name+"/id/"), body)
+				return
+			}
+		}
+
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s %s is not implemented by fake server", r.Method, p))
+	}
+}
+
+func (f *fakeTrueNAS) serveCollection(w http.ResponseWriter, r *http.Request, name string, idStr string, body map[string]interface{}) {
+	items := f.items[name]
+
+	if idStr == "" {
+		switch r.Method {
+		case http.MethodGet:
+			ids := make([]int, 0, len(items))
+
+			for id := range items {
+				ids = append(ids, id)
+			}
+
+			sort.Ints(ids)
+			list := make([]interface{}, 0, len(ids))
+
+			for _, id := range ids {
+				if fakeMatchQuery(items[id], r) {
+					list = append(list, items[id])
+				}
+			}
+
+			fakeJSON(w, list)
+		case http.MethodPost:
+			item := fakeCollections[name]()
+			item["id"] = f.nextID
+			f.nextID++
+
+			if err := f.updateItem(name, item, body); err != nil {
+				fakeValidationError(w, strings.ReplaceAll(name, "/", "")+"_create", err)
+				return
+			}
+
+			items[item["id"].(int)] = item
+			fakeJSON(w, item)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+
+		return
+	}
+
+	id, _ := strconv.Atoi(idStr)
+	item, ok := items[id]
+
+	if !ok {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s %s does not exist", name, idStr))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		fakeJSON(w, item)
+	case http.MethodPut:
+		if err := f.updateItem(name, item, body); err != nil {
+			fakeValidationError(w, strings.ReplaceAll(name, "/", "")+"_update", err)
+			return
+		}
+
+		fakeJSON(w, item)
+	case http.MethodDelete:
+		delete(items, id)
+		fakeJSON(w, true)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// fakeMatchQuery checks if item matches REST query filters, eg. ?dataset=Tank/data
+func fakeMatchQuery(item map[string]interface{}, r *http.Request) bool {
+	for field, values := range r.URL.Query() {
+		for _, v := range values {
+			if fmt.Sprint(item[field]) != v {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// fakeFieldError is a validation error of a single method argument
+type fakeFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *fakeFieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// updateItem applies create/update params to collection item the way middleware does
+func (f *fakeTrueNAS) updateItem(name string, item map[string]interface{}, params map[string]interface{}) error {
+	for k, v := range params {
+		if k == "id" {
+			continue
+		}
+
+		item[k] = v
+	}
+
+	switch name {
+	case "sharing/nfs":
+		for _, p := range item["paths"].([]interface{}) {
+			if _, ok := f.datasets[strings.TrimPrefix(p.(string), "/mnt/")]; !ok {
+				return &fakeFieldError{"paths", fmt.Sprintf("%s does not exist", p)}
+			}
+		}
+	case "sharing/smb":
+		p, _ := item["path"].(string)
+
+		if _, ok := f.datasets[strings.TrimPrefix(p, "/mnt/")]; !ok {
+			return &fakeFieldError{"path", fmt.Sprintf("%s does not exist", p)}
+		}
+
+		if n, _ := item["name"].(string); n == "" {
+			item["name"] = path.Base(p)
+		}
+
+		if _, ok := item["vuid"]; !ok {
+			item["vuid"] = fmt.Sprintf("00000000-0000-0000-0000-%012d", item["id"])
+		}
+	case "pool/snapshottask":
+		if _, ok := f.datasets[item["dataset"].(string)]; !ok {
+			return &fakeFieldError{"dataset", fmt.Sprintf("%s does not exist", item["dataset"])}
+		}
+	case "replication":
+		return f.updateReplication(item)
+	case "vm":
+		f.updateVMDevices(item)
+	}
+
+	return nil
+}
+
+// updateReplication expands ssh credentials and periodic snapshot tasks into objects,
+// the way replication.query reports them
+func (f *fakeTrueNAS) updateReplication(task map[string]interface{}) error {
+	if id, ok := task["ssh_credentials"].(json.Number); ok {
+		task["ssh_credentials"] = map[string]interface{}{"id": id, "name": fmt.Sprintf("ssh-%s", id), "type": "SSH_CREDENTIALS"}
+	}
+
+	tasks, _ := task["periodic_snapshot_tasks"].([]interface{})
+
+	for i, t := range tasks {
+		id, ok := t.(json.Number)
+
+		if !ok {
+			continue
+		}
+
+		n, _ := id.Int64()
+		snapshotTask, ok := f.items["pool/snapshottask"][int(n)]
+
+		if !ok {
+			return &fakeFieldError{"periodic_snapshot_tasks", fmt.Sprintf("periodic snapshot task %s does not exist", id)}
+		}
+
+		tasks[i] = snapshotTask
+	}
+
+	if task["direction"] == "PUSH" {
+		for _, ds := range task["source_datasets"].([]interface{}) {
+			if _, ok := f.datasets[ds.(string)]; !ok {
+				return &fakeFieldError{"source_datasets", fmt.Sprintf("%s does not exist", ds)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// runReplication starts replication.run job, LOCAL replication creates target dataset
+// and finishes immediately
+func (f *fakeTrueNAS) runReplication(w http.ResponseWriter, idStr string) {
+	id, _ := strconv.Atoi(idStr)
+	task, ok := f.items["replication"][id]
+
+	if !ok {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("replication %s does not exist", idStr))
+		return
+	}
+
+	if task["transport"] != replicationTransportLocal {
+		err := fmt.Errorf("%s transport is not supported by fake server", task["transport"])
+		task["state"] = map[string]interface{}{"state": "ERROR", "error": err.Error()}
+		fakeJSON(w, f.startJob("replication.run", nil, err))
+		return
+	}
+
+	target := task["target_dataset"].(string)
+
+	if _, ok := f.datasets[target]; !ok {
+		f.datasets[target] = newFakeDataset("FILESYSTEM")
+	}
+
+	task["state"] = map[string]interface{}{"state": "FINISHED"}
+	fakeJSON(w, f.startJob("replication.run", nil, nil))
+}
+
+// startJob records job that has already finished, either successfully with result, or failed with err
+func (f *fakeTrueNAS) startJob(method string, result interface{}, err error) int {
+	id := f.nextID
+	f.nextID++
+
+	job := map[string]interface{}{
+		"id":       id,
+		"method":   method,
+		"state":    jobStateSuccess,
+		"progress": map[string]interface{}{"percent": 100},
+		"result":   result,
+	}
+
+	if err != nil {
+		job["state"] = jobStateFailed
+		job["error"] = err.Error()
+	}
+
+	f.jobs[id] = job
+
+	return id
+}
+
+// servePool serves pool endpoints, pool create, update and export run as jobs
+func (f *fakeTrueNAS) servePool(w http.ResponseWriter, r *http.Request, id string, body map[string]interface{}) {
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			ids := make([]int, 0, len(f.pools))
+
+			for id := range f.pools {
+				ids = append(ids, id)
+			}
+
+			sort.Ints(ids)
+			list := make([]interface{}, 0, len(ids))
+
+			for _, id := range ids {
+				if fakeMatchQuery(f.pools[id], r) {
+					list = append(list, f.pools[id])
+				}
+			}
+
+			fakeJSON(w, list)
+		case http.MethodPost:
+			f.createPool(w, body)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+
+		return
+	}
+
+	action := ""
+
+	if i := strings.Index(id, "/"); i >= 0 {
+		id, action = id[:i], id[i+1:]
+	}
+
+	poolID, _ := strconv.Atoi(id)
+	pool, ok := f.pools[poolID]
+
+	if !ok {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("pool %s does not exist", id))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		fakeJSON(w, pool)
+	case r.Method == http.MethodPut && action == "":
+		if topology, ok := body["topology"].(map[string]interface{}); ok {
+			if err := f.validatePoolTopology(topology); err != nil {
+				fakeValidationError(w, "pool_update", err)
+				return
+			}
+
+			addFakePoolTopology(pool, topology)
+		}
+
+		if autotrim, ok := body["autotrim"].(string); ok {
+			pool["autotrim"] = map[string]interface{}{"value": autotrim, "rawvalue": strings.ToLower(autotrim), "source": "LOCAL"}
+		}
+
+		fakeJSON(w, f.startJob("pool.update", pool, nil))
+	case r.Method == http.MethodPost && action == "export":
+		name := pool["name"].(string)
+		delete(f.pools, poolID)
+
+		for ds := range f.datasets {
+			if ds == name || strings.HasPrefix(ds, name+"/") {
+				delete(f.datasets, ds)
+			}
+		}
+
+		fakeJSON(w, f.startJob("pool.export", nil, nil))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeTrueNAS) createPool(w http.ResponseWriter, params map[string]interface{}) {
+	name, _ := params["name"].(string)
+
+	for _, pool := range f.pools {
+		if pool["name"] == name {
+			fakeValidationError(w, "pool_create", &fakeFieldError{"name", fmt.Sprintf("pool %s already exists", name)})
+			return
+		}
+	}
+
+	topology, _ := params["topology"].(map[string]interface{})
+
+	if data, _ := topology["data"].([]interface{}); len(data) == 0 {
+		fakeValidationError(w, "pool_create", &fakeFieldError{"topology", "at least one data vdev is required"})
+		return
+	}
+
+	if err := f.validatePoolTopology(topology); err != nil {
+		fakeValidationError(w, "pool_create", err)
+		return
+	}
+
+	id := 1
+
+	for poolID := range f.pools {
+		if poolID >= id {
+			id = poolID + 1
+		}
+	}
+
+	pool := newFakePool(id, name, topology)
+
+	if params["encryption"] == true {
+		pool["encrypt"] = 1
+	}
+
+	f.pools[id] = pool
+
+	root := newFakeDataset("FILESYSTEM")
+	root.update(map[string]interface{}{"checksum": params["checksum"], "deduplication": params["deduplication"]})
+	f.datasets[name] = root
+
+	fakeJSON(w, f.startJob("pool.create", pool, nil))
+}
+
+// validatePoolTopology checks that vdevs have enough disks and these disks are not used by other pools
+func (f *fakeTrueNAS) validatePoolTopology(topology map[string]interface{}) error {
+	used := make(map[string]bool)
+
+	for _, pool := range f.pools {
+		for _, vdevs := range pool["topology"].(map[string]interface{}) {
+			for _, v := range vdevs.([]interface{}) {
+				vdev := v.(map[string]interface{})
+				used[fmt.Sprint(vdev["disk"])] = true
+
+				for _, child := range vdev["children"].([]interface{}) {
+					used[fmt.Sprint(child.(map[string]interface{})["disk"])] = true
+				}
+			}
+		}
+	}
+
+	minDisks := map[string]int{"STRIPE": 1, "MIRROR": 2, "RAIDZ1": 3, "RAIDZ2": 4, "RAIDZ3": 5}
+	check := func(disk string) error {
+		if !fakeHasDisk(disk) {
+			return &fakeFieldError{"topology", fmt.Sprintf("disk %s does not exist", disk)}
+		}
+
+		if used[disk] {
+			return &fakeFieldError{"topology", fmt.Sprintf("disk %s is in use", disk)}
+		}
+
+		used[disk] = true
+		return nil
+	}
+
+	for class, v := range topology {
+		if class == "spares" {
+			for _, disk := range v.([]interface{}) {
+				if err := check(disk.(string)); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		for _, vd := range v.([]interface{}) {
+			vdev := vd.(map[string]interface{})
+			disks := vdev["disks"].([]interface{})
+
+			if len(disks) < minDisks[vdev["type"].(string)] {
+				return &fakeFieldError{"topology", fmt.Sprintf("%s vdev requires at least %d disks", vdev["type"], minDisks[vdev["type"].(string)])}
+			}
+
+			for _, disk := range disks {
+				if err := check(disk.(string)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func fakeHasDisk(disk string) bool {
+	for _, d := range fakeDisks {
+		if d == disk {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newFakePool returns pool as reported by pool.query, topology is pool.create topology
+func newFakePool(id int, name string, topology map[string]interface{}) map[string]interface{} {
+	pool := map[string]interface{}{
+		"id":            id,
+		"name":          name,
+		"guid":          fmt.Sprintf("%d234567890", id),
+		"path":          "/mnt/" + name,
+		"status":        "ONLINE",
+		"healthy":       true,
+		"is_decrypted":  true,
+		"encrypt":       0,
+		"autotrim":      map[string]interface{}{"value": "OFF", "rawvalue": "off", "source": "DEFAULT"},
+		"size":          int64(id) * 10737418240,
+		"allocated":     int64(id) * 1073741824,
+		"free":          int64(id) * 9663676416,
+		"fragmentation": "1",
+		"scan": map[string]interface{}{
+			"function":   "SCRUB",
+			"state":      "FINISHED",
+			"start_time": map[string]interface{}{"$date": 1670000000000},
+			"end_time":   map[string]interface{}{"$date": 1670000600000},
+			"percentage": 100,
+			"errors":     0,
+		},
+		"topology": map[string]interface{}{
+			"data": []interface{}{}, "log": []interface{}{}, "cache": []interface{}{},
+			"spare": []interface{}{}, "special": []interface{}{}, "dedup": []interface{}{},
+		},
+	}
+
+	addFakePoolTopology(pool, topology)
+
+	return pool
+}
+
+// addFakePoolTopology adds vdevs of pool.create/pool.update topology to pool, STRIPE vdevs
+// are added as single disk vdevs
+func addFakePoolTopology(pool map[string]interface{}, topology map[string]interface{}) {
+	current := pool["topology"].(map[string]interface{})
+	disk := func(name interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"name": fmt.Sprintf("%s2", name), "type": vdevTypeDisk, "disk": name, "device": fmt.Sprintf("%s2", name),
+			"status": "ONLINE", "children": []interface{}{},
+		}
+	}
+
+	for class, v := range topology {
+		if class == "spares" {
+			for _, d := range v.([]interface{}) {
+				current["spare"] = append(current["spare"].([]interface{}), disk(d))
+			}
+
+			continue
+		}
+
+		for _, vd := range v.([]interface{}) {
+			vdev := vd.(map[string]interface{})
+
+			if vdev["type"] == vdevTypeStripe {
+				for _, d := range vdev["disks"].([]interface{}) {
+					current[class] = append(current[class].([]interface{}), disk(d))
+				}
+
+				continue
+			}
+
+			children := make([]interface{}, 0)
+
+			for _, d := range vdev["disks"].([]interface{}) {
+				children = append(children, disk(d))
+			}
+
+			current[class] = append(current[class].([]interface{}), map[string]interface{}{
+				"name":     fmt.Sprintf("%s-%d", strings.ToLower(vdev["type"].(string)), len(current[class].([]interface{}))),
+				"type":     vdev["type"],
+				"disk":     nil,
+				"status":   "ONLINE",
+				"children": children,
+			})
+		}
+	}
+}
+
+// updateVMDevices assigns ids to new VM devices, devices missing from update are removed
+func (f *fakeTrueNAS) updateVMDevices(vm map[string]interface{}) {
+	devices, _ := vm["devices"].([]interface{})
+
+	for i, d := range devices {
+		device := d.(map[string]interface{})
+
+		if device["id"] == nil {
+			device["id"] = f.nextID
+			f.nextID++
+		}
+
+		if device["order"] == nil {
+			device["order"] = 1000 + i
+		}
+
+		device["vm"] = vm["id"]
+	}
+}
+
+func (f *fakeTrueNAS) serveDataset(w http.ResponseWriter, r *http.Request, id string, body map[string]interface{}) {
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			ids := make([]string, 0, len(f.datasets))
+
+			for id := range f.datasets {
+				ids = append(ids, id)
+			}
+
+			sort.Strings(ids)
+			list := make([]interface{}, 0, len(ids))
+
+			for _, id := range ids {
+				list = append(list, f.renderDataset(id))
+			}
+
+			fakeJSON(w, list)
+		case http.MethodPost:
+			name, _ := body["name"].(string)
+			datasetType, _ := body["type"].(string)
+
+			if datasetType == "" {
+				datasetType = "FILESYSTEM"
+			}
+
+			if _, ok := f.datasets[name]; ok {
+				fakeValidationError(w, "pool_dataset_create", &fakeFieldError{"name", fmt.Sprintf("%s already exists", name)})
+				return
+			}
+
+			if parent := path.Dir(name); parent == "." || f.datasets[parent] == nil {
+				fakeValidationError(w, "pool_dataset_create", &fakeFieldError{"name", fmt.Sprintf("Parent dataset %s does not exist", parent)})
+				return
+			}
+
+			if datasetType == "VOLUME" && body["volsize"] == nil {
+				fakeValidationError(w, "pool_dataset_create", &fakeFieldError{"volsize", "This field is required for VOLUME"})
+				return
+			}
+
+			ds := newFakeDataset(datasetType)
+			ds.update(body)
+
+			if err := f.setupFakeEncryption(name, ds, body); err != nil {
+				fakeValidationError(w, "pool_dataset_create", err)
+				return
+			}
+
+			// SMB datasets get NFS4 ACL, as in middleware
+			if body["share_type"] == "SMB" {
+				ds.update(map[string]interface{}{"acltype": "NFSV4", "aclmode": "RESTRICTED"})
+			}
+			f.datasets[name] = ds
+
+			fakeJSON(w, f.renderDataset(name))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+
+		return
+	}
+
+	ds, ok := f.datasets[id]
+
+	if !ok {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", id))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		fakeJSON(w, f.renderDataset(id))
+	case http.MethodPut:
+		if f.fakeLocked(id) {
+			fakeError(w, http.StatusUnprocessableEntity, errnoEINVAL, fmt.Sprintf("%s is locked", id))
+			return
+		}
+
+		ds.update(body)
+		fakeJSON(w, f.renderDataset(id))
+	case http.MethodDelete:
+		for child := range f.datasets {
+			if strings.HasPrefix(child, id+"/") {
+				fakeError(w, http.StatusUnprocessableEntity, errnoEBUSY, fmt.Sprintf("%s has children", id))
+				return
+			}
+		}
+
+		for snap := range f.snapshots {
+			if strings.HasPrefix(snap, id+"@") {
+				fakeError(w, http.StatusUnprocessableEntity, errnoEBUSY, fmt.Sprintf("%s has snapshots", id))
+				return
+			}
+		}
+
+		delete(f.datasets, id)
+		fakeJSON(w, true)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// mountedDataset returns dataset mounted at path, eg. /mnt/Tank/data
+func (f *fakeTrueNAS) mountedDataset(path string) (string, *fakeDataset) {
+	for id, ds := range f.datasets {
+		if mp := f.mountpoint(id); mp != "" && mp == path {
+			return id, ds
+		}
+	}
+
+	return strings.TrimPrefix(path, "/mnt/"), nil
+}
+
+// mountpoint returns path filesystem is mounted at, volumes and locked datasets are not mounted
+func (f *fakeTrueNAS) mountpoint(id string) string {
+	ds := f.datasets[id]
+
+	if ds == nil || ds.Type != "FILESYSTEM" {
+		return ""
+	}
+
+	if root := f.datasets[ds.EncryptionRoot]; root != nil && root.Locked {
+		return ""
+	}
+
+	if ds.Mountpoint != "" {
+		return ds.Mountpoint
+	}
+
+	return "/mnt/" + id
+}
+
+func (f *fakeTrueNAS) serveFilesystemStat(w http.ResponseWriter, path string) {
+	_, ds := f.mountedDataset(path)
+
+	if ds == nil {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", path))
+		return
+	}
+
+	fakeJSON(w, map[string]interface{}{
+		"realpath": path,
+		"type":     "DIRECTORY",
+		"mode":     0o40000 | ds.Mode,
+		"uid":      ds.UID,
+		"gid":      ds.GID,
+		"user":     fakeAccountName(fakeUsers, ds.UID),
+		"group":    fakeAccountName(fakeGroups, ds.GID),
+		"acl":      ds.ACL != nil,
+	})
+}
+
+// serveFilesystemACL serves filesystem.getacl, trivial ACL is derived from dataset mode
+func (f *fakeTrueNAS) serveFilesystemACL(w http.ResponseWriter, path string) {
+	_, ds := f.mountedDataset(path)
+
+	if ds == nil {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", path))
+		return
+	}
+
+	aclType := aclTypePOSIX1E
+
+	if ds.Props["acltype"] == "NFSV4" {
+		aclType = aclTypeNFS4
+	}
+
+	acl := ds.ACL
+
+	if acl == nil {
+		acl = fakeTrivialACL(aclType, ds.Mode)
+	}
+
+	fakeJSON(w, map[string]interface{}{
+		"path":    path,
+		"acltype": aclType,
+		"trivial": ds.ACL == nil,
+		"uid":     ds.UID,
+		"gid":     ds.GID,
+		"acl":     acl,
+	})
+}
+
+// setDatasetPermission serves pool.dataset.permission job, permissions of child datasets are changed with traverse
+func (f *fakeTrueNAS) setDatasetPermission(w http.ResponseWriter, id string, body map[string]interface{}) {
+	if ds := f.datasets[id]; ds == nil || ds.Type != "FILESYSTEM" {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", id))
+		return
+	}
+
+	uid, gid, mode := -1, -1, -1
+
+	if user, ok := body["user"].(string); ok {
+		if uid, ok = fakeUsers[user]; !ok {
+			fakeValidationError(w, "pool_dataset_permission", &fakeFieldError{"user", fmt.Sprintf("%s: user does not exist", user)})
+			return
+		}
+	}
+
+	if group, ok := body["group"].(string); ok {
+		if gid, ok = fakeGroups[group]; !ok {
+			fakeValidationError(w, "pool_dataset_permission", &fakeFieldError{"group", fmt.Sprintf("%s: group does not exist", group)})
+			return
+		}
+	}
+
+	if m, ok := body["mode"].(string); ok {
+		parsed, err := strconv.ParseInt(m, 8, 32)
+
+		if err != nil {
+			fakeValidationError(w, "pool_dataset_permission", &fakeFieldError{"mode", "invalid mode"})
+			return
+		}
+
+		mode = int(parsed)
+	}
+
+	acl, _ := body["acl"].([]interface{})
+	options, _ := body["options"].(map[string]interface{})
+	recursive, _ := options["recursive"].(bool)
+	traverse, _ := options["traverse"].(bool)
+
+	for name, ds := range f.datasets {
+		if name != id && !(recursive && traverse && strings.HasPrefix(name, id+"/")) || ds.Type != "FILESYSTEM" {
+			continue
+		}
+
+		if uid >= 0 {
+			ds.UID = uid
+		}
+
+		if gid >= 0 {
+			ds.GID = gid
+		}
+
+		if mode >= 0 {
+			ds.Mode = mode
+			ds.ACL = nil
+		}
+
+		if len(acl) > 0 {
+			ds.ACL = acl
+		}
+	}
+
+	fakeJSON(w, f.startJob("pool.dataset.permission", nil, nil))
+}
+
+// renameDataset moves dataset along with its children and snapshots, same as zfs rename
+func (f *fakeTrueNAS) renameDataset(w http.ResponseWriter, id string, newName string) {
+	if f.datasets[id] == nil {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", id))
+		return
+	}
+
+	if strings.SplitN(id, "/", 2)[0] != strings.SplitN(newName, "/", 2)[0] || !strings.Contains(id, "/") {
+		fakeValidationError(w, "pool_dataset_rename", &fakeFieldError{"new_name", "Datasets can only be renamed within the same pool"})
+		return
+	}
+
+	if strings.HasPrefix(newName, id+"/") {
+		fakeValidationError(w, "pool_dataset_rename", &fakeFieldError{"new_name", "Dataset can not be moved under itself"})
+		return
+	}
+
+	if f.datasets[newName] != nil {
+		fakeValidationError(w, "pool_dataset_rename", &fakeFieldError{"new_name", fmt.Sprintf("%s already exists", newName)})
+		return
+	}
+
+	if f.datasets[path.Dir(newName)] == nil {
+		fakeValidationError(w, "pool_dataset_rename", &fakeFieldError{"new_name", fmt.Sprintf("%s does not exist", path.Dir(newName))})
+		return
+	}
+
+	moved := func(name string) string {
+		if name == id || strings.HasPrefix(name, id+"/") || strings.HasPrefix(name, id+"@") {
+			return newName + strings.TrimPrefix(name, id)
+		}
+
+		return name
+	}
+
+	datasets := make(map[string]*fakeDataset, len(f.datasets))
+
+	for name, ds := range f.datasets {
+		ds.EncryptionRoot = moved(ds.EncryptionRoot)
+		ds.Origin = moved(ds.Origin)
+		datasets[moved(name)] = ds
+	}
+
+	snapshots := make(map[string]*fakeSnapshot, len(f.snapshots))
+
+	for name, snap := range f.snapshots {
+		snapshots[moved(name)] = snap
+	}
+
+	f.datasets = datasets
+	f.snapshots = snapshots
+
+	fakeJSON(w, nil)
+}
+
+func (f *fakeTrueNAS) setDatasetQuota(w http.ResponseWriter, id string, quotas []interface{}) {
+	ds := f.datasets[id]
+
+	if ds == nil {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", id))
+		return
+	}
+
+	for i, q := range quotas {
+		quota, _ := q.(map[string]interface{})
+		quotaType, _ := quota["quota_type"].(string)
+		name, _ := quota["id"].(string)
+		value, _ := quota["quota_value"].(json.Number)
+		accounts := fakeUsers
+
+		if strings.HasPrefix(quotaType, quotaTypeGroup) {
+			accounts = fakeGroups
+		}
+
+		xid, ok := accounts[name]
+
+		if !ok {
+			var err error
+
+			if xid, err = strconv.Atoi(name); err != nil {
+				fakeValidationError(w, "pool_dataset_set_quota", &fakeFieldError{fmt.Sprintf("quotas.%d.id", i), fmt.Sprintf("%s: %s does not exist", name, strings.ToLower(strings.TrimSuffix(quotaType, "OBJ")))})
+				return
+			}
+		}
+
+		limit, _ := value.Int64()
+		key := fmt.Sprintf("%s:%d", quotaType, xid)
+
+		if limit == 0 {
+			delete(ds.Quotas, key)
+		} else {
+			ds.Quotas[key] = limit
+		}
+	}
+
+	fakeJSON(w, nil)
+}
+
+// fakeSetQuotaArgs checks pool.dataset.set_quota item method body, that is a bare list of quota entries,
+// dataset id is only accepted as part of the path
+func fakeSetQuotaArgs(method string, raw interface{}) ([]interface{}, error) {
+	if method != http.MethodPost {
+		return nil, &fakeFieldError{"quotas", fmt.Sprintf("%s is not supported", method)}
+	}
+
+	quotas, ok := raw.([]interface{})
+
+	if !ok {
+		return nil, &fakeFieldError{"quotas", "Not a list"}
+	}
+
+	for i, q := range quotas {
+		quota, ok := q.(map[string]interface{})
+
+		if !ok {
+			return nil, &fakeFieldError{fmt.Sprintf("quotas.%d", i), "Not a dictionary"}
+		}
+
+		for key := range quota {
+			if key != "quota_type" && key != "id" && key != "quota_value" {
+				return nil, &fakeFieldError{fmt.Sprintf("quotas.%d.%s", i, key), "Field was not expected"}
+			}
+		}
+
+		if quotaType, _ := quota["quota_type"].(string); !fakeQuotaTypes[quotaType] {
+			return nil, &fakeFieldError{fmt.Sprintf("quotas.%d.quota_type", i), "Invalid choice"}
+		}
+
+		if _, ok := quota["id"].(string); !ok {
+			return nil, &fakeFieldError{fmt.Sprintf("quotas.%d.id", i), "Not a string"}
+		}
+
+		if _, ok := quota["quota_value"].(json.Number); !ok {
+			return nil, &fakeFieldError{fmt.Sprintf("quotas.%d.quota_value", i), "Not an integer"}
+		}
+	}
+
+	return quotas, nil
+}
+
+// fakeGetQuotaArgs checks pool.dataset.get_quota item method body and returns its quota type
+func fakeGetQuotaArgs(method string, raw interface{}) (string, error) {
+	if method != http.MethodPost {
+		return "", &fakeFieldError{"quota_type", fmt.Sprintf("%s is not supported", method)}
+	}
+
+	body, ok := raw.(map[string]interface{})
+
+	if !ok {
+		return "", &fakeFieldError{"quota_type", "Not a dictionary"}
+	}
+
+	for key := range body {
+		if key != "quota_type" && key != "filters" && key != "options" {
+			return "", &fakeFieldError{key, "Field was not expected"}
+		}
+	}
+
+	quotaType, _ := body["quota_type"].(string)
+
+	if quotaType != quotaTypeUser && quotaType != quotaTypeGroup && quotaType != "DATASET" {
+		return "", &fakeFieldError{"quota_type", "Invalid choice"}
+	}
+
+	return quotaType, nil
+}
+
+// fakeQuotaTypes are quota types accepted by pool.dataset.set_quota
+var fakeQuotaTypes = map[string]bool{
+	quotaTypeUser:     true,
+	quotaTypeGroup:    true,
+	quotaTypeUserObj:  true,
+	quotaTypeGroupObj: true,
+	"DATASET":         true,
+	"REFQUOTA":        true,
+}
+
+// getDatasetQuota serves pool.dataset.get_quota, USER and GROUP entries include object quotas
+func (f *fakeTrueNAS) getDatasetQuota(w http.ResponseWriter, id string, quotaType string) {
+	ds := f.datasets[id]
+
+	if ds == nil {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", id))
+		return
+	}
+
+	accounts := fakeUsers
+
+	if quotaType == quotaTypeGroup {
+		accounts = fakeGroups
+	}
+
+	entries := make(map[int]map[string]interface{})
+
+	for key, limit := range ds.Quotas {
+		s := strings.SplitN(key, ":", 2)
+		xid, _ := strconv.Atoi(s[1])
+
+		if strings.TrimSuffix(s[0], "OBJ") != quotaType {
+			continue
+		}
+
+		entry, ok := entries[xid]
+
+		if !ok {
+			entry = map[string]interface{}{
+				"quota_type":       quotaType,
+				"id":               xid,
+				"name":             fakeAccountName(accounts, xid),
+				"quota":            0,
+				"refquota":         0,
+				"used_bytes":       0,
+				"used_percent":     0,
+				"obj_quota":        0,
+				"obj_used":         0,
+				"obj_used_percent": 0,
+			}
+			entries[xid] = entry
+		}
+
+		if isObjectQuota(s[0]) {
+			entry["obj_quota"] = limit
+		} else {
+			entry["quota"] = limit
+		}
+	}
+
+	list := make([]interface{}, 0, len(entries))
+
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+
+	fakeJSON(w, list)
+}
+
+func fakeAccountName(accounts map[string]int, id int) string {
+	for name, accountID := range accounts {
+		if accountID == id {
+			return name
+		}
+	}
+
+	return strconv.Itoa(id)
+}
+
+// fakeTrivialACL returns ACL entries equivalent to mode, as reported for datasets without extended ACL
+func fakeTrivialACL(aclType string, mode int) []interface{} {
+	acl := make([]interface{}, 0, 3)
+
+	if aclType == aclTypeNFS4 {
+		for i, tag := range []string{"owner@", "group@", "everyone@"} {
+			bits := mode >> (6 - 3*i) & 0o7
+			perm := "NOPERMS"
+
+			switch {
+			case bits&0o2 != 0:
+				perm = "MODIFY"
+			case bits&0o4 != 0:
+				perm = "READ"
+			case bits&0o1 != 0:
+				perm = "TRAVERSE"
+			}
+
+			acl = append(acl, map[string]interface{}{
+				"tag":   tag,
+				"id":    nil,
+				"type":  "ALLOW",
+				"perms": map[string]interface{}{aclBasicKey: perm},
+				"flags": map[string]interface{}{aclBasicKey: "NOINHERIT"},
+			})
+		}
+
+		return acl
+	}
+
+	for i, tag := range []string{"USER_OBJ", "GROUP_OBJ", "OTHER"} {
+		bits := mode >> (6 - 3*i) & 0o7
+
+		acl = append(acl, map[string]interface{}{
+			"tag":     tag,
+			"id":      -1,
+			"default": false,
+			"perms": map[string]interface{}{
+				"READ":    bits&0o4 != 0,
+				"WRITE":   bits&0o2 != 0,
+				"EXECUTE": bits&0o1 != 0,
+			},
+		})
+	}
+
+	return acl
+}
+
+func (f *fakeTrueNAS) serveSnapshot(w http.ResponseWriter, r *http.Request, id string, body map[string]interface{}) {
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			ids := make([]string, 0, len(f.snapshots))
+
+			for id := range f.snapshots {
+				ids = append(ids, id)
+			}
+
+			sort.Strings(ids)
+			list := make([]interface{}, 0, len(ids))
+
+			for _, id := range ids {
+				if snap := f.renderSnapshot(id); fakeMatchQuery(snap, r) {
+					list = append(list, snap)
+				}
+			}
+
+			fakeJSON(w, list)
+		case http.MethodPost:
+			dataset, _ := body["dataset"].(string)
+			name, _ := body["name"].(string)
+			recursive, _ := body["recursive"].(bool)
+			id := dataset + "@" + name
+
+			if f.datasets[dataset] == nil {
+				fakeValidationError(w, "zfs_snapshot_create", &fakeFieldError{"dataset", fmt.Sprintf("%s does not exist", dataset)})
+				return
+			}
+
+			if f.snapshots[id] != nil {
+				fakeValidationError(w, "zfs_snapshot_create", &fakeFieldError{"name", fmt.Sprintf("%s already exists", id)})
+				return
+			}
+
+			for ds := range f.datasets {
+				if ds == dataset || (recursive && strings.HasPrefix(ds, dataset+"/")) {
+					f.snapshots[ds+"@"+name] = &fakeSnapshot{Created: time.Now(), Txg: f.nextID}
+					f.nextID++
+				}
+			}
+
+			fakeJSON(w, f.renderSnapshot(id))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+
+		return
+	}
+
+	snap, ok := f.snapshots[id]
+
+	if !ok {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", id))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		fakeJSON(w, f.renderSnapshot(id))
+	case http.MethodDelete:
+		deferDelete, _ := body["defer"].(bool)
+		recursive, _ := body["recursive"].(bool)
+
+		if snap.Holds > 0 && !deferDelete {
+			fakeError(w, http.StatusUnprocessableEntity, errnoEBUSY, fmt.Sprintf("%s has holds", id))
+			return
+		}
+
+		for _, ds := range f.datasets {
+			if ds.Origin == id {
+				fakeError(w, http.StatusUnprocessableEntity, errnoEBUSY, fmt.Sprintf("%s has dependent clones", id))
+				return
+			}
+		}
+
+		dataset, name, _ := parseSnapshotID(id)
+
+		for other := range f.snapshots {
+			if other == id || (recursive && strings.HasPrefix(other, dataset+"/") && strings.HasSuffix(other, "@"+name)) {
+				delete(f.snapshots, other)
+			}
+		}
+
+		fakeJSON(w, true)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// cloneSnapshot creates dataset from snapshot, clone copies properties and permissions of origin
+func (f *fakeTrueNAS) cloneSnapshot(w http.ResponseWriter, body map[string]interface{}) {
+	id, _ := body["snapshot"].(string)
+	name, _ := body["dataset_dst"].(string)
+
+	if f.snapshots[id] == nil {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", id))
+		return
+	}
+
+	if f.datasets[name] != nil {
+		fakeValidationError(w, "snapshot_clone", &fakeFieldError{"dataset_dst", fmt.Sprintf("%s already exists", name)})
+		return
+	}
+
+	if parent := path.Dir(name); f.datasets[parent] == nil {
+		fakeValidationError(w, "snapshot_clone", &fakeFieldError{"dataset_dst", fmt.Sprintf("Parent dataset %s does not exist", parent)})
+		return
+	}
+
+	dataset, _, _ := parseSnapshotID(id)
+	origin := f.datasets[dataset]
+	clone := *origin
+
+	clone.Props = make(map[string]string, len(origin.Props))
+	clone.Local = make(map[string]bool)
+	clone.User = make(map[string]string)
+	clone.Quotas = make(map[string]int64)
+	clone.Origin = id
+
+	for k, v := range origin.Props {
+		clone.Props[k] = v
+	}
+
+	f.datasets[name] = &clone
+
+	fakeJSON(w, true)
+}
+
+// rollbackSnapshot rolls dataset back, newer snapshots are destroyed with recursive option and their
+// clones with recursive_clones option
+func (f *fakeTrueNAS) rollbackSnapshot(w http.ResponseWriter, id string, options map[string]interface{}) {
+	snap := f.snapshots[id]
+
+	if snap == nil {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", id))
+		return
+	}
+
+	recursive, _ := options["recursive"].(bool)
+	recursiveClones, _ := options["recursive_clones"].(bool)
+	dataset, _, _ := parseSnapshotID(id)
+
+	var newer []string
+
+	for other, s := range f.snapshots {
+		if strings.HasPrefix(other, dataset+"@") && s.Txg > snap.Txg {
+			newer = append(newer, other)
+		}
+	}
+
+	if len(newer) > 0 && !recursive && !recursiveClones {
+		fakeError(w, http.StatusUnprocessableEntity, errnoEINVAL, fmt.Sprintf("cannot rollback to '%s': more recent snapshots or bookmarks exist", id))
+		return
+	}
+
+	var clones []string
+
+	for name, ds := range f.datasets {
+		for _, other := range newer {
+			if ds.Origin == other {
+				clones = append(clones, name)
+			}
+		}
+	}
+
+	if len(clones) > 0 && !recursiveClones {
+		fakeError(w, http.StatusUnprocessableEntity, errnoEBUSY, fmt.Sprintf("cannot rollback to '%s': clones of previous snapshots exist", id))
+		return
+	}
+
+	for _, name := range clones {
+		delete(f.datasets, name)
+	}
+
+	for _, other := range newer {
+		delete(f.snapshots, other)
+	}
+
+	f.datasets[dataset].Rollback = id
+
+	fakeJSON(w, nil)
+}
+
+// promoteDataset moves origin snapshot and snapshots taken before it from origin dataset to the clone,
+// origin dataset and other clones of moved snapshots become clones of promoted dataset
+func (f *fakeTrueNAS) promoteDataset(w http.ResponseWriter, id string) {
+	ds := f.datasets[id]
+
+	if ds == nil {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", id))
+		return
+	}
+
+	if ds.Origin == "" {
+		fakeError(w, http.StatusUnprocessableEntity, errnoEINVAL, fmt.Sprintf("%s is not a clone", id))
+		return
+	}
+
+	origin, _, _ := parseSnapshotID(ds.Origin)
+	created := f.snapshots[ds.Origin].Created
+	moved := make(map[string]string)
+
+	for snapID, snap := range f.snapshots {
+		dataset, name, _ := parseSnapshotID(snapID)
+
+		if dataset == origin && !snap.Created.After(created) {
+			moved[snapID] = id + "@" + name
+		}
+	}
+
+	for from, to := range moved {
+		f.snapshots[to] = f.snapshots[from]
+		delete(f.snapshots, from)
+	}
+
+	f.datasets[origin].Origin = moved[ds.Origin]
+	ds.Origin = ""
+
+	for _, other := range f.datasets {
+		if to, ok := moved[other.Origin]; ok {
+			other.Origin = to
+		}
+	}
+
+	fakeJSON(w, nil)
+}
+
+// renderSnapshot returns snapshot as reported by zfs.snapshot.query
+func (f *fakeTrueNAS) renderSnapshot(id string) map[string]interface{} {
+	snap := f.snapshots[id]
+	dataset, name, _ := parseSnapshotID(id)
+	creation := strconv.FormatInt(snap.Created.Unix(), 10)
+
+	return map[string]interface{}{
+		"id":            id,
+		"name":          id,
+		"pool":          strings.SplitN(dataset, "/", 2)[0],
+		"type":          "SNAPSHOT",
+		"dataset":       dataset,
+		"snapshot_name": name,
+		"properties": map[string]interface{}{
+			"creation":   map[string]interface{}{"value": snap.Created.Format(time.ANSIC), "rawvalue": creation, "source": "NONE"},
+			"createtxg":  map[string]interface{}{"value": strconv.Itoa(snap.Txg), "rawvalue": strconv.Itoa(snap.Txg), "source": "NONE"},
+			"used":       map[string]interface{}{"value": "0B", "rawvalue": "0", "source": "NONE"},
+			"referenced": map[string]interface{}{"value": "96K", "rawvalue": "98304", "source": "NONE"},
+		},
+	}
+}
+
+// fakeDatasetEncryptionMethods are pool.dataset methods served by serveDatasetEncryption
+var fakeDatasetEncryptionMethods = map[string]bool{
+	"change_key":                           true,
+	"export_key":                           true,
+	"lock":                                 true,
+	"unlock":                               true,
+	"inherit_parent_encryption_properties": true,
+}
+
+// fakeGeneratedKey is the key fakeTrueNAS generates for datasets created or changed with generate_key
+const fakeGeneratedKey = "00112233445566778899aabbccddeeff00112233445566778899aabbccddeeff"
+
+// setupFakeEncryption makes new dataset an encryption root, or inherits encryption root of its parent
+func (f *fakeTrueNAS) setupFakeEncryption(name string, ds *fakeDataset, params map[string]interface{}) error {
+	encryption, _ := params["encryption"].(bool)
+	inherit, _ := params["inherit_encryption"].(bool)
+	parent := f.datasets[path.Dir(name)]
+
+	if parent != nil && parent.EncryptionRoot != "" {
+		if inherit {
+			ds.EncryptionRoot = parent.EncryptionRoot
+			return nil
+		}
+
+		if !encryption {
+			return &fakeFieldError{"encryption", "Encrypted parent dataset requires child dataset to be encrypted"}
+		}
+	}
+
+	if !encryption {
+		return nil
+	}
+
+	options, _ := params["encryption_options"].(map[string]interface{})
+
+	if err := ds.setFakeKey("encryption_options", options); err != nil {
+		return err
+	}
+
+	ds.EncryptionRoot = name
+	ds.Algorithm = "AES-256-GCM"
+
+	if algorithm, ok := options["algorithm"].(string); ok {
+		ds.Algorithm = algorithm
+	}
+
+	return nil
+}
+
+// setFakeKey sets key format and key of encryption root from pool.dataset.create or change_key options
+func (ds *fakeDataset) setFakeKey(field string, options map[string]interface{}) error {
+	passphrase, _ := options["passphrase"].(string)
+	key, _ := options["key"].(string)
+	generate, _ := options["generate_key"].(bool)
+
+	switch {
+	case passphrase != "":
+		ds.KeyFormat, ds.Key = "PASSPHRASE", passphrase
+		ds.Props["pbkdf2iters"] = "350000"
+
+		if iters, ok := options["pbkdf2iters"].(json.Number); ok {
+			ds.Props["pbkdf2iters"] = iters.String()
+		}
+	case key != "":
+		ds.KeyFormat, ds.Key = "HEX", key
+		ds.Props["pbkdf2iters"] = "1"
+	case generate:
+		ds.KeyFormat, ds.Key = "HEX", fakeGeneratedKey
+		ds.Props["pbkdf2iters"] = "1"
+	default:
+		return &fakeFieldError{field, "Passphrase, key or generate_key is required"}
+	}
+
+	return nil
+}
+
+// fakeLocked returns true if encryption root of dataset is locked
+func (f *fakeTrueNAS) fakeLocked(id string) bool {
+	root := f.datasets[f.datasets[id].EncryptionRoot]
+
+	return root != nil && root.Locked
+}
+
+// serveDatasetEncryption serves encryption methods of dataset, all of them but inherit_parent_encryption_properties run as jobs
+func (f *fakeTrueNAS) serveDatasetEncryption(w http.ResponseWriter, id string, method string, body map[string]interface{}) {
+	ds := f.datasets[id]
+
+	if ds == nil {
+		fakeError(w, http.StatusNotFound, errnoENOENT, fmt.Sprintf("%s does not exist", id))
+		return
+	}
+
+	apiMethod := "pool_dataset_" + method
+
+	if ds.EncryptionRoot == "" {
+		fakeValidationError(w, apiMethod, &fakeFieldError{"id", fmt.Sprintf("%s is not encrypted", id)})
+		return
+	}
+
+	isRoot := ds.EncryptionRoot == id
+
+	switch method {
+	case "change_key":
+		if f.fakeLocked(id) {
+			fakeValidationError(w, apiMethod, &fakeFieldError{"id", "Dataset must be unlocked before key can be changed"})
+			return
+		}
+
+		root := f.datasets[ds.EncryptionRoot]
+		changed := &fakeDataset{Props: ds.Props}
+
+		if err := changed.setFakeKey("change_key_options", body); err != nil {
+			fakeValidationError(w, apiMethod, err)
+			return
+		}
+
+		// dataset that inherited encryption becomes encryption root of itself and its children
+		for name, other := range f.datasets {
+			if other.EncryptionRoot == ds.EncryptionRoot && (name == id || strings.HasPrefix(name, id+"/")) && name != ds.EncryptionRoot {
+				other.EncryptionRoot = id
+			}
+		}
+
+		ds.EncryptionRoot = id
+		ds.Algorithm = root.Algorithm
+		ds.KeyFormat, ds.Key = changed.KeyFormat, changed.Key
+
+		fakeJSON(w, f.startJob("pool.dataset.change_key", nil, nil))
+	case "export_key":
+		switch {
+		case !isRoot:
+			fakeValidationError(w, apiMethod, &fakeFieldError{"id", "Only encryption roots can be exported"})
+		case ds.KeyFormat != "HEX":
+			fakeValidationError(w, apiMethod, &fakeFieldError{"id", "Only datasets which are encrypted with key can be exported"})
+		default:
+			fakeJSON(w, f.startJob("pool.dataset.export_key", ds.Key, nil))
+		}
+	case "lock":
+		switch {
+		case !isRoot:
+			fakeValidationError(w, apiMethod, &fakeFieldError{"id", "Only encryption roots can be locked"})
+		case ds.KeyFormat != "PASSPHRASE":
+			fakeValidationError(w, apiMethod, &fakeFieldError{"id", "Only datasets which are encrypted with passphrase can be locked"})
+		case ds.Locked:
+			fakeValidationError(w, apiMethod, &fakeFieldError{"id", fmt.Sprintf("%s dataset is already locked", id)})
+		default:
+			ds.Locked = true
+			fakeJSON(w, f.startJob("pool.dataset.lock", true, nil))
+		}
+	case "unlock":
+		if !isRoot || !ds.Locked {
+			fakeValidationError(w, apiMethod, &fakeFieldError{"id", fmt.Sprintf("%s dataset is not locked", id)})
+			return
+		}
+
+		result := map[string]interface{}{"unlocked": []interface{}{}, "failed": map[string]interface{}{}}
+		datasets, _ := body["datasets"].([]interface{})
+		unlocked := false
+
+		for _, d := range datasets {
+			entry, _ := d.(map[string]interface{})
+
+			if entry["name"] == id && (entry["passphrase"] == ds.Key || entry["key"] == ds.Key) {
+				unlocked = true
+			}
+		}
+
+		if unlocked {
+			ds.Locked = false
+			result["unlocked"] = []interface{}{id}
+		} else {
+			result["failed"] = map[string]interface{}{id: map[string]interface{}{"error": "Invalid Key", "skipped": []interface{}{}}}
+		}
+
+		fakeJSON(w, f.startJob("pool.dataset.unlock", result, nil))
+	case "inherit_parent_encryption_properties":
+		parent := f.datasets[path.Dir(id)]
+
+		switch {
+		case !isRoot:
+			fakeError(w, http.StatusUnprocessableEntity, errnoEINVAL, fmt.Sprintf("%s is not an encryption root", id))
+		case parent == nil || parent.EncryptionRoot == "":
+			fakeError(w, http.StatusUnprocessableEntity, errnoEINVAL, "Parent dataset is not encrypted")
+		case f.fakeLocked(path.Dir(id)) || ds.Locked:
+			fakeError(w, http.StatusUnprocessableEntity, errnoEINVAL, "Dataset and its parent must be unlocked")
+		default:
+			for _, other := range f.datasets {
+				if other.EncryptionRoot == id {
+					other.EncryptionRoot = parent.EncryptionRoot
+				}
+			}
+
+			ds.KeyFormat, ds.Key, ds.Algorithm = "", "", ""
+			fakeJSON(w, nil)
+		}
+	}
+}
+
+func (ds *fakeDataset) update(params map[string]interface{}) {
+	for k, v := range params {
+		if fakeDatasetIgnoredParams[k] || v == nil {
+			continue
+		}
+
+		// INHERIT resets property to default, value of parent dataset is resolved by renderDataset
+		if v == "INHERIT" {
+			ds.Props[k] = newFakeDatasetProps(ds.Type)[k]
+			delete(ds.Local, k)
+			continue
+		}
+
+		ds.Props[k] = fmt.Sprint(v)
+		ds.Local[k] = true
+	}
+
+	// user_properties are set on create, user_properties_update on update
+	for _, k := range []string{"user_properties", "user_properties_update"} {
+		props, _ := params[k].([]interface{})
+
+		for _, p := range props {
+			prop := p.(map[string]interface{})
+			key := prop["key"].(string)
+
+			if remove, _ := prop["remove"].(bool); remove {
+				delete(ds.User, key)
+			} else {
+				ds.User[key] = fmt.Sprint(prop["value"])
+			}
+		}
+	}
+}
+
+// fakeProperty returns property value and its source, inheritable properties not set locally are taken
+// from the closest ancestor that sets them
+func (f *fakeTrueNAS) fakeProperty(id string, name string) (string, string) {
+	ds := f.datasets[id]
+
+	if fakeDatasetLocalProps[name] || ds.Local[name] {
+		return ds.Props[name], "LOCAL"
+	}
+
+	for parent := path.Dir(id); parent != "."; parent = path.Dir(parent) {
+		if p := f.datasets[parent]; p != nil && p.Local[name] {
+			return p.Props[name], "INHERITED"
+		}
+	}
+
+	return ds.Props[name], "DEFAULT"
+}
+
+// renderDataset returns dataset as reported by pool.dataset.query, properties are composite values
+func (f *fakeTrueNAS) renderDataset(id string) map[string]interface{} {
+	ds := f.datasets[id]
+
+	res := map[string]interface{}{
+		"id":                   id,
+		"name":                 id,
+		"pool":                 strings.SplitN(id, "/", 2)[0],
+		"type":                 ds.Type,
+		"encrypted":            false,
+		"encryption_root":      nil,
+		"key_loaded":           false,
+		"locked":               false,
+		"encryption_algorithm": map[string]interface{}{"value": nil, "rawvalue": "off", "source": "DEFAULT"},
+		"key_format":           map[string]interface{}{"value": nil, "rawvalue": "none", "source": "DEFAULT"},
+		"origin":               map[string]interface{}{"value": ds.Origin, "rawvalue": ds.Origin, "source": "NONE"},
+	}
+
+	if root := f.datasets[ds.EncryptionRoot]; root != nil {
+		res["encrypted"] = true
+		res["encryption_root"] = ds.EncryptionRoot
+		res["key_loaded"] = !root.Locked
+		res["locked"] = root.Locked
+		res["encryption_algorithm"] = map[string]interface{}{"value": root.Algorithm, "rawvalue": strings.ToLower(root.Algorithm), "source": "LOCAL"}
+		res["key_format"] = map[string]interface{}{"value": root.KeyFormat, "rawvalue": strings.ToLower(root.KeyFormat), "source": "LOCAL"}
+	}
+
+	if mp := f.mountpoint(id); mp != "" {
+		res["mountpoint"] = mp
+	} else {
+		res["mountpoint"] = nil
+	}
+
+	for k := range ds.Props {
+		v, source := f.fakeProperty(id, k)
+		raw := v
+
+		// middleware reports record size in bytes as rawvalue, eg. 131072 for 128K
+		if k == "recordsize" {
+			raw = strconv.FormatInt(parseBlockSize(v), 10)
+		}
+
+		res[k] = map[string]interface{}{"value": v, "rawvalue": raw, "source": source}
+	}
+
+	userProps := make(map[string]interface{})
+
+	// user properties are inherited, the closest dataset setting them wins
+	for p := id; p != "."; p = path.Dir(p) {
+		source := "LOCAL"
+
+		if p != id {
+			source = "INHERITED"
+		}
+
+		if other := f.datasets[p]; other != nil {
+			for k, v := range other.User {
+				if userProps[k] == nil {
+					userProps[k] = map[string]interface{}{"value": v, "rawvalue": v, "parsed": v, "source": source}
+				}
+			}
+		}
+	}
+
+	res["user_properties"] = userProps
+
+	return res
+}
+
+func fakeServices() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"id": 1, "service": "cifs", "enable": true, "state": "RUNNING", "pids": []int{101}},
+		{"id": 2, "service": "nfs", "enable": true, "state": "RUNNING", "pids": []int{102, 103}},
+		{"id": 3, "service": "ssh", "enable": false, "state": "STOPPED", "pids": []int{}},
+	}
+}
+
+func fakeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func fakeError(w http.ResponseWriter, status int, errno int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": msg, "errno": errno})
+}
+
+// fakeValidationError responds with middleware validation error, eg. {"vm_create.name": [...]}
+func fakeValidationError(w http.ResponseWriter, method string, err error) {
+	fe := err.(*fakeFieldError)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		fmt.Sprintf("%s.%s", method, fe.Field): []interface{}{
+			map[string]interface{}{"message": fe.Message, "errno": errnoEINVAL},
+		},
+	})
+}
+
+// testFakePreCheck skips tests driving terraform CLI against fake server, if terraform is not installed,
+// to avoid downloading it during unit tests
+func testFakePreCheck(t *testing.T) {
+	if os.Getenv("TF_ACC_TERRAFORM_PATH") != "" {
+		return
+	}
+
+	if _, err := exec.LookPath("terraform"); err != nil {
+		t.Skip("terraform CLI is not installed, set TF_ACC_TERRAFORM_PATH to run tests against fake TrueNAS")
+	}
+}
+
+// testFakeProviderFactories returns new provider instance for every resource.UnitTest step
+func testFakeProviderFactories() map[string]func() (*schema.Provider, error) {
+	return map[string]func() (*schema.Provider, error){
+		"truenas": func() (*schema.Provider, error) {
+			return Provider(), nil
+		},
+	}
+}
+
+// testAccCheckFakeDestroy checks that resources of given type are removed from fake server
+func testAccCheckFakeDestroy(f *fakeTrueNAS, resourceType string, collection string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != resourceType {
+				continue
+			}
+
+			if collection == "zfs/snapshot" {
+				if _, ok := f.snapshots[rs.Primary.ID]; ok {
+					return fmt.Errorf("%s (%s) still exists", resourceType, rs.Primary.ID)
+				}
+
+				continue
+			}
+
+			if collection == "pool/dataset" {
+				if _, ok := f.datasets[rs.Primary.ID]; ok {
+					return fmt.Errorf("%s (%s) still exists", resourceType, rs.Primary.ID)
+				}
+
+				continue
+			}
+
+			id, _ := strconv.Atoi(rs.Primary.ID)
+
+			if collection == "pool" {
+				if _, ok := f.pools[id]; ok {
+					return fmt.Errorf("%s (%s) still exists", resourceType, rs.Primary.ID)
+				}
+
+				continue
+			}
+
+			if _, ok := f.items[collection][id]; ok {
+				return fmt.Errorf("%s (%s) still exists", resourceType, rs.Primary.ID)
+			}
+		}
+
+		return nil
+	}
+}
+
+func TestFakeTrueNAS_readNotFound(t *testing.T) {
+	f := newFakeTrueNAS(t)
+	c := f.client()
+
+	testcases := []struct {
+		resource string
+		id       string
+		path     string
+	}{
+		{"truenas_cronjob", "1", "cronjob/id/1"},
+		{"truenas_dataset", "Tank/data", "pool/dataset/id/Tank/data"},
+		{"truenas_share_nfs", "1", "sharing/nfs/id/1"},
+		{"truenas_share_smb", "1", "sharing/smb/id/1"},
+		{"truenas_vm", "1", "vm/id/1"},
+		{"truenas_zvol", "Tank/vol", "pool/dataset/id/Tank/vol"},
+	}
+
+	for _, tc := range testcases {
+		f.inject(fakeFault{
+			Method: http.MethodGet,
+			Path:   tc.path,
+			Status: http.StatusNotFound,
+			Body:   `{"message": "[ENOENT] Object does not exist", "errno": 2}`,
+			Times:  1,
+		})
+
+		r := Provider().ResourcesMap[tc.resource]
+		d := r.TestResourceData()
+		d.SetId(tc.id)
+
+		diags := r.ReadContext(context.Background(), d, c)
+
+		assert.False(t, diags.HasError(), tc.resource)
+		assert.Equal(t, "", d.Id(), "%s must be removed from state", tc.resource)
+	}
+}
+
+func TestFakeTrueNAS_validationError(t *testing.T) {
+	f := newFakeTrueNAS(t)
+	c := f.client()
+
+	f.inject(fakeFault{
+		Method: http.MethodPost,
+		Path:   "sharing/nfs",
+		Status: http.StatusUnprocessableEntity,
+		Body:   `{"sharingnfs_create.networks": [{"message": "Invalid network", "errno": 22}]}`,
+		Times:  1,
+	})
+
+	r := resourceTrueNASShareNFS()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"paths":    []interface{}{"/mnt/Tank"},
+		"networks": []interface{}{"10.0.0.0/33"},
+	})
+
+	diags := r.CreateContext(context.Background(), d, c)
+
+	assert.Equal(t, diag.Diagnostics{
+		{
+			Severity:      diag.Error,
+			Summary:       "error creating NFS share: Invalid network",
+			Detail:        "sharingnfs_create.networks: Invalid network",
+			AttributePath: cty.GetAttrPath("networks"),
+		},
+	}, diags)
+	assert.Equal(t, "", d.Id())
+
+	// validation errors reported by fake server itself
+	r = resourceTrueNASDataset()
+	d = schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"pool":   "Tank",
+		"parent": "missing",
+		"name":   "data",
+	})
+
+	diags = r.CreateContext(context.Background(), d, c)
+
+	assert.Len(t, diags, 1)
+	assert.Equal(t, "error creating dataset: Parent dataset Tank/missing does not exist", diags[0].Summary)
+	assert.Equal(t, cty.GetAttrPath("name"), diags[0].AttributePath)
+}
+
+func TestFakeTrueNAS_quotaArgs(t *testing.T) {
+	f := newFakeTrueNAS(t)
+	c := f.client()
+	ctx := context.Background()
+
+	assert.NoError(t, c.invoke(ctx, "pool.dataset.create", []interface{}{map[string]interface{}{"name": "Tank/home"}}, nil))
+
+	quotas := []interface{}{map[string]interface{}{"quota_type": "USER", "id": "apps", "quota_value": 1024}}
+
+	testcases := []struct {
+		name string
+		path string
+		body interface{}
+	}{
+		{"set_quota with named args", "pool/dataset/id/Tank%2Fhome/set_quota", map[string]interface{}{"ds": "Tank/home", "quotas": quotas}},
+		{"set_quota with unexpected entry field", "pool/dataset/id/Tank%2Fhome/set_quota", []interface{}{map[string]interface{}{"quota_type": "USER", "id": "apps", "quota_value": 1024, "ds": "Tank/home"}}},
+		{"set_quota with numeric id", "pool/dataset/id/Tank%2Fhome/set_quota", []interface{}{map[string]interface{}{"quota_type": "USER", "id": 568, "quota_value": 1024}}},
+		{"get_quota with dataset in body", "pool/dataset/id/Tank%2Fhome/get_quota", map[string]interface{}{"ds": "Tank/home", "quota_type": "USER"}},
+		{"get_quota with invalid type", "pool/dataset/id/Tank%2Fhome/get_quota", map[string]interface{}{"quota_type": "USEROBJ"}},
+		{"get_quota with bare type", "pool/dataset/id/Tank%2Fhome/get_quota", "USER"},
+	}
+
+	for _, tc := range testcases {
+		_, err := c.call(ctx, http.MethodPost, tc.path, tc.body, nil)
+
+		var apiErr *apiError
+		if assert.ErrorAs(t, err, &apiErr, tc.name) {
+			assert.Equal(t, "422 Unprocessable Entity", apiErr.Status, tc.name)
+		}
+	}
+
+	// item method format used by provider
+	assert.NoError(t, c.invoke(ctx, "pool.dataset.set_quota", []interface{}{"Tank/home", quotas}, nil))
+
+	var entries []map[string]interface{}
+	assert.NoError(t, c.invoke(ctx, "pool.dataset.get_quota", []interface{}{"Tank/home", "USER"}, &entries))
+	assert.Len(t, entries, 1)
+}
+
+func TestFakeTrueNAS_slowResponse(t *testing.T) {
+	f := newFakeTrueNAS(t)
+	c := f.client()
+
+	r := resourceTrueNASCronjob()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"user":     "root",
+		"command":  "echo test",
+		"schedule": []interface{}{map[string]interface{}{"minute": "5"}},
+	})
+
+	diags := r.CreateContext(context.Background(), d, c)
+	assert.False(t, diags.HasError())
+
+	f.inject(fakeFault{Path: "cronjob/id/" + d.Id(), Delay: 5 * time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	diags = r.ReadContext(ctx, d, c)
+
+	assert.True(t, diags.HasError())
+	assert.Contains(t, diags[0].Summary, "context deadline exceeded")
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.NotEqual(t, "", d.Id(), "cronjob must be kept in state if request times out")
+}
@@ -29,6 +29,13 @@ func getStringPtr(s string) *string {
 	return &val
 }
 
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
 func getInt64Ptr(i int64) *int64 {
 	val := i
 	return &val
@@ -0,0 +1,243 @@
+package truenas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"log"
+	"strconv"
+	"time"
+)
+
+// middleware job states, see core.get_jobs
+const (
+	jobStateWaiting = "WAITING"
+	jobStateRunning = "RUNNING"
+	jobStateSuccess = "SUCCESS"
+	jobStateFailed  = "FAILED"
+	jobStateAborted = "ABORTED"
+)
+
+// jobDefaultTimeout is used when waiting for a job without context deadline
+const jobDefaultTimeout = 20 * time.Minute
+
+type jobProgress struct {
+	Percent     *float64 `json:"percent"`
+	Description *string  `json:"description"`
+}
+
+// jobExcInfo describes exception that caused job to fail, for validation errors
+// extra holds [attribute, message, errno] triples
+type jobExcInfo struct {
+	Type  *string         `json:"type"`
+	Extra json.RawMessage `json:"extra"`
+}
+
+// job is a long-running middleware operation, eg. pool.create
+type job struct {
+	ID        int64           `json:"id"`
+	Method    string          `json:"method"`
+	State     string          `json:"state"`
+	Progress  jobProgress     `json:"progress"`
+	Result    json.RawMessage `json:"result"`
+	Error     *string         `json:"error"`
+	Exception *string         `json:"exception"`
+	ExcInfo   *jobExcInfo     `json:"exc_info"`
+}
+
+// jobError is returned when middleware job has failed or was aborted
+type jobError struct {
+	ID        int64
+	Method    string
+	State     string
+	Message   string
+	Exception string
+	Fields    map[string][]apiFieldError
+}
+
+func (e *jobError) Error() string {
+	status := "failed"
+
+	if e.State == jobStateAborted {
+		status = "was aborted"
+	}
+
+	if e.Message == "" {
+		return fmt.Sprintf("job %d (%s) %s", e.ID, e.Method, status)
+	}
+
+	return fmt.Sprintf("job %d (%s) %s: %s", e.ID, e.Method, status, e.Message)
+}
+
+func newJobError(j *job) *jobError {
+	e := &jobError{
+		ID:     j.ID,
+		Method: j.Method,
+		State:  j.State,
+	}
+
+	if j.Error != nil {
+		e.Message = *j.Error
+	}
+
+	if j.Exception != nil {
+		e.Exception = *j.Exception
+	}
+
+	if j.ExcInfo != nil && j.ExcInfo.Type != nil && *j.ExcInfo.Type == "VALIDATION" {
+		e.Fields = validationErrorFields(j.ExcInfo.Extra)
+	}
+
+	return e
+}
+
+// validationErrorFields decodes middleware validation errors passed as [attribute, message, errno] triples
+func validationErrorFields(extra json.RawMessage) map[string][]apiFieldError {
+	var items [][]interface{}
+
+	if err := json.Unmarshal(extra, &items); err != nil {
+		return nil
+	}
+
+	var fields map[string][]apiFieldError
+
+	for _, v := range items {
+		if len(v) < 2 {
+			continue
+		}
+
+		key, _ := v[0].(string)
+		fe := apiFieldError{Message: fmt.Sprint(v[1])}
+
+		if len(v) > 2 {
+			if errno, ok := v[2].(float64); ok {
+				fe.Errno = int(errno)
+			}
+		}
+
+		if fields == nil {
+			fields = make(map[string][]apiFieldError)
+		}
+
+		fields[key] = append(fields[key], fe)
+	}
+
+	return fields
+}
+
+// getJob fetches current job state
+func (c *Client) getJob(ctx context.Context, id int64) (*job, error) {
+	var jobs []job
+
+	err := c.invoke(ctx, "core.get_jobs", []interface{}{queryFilter("id", id)}, &jobs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("job %d not found", id)
+	}
+
+	return &jobs[0], nil
+}
+
+// waitForJob polls middleware until job is finished, job progress is logged on every poll.
+// Waiting stops at context deadline, that usually comes from resource timeouts
+func (c *Client) waitForJob(ctx context.Context, id int64) (*job, error) {
+	timeout := jobDefaultTimeout
+
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	conf := &resource.StateChangeConf{
+		Pending: []string{jobStateWaiting, jobStateRunning},
+		Target:  []string{jobStateSuccess},
+		Refresh: func() (interface{}, string, error) {
+			j, err := c.getJob(ctx, id)
+
+			if err != nil {
+				return nil, "", err
+			}
+
+			logJobProgress(j)
+
+			if j.State == jobStateFailed || j.State == jobStateAborted {
+				return j, j.State, newJobError(j)
+			}
+
+			return j, j.State, nil
+		},
+		Timeout:      timeout,
+		PollInterval: c.jobPollInterval,
+	}
+
+	raw, err := conf.WaitForStateContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return raw.(*job), nil
+}
+
+func logJobProgress(j *job) {
+	msg := fmt.Sprintf("[DEBUG] TrueNAS job %d (%s): %s", j.ID, j.Method, j.State)
+
+	if j.Progress.Percent != nil {
+		msg += fmt.Sprintf(", %.0f%%", *j.Progress.Percent)
+	}
+
+	if j.Progress.Description != nil && *j.Progress.Description != "" {
+		msg += fmt.Sprintf(", %s", *j.Progress.Description)
+	}
+
+	log.Print(msg)
+}
+
+// invokeJob calls middleware method that runs as a job, waits for job to finish and
+// decodes job result into out, unless out is nil
+func (c *Client) invokeJob(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	var id int64
+
+	if err := c.invoke(ctx, method, params, &id); err != nil {
+		return err
+	}
+
+	j, err := c.waitForJob(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	if out != nil && len(j.Result) > 0 {
+		if err := json.Unmarshal(j.Result, out); err != nil {
+			return fmt.Errorf("error decoding job %d result: %s", id, err)
+		}
+	}
+
+	return nil
+}
+
+// invokeOptionalJob calls middleware method that, depending on middleware version and arguments, either
+// returns the final result or runs as a job (eg. dataset delete). Job is waited for if one was started
+func (c *Client) invokeOptionalJob(ctx context.Context, method string, params []interface{}) error {
+	var result json.RawMessage
+
+	if err := c.invoke(ctx, method, params, &result); err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseInt(string(bytes.TrimSpace(result)), 10, 64)
+
+	if err != nil {
+		// not a job
+		return nil
+	}
+
+	_, err = c.waitForJob(ctx, id)
+	return err
+}
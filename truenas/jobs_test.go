@@ -0,0 +1,167 @@
+package truenas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	api "github.com/dariusbakunas/truenas-go-sdk"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return newTestClientForURL(srv.URL)
+}
+
+func newTestClientForURL(serverURL string) *Client {
+	config := api.NewConfiguration()
+	config.Servers = api.ServerConfigurations{
+		{
+			URL: serverURL + "/api/v2.0",
+		},
+	}
+
+	c := newClient(api.NewAPIClient(config))
+	c.jobPollInterval = 10 * time.Millisecond
+
+	return c
+}
+
+// newTestJobHandler responds to core/get_jobs with given job states, one per poll,
+// the last state is repeated once all are consumed
+func newTestJobHandler(t *testing.T, states []map[string]interface{}) http.Handler {
+	polls := 0
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2.0/pool":
+			w.Write([]byte(`42`))
+		case "/api/v2.0/core/get_jobs":
+			assert.Equal(t, "42", r.URL.Query().Get("id"))
+
+			state := states[len(states)-1]
+
+			if polls < len(states) {
+				state = states[polls]
+			}
+
+			polls++
+
+			j := map[string]interface{}{"id": 42, "method": "pool.create"}
+
+			for k, v := range state {
+				j[k] = v
+			}
+
+			json.NewEncoder(w).Encode([]interface{}{j})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestClient_invokeJob(t *testing.T) {
+	c := newTestClient(t, newTestJobHandler(t, []map[string]interface{}{
+		{"state": "WAITING"},
+		{"state": "RUNNING", "progress": map[string]interface{}{"percent": 50, "description": "Creating pool"}},
+		{"state": "SUCCESS", "result": map[string]interface{}{"id": 1, "name": "Tank"}},
+	}))
+
+	var out struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+
+	err := c.invokeJob(context.Background(), "pool.create", []interface{}{map[string]interface{}{"name": "Tank"}}, &out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), out.ID)
+	assert.Equal(t, "Tank", out.Name)
+}
+
+func TestClient_waitForJob_failed(t *testing.T) {
+	c := newTestClient(t, newTestJobHandler(t, []map[string]interface{}{
+		{"state": "RUNNING"},
+		{"state": "FAILED", "error": "[EFAULT] Failed to create pool", "exception": "Traceback (most recent call last): ..."},
+	}))
+
+	_, err := c.waitForJob(context.Background(), 42)
+
+	assert.EqualError(t, err, "job 42 (pool.create) failed: [EFAULT] Failed to create pool")
+	assert.Equal(t, diag.Diagnostics{
+		{
+			Severity: diag.Error,
+			Summary:  "error creating pool: job 42 (pool.create) failed: [EFAULT] Failed to create pool",
+			Detail:   "Traceback (most recent call last): ...",
+		},
+	}, apiErrorDiags(err, "error creating pool", nil))
+}
+
+func TestClient_waitForJob_validationFailed(t *testing.T) {
+	c := newTestClient(t, newTestJobHandler(t, []map[string]interface{}{
+		{
+			"state":    "FAILED",
+			"error":    "[EINVAL] pool_create.name: Invalid pool name",
+			"exc_info": map[string]interface{}{"type": "VALIDATION", "extra": [][]interface{}{{"pool_create.name", "Invalid pool name", 22}}},
+		},
+	}))
+
+	_, err := c.waitForJob(context.Background(), 42)
+
+	assert.Equal(t, diag.Diagnostics{
+		{
+			Severity:      diag.Error,
+			Summary:       "error creating pool: Invalid pool name",
+			Detail:        "pool_create.name: Invalid pool name",
+			AttributePath: cty.GetAttrPath("name"),
+		},
+	}, apiErrorDiags(err, "error creating pool", apiAttrMap{"name": "name"}))
+}
+
+func TestClient_waitForJob_contextDeadline(t *testing.T) {
+	c := newTestClient(t, newTestJobHandler(t, []map[string]interface{}{
+		{"state": "RUNNING"},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.waitForJob(ctx, 42)
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestClient_invokeOptionalJob(t *testing.T) {
+	c := newTestClient(t, newTestJobHandler(t, []map[string]interface{}{
+		{"state": "ABORTED"},
+	}))
+
+	assert.EqualError(t, c.invokeOptionalJob(context.Background(), "pool.create", []interface{}{map[string]interface{}{"name": "Tank"}}), "job 42 (pool.create) was aborted")
+
+	c = newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`true`))
+	}))
+
+	assert.NoError(t, c.invokeOptionalJob(context.Background(), "pool.dataset.delete", []interface{}{"Tank/data"}), "non-job result must be ignored")
+}
+
+func TestClient_call_error(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprint(w, `{"message": "Snapshot not found", "errno": 2}`)
+	}))
+
+	resp, err := c.call(context.Background(), http.MethodGet, "zfs/snapshot/id/Tank@snap", nil, nil)
+
+	assert.True(t, isNotFoundError(resp, err))
+}
@@ -2,10 +2,16 @@ package truenas
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
 	api "github.com/dariusbakunas/truenas-go-sdk"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"golang.org/x/oauth2"
+	"log"
+	"net/http"
+	"time"
 )
 
 // Provider -
@@ -13,11 +19,40 @@ func Provider() *schema.Provider {
 	return &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"api_key": {
-				Type:        schema.TypeString,
-				Description: "TrueNAS API key",
-				Required:    true,
-				Sensitive:   true,
-				DefaultFunc: schema.EnvDefaultFunc("TRUENAS_API_KEY", nil),
+				Type:          schema.TypeString,
+				Description:   "TrueNAS API key. Conflicts with `username` and `password`",
+				Optional:      true,
+				Sensitive:     true,
+				DefaultFunc:   schema.EnvDefaultFunc("TRUENAS_API_KEY", nil),
+				ConflictsWith: []string{"username", "password"},
+			},
+			"username": {
+				Type:         schema.TypeString,
+				Description:  "TrueNAS username for HTTP basic authentication, requires `password`. Useful before any API key exists, eg. on freshly installed system",
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("TRUENAS_USERNAME", nil),
+				RequiredWith: []string{"password"},
+			},
+			"password": {
+				Type:         schema.TypeString,
+				Description:  "TrueNAS password for HTTP basic authentication, requires `username`",
+				Optional:     true,
+				Sensitive:    true,
+				DefaultFunc:  schema.EnvDefaultFunc("TRUENAS_PASSWORD", nil),
+				RequiredWith: []string{"username"},
+			},
+			"generate_token": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Authenticate once and use short-lived authentication token generated by TrueNAS for the rest of the run, instead of sending credentials with every request",
+				DefaultFunc: schema.EnvDefaultFunc("TRUENAS_GENERATE_TOKEN", false),
+			},
+			"token_ttl": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Description:  "Time in seconds generated authentication token stays valid after its last use, only used with `generate_token`",
+				DefaultFunc:  schema.EnvDefaultFunc("TRUENAS_TOKEN_TTL", 600),
+				ValidateFunc: validation.IntAtLeast(1),
 			},
 			"base_url": {
 				Type:        schema.TypeString,
@@ -31,25 +66,109 @@ func Provider() *schema.Provider {
 				Description: "DEBUG: dump all API requests/responses",
 				DefaultFunc: schema.EnvDefaultFunc("TRUENAS_DEBUG", false),
 			},
+			"ca_cert_pem": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM encoded CA certificate(s) used to verify TrueNAS server certificate, in addition to system CA pool. Conflicts with `ca_cert_file`",
+				DefaultFunc: schema.EnvDefaultFunc("TRUENAS_CA_CERT_PEM", ""),
+			},
+			"ca_cert_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to PEM encoded CA certificate(s) file used to verify TrueNAS server certificate, in addition to system CA pool. Conflicts with `ca_cert_pem`",
+				DefaultFunc: schema.EnvDefaultFunc("TRUENAS_CA_CERT_FILE", ""),
+			},
+			"client_cert": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM encoded client certificate for mutual TLS authentication, requires `client_key`",
+				DefaultFunc: schema.EnvDefaultFunc("TRUENAS_CLIENT_CERT", ""),
+			},
+			"client_key": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "PEM encoded client certificate private key, requires `client_cert`",
+				DefaultFunc: schema.EnvDefaultFunc("TRUENAS_CLIENT_KEY", ""),
+			},
+			"max_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Description:  "Maximum number of retries for transient REST API failures (eg. 502/503 responses while middleware restarts), `0` disables retries. Websocket calls are not retried, broken connection is re-established on the next call",
+				DefaultFunc:  schema.EnvDefaultFunc("TRUENAS_MAX_RETRIES", 4),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"retry_min_wait": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Description:  "Minimum time in seconds to wait before retrying failed request",
+				DefaultFunc:  schema.EnvDefaultFunc("TRUENAS_RETRY_MIN_WAIT", 1),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"retry_max_wait": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Description:  "Maximum time in seconds to wait before retrying failed request",
+				DefaultFunc:  schema.EnvDefaultFunc("TRUENAS_RETRY_MAX_WAIT", 30),
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"max_concurrent_requests": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Description:  "Maximum number of concurrent API requests shared by all resources and data sources, websocket calls included, `0` means no limit",
+				DefaultFunc:  schema.EnvDefaultFunc("TRUENAS_MAX_CONCURRENT_REQUESTS", 0),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"transport": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "Experimental: transport used to call middleware methods: `rest` or `websocket` (JSON-RPC 2.0 or legacy DDP protocol). Used by all resources and data sources, except `truenas_network_configuration`, `truenas_pool_ids` and `truenas_service` data sources, that always use REST API",
+				DefaultFunc:  schema.EnvDefaultFunc("TRUENAS_TRANSPORT", "rest"),
+				ValidateFunc: validation.StringInSlice([]string{"rest", "websocket"}, false),
+			},
+			"websocket_url": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "TrueNAS websocket API URL, eg. wss://your.nas/api/current. Legacy DDP protocol is used if URL path ends with `/websocket`. Derived from `base_url` if not set",
+				DefaultFunc: schema.EnvDefaultFunc("TRUENAS_WEBSOCKET_URL", ""),
+			},
+			"insecure_skip_verify": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Skip TrueNAS server certificate verification, not recommended outside of testing",
+				DefaultFunc: schema.EnvDefaultFunc("TRUENAS_INSECURE_SKIP_VERIFY", false),
+			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"truenas_cronjob":   resourceTrueNASCronjob(),
-			"truenas_dataset":   resourceTrueNASDataset(),
-			"truenas_share_nfs": resourceTrueNASShareNFS(),
-			"truenas_share_smb": resourceTrueNASShareSMB(),
-			"truenas_zvol":      resourceTrueNASZVOL(),
-			"truenas_vm":        resourceTrueNASVM(),
+			"truenas_cronjob":                resourceTrueNASCronjob(),
+			"truenas_dataset":                resourceTrueNASDataset(),
+			"truenas_dataset_permissions":    resourceTrueNASDatasetPermissions(),
+			"truenas_dataset_rollback":       resourceTrueNASDatasetRollback(),
+			"truenas_dataset_user_quota":     resourceTrueNASDatasetUserQuota(),
+			"truenas_periodic_snapshot_task": resourceTrueNASPeriodicSnapshotTask(),
+			"truenas_pool":                   resourceTrueNASPool(),
+			"truenas_replication_task":       resourceTrueNASReplicationTask(),
+			"truenas_share_nfs":              resourceTrueNASShareNFS(),
+			"truenas_share_smb":              resourceTrueNASShareSMB(),
+			"truenas_snapshot":               resourceTrueNASSnapshot(),
+			"truenas_zvol":                   resourceTrueNASZVOL(),
+			"truenas_vm":                     resourceTrueNASVM(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
-			"truenas_cronjob":               dataSourceTrueNASCronjob(),
-			"truenas_dataset":               dataSourceTrueNASDataset(),
-			"truenas_network_configuration": dataSourceTrueNASNetworkConfiguration(),
-			"truenas_pool_ids":              dataSourceTrueNASPoolIDs(),
-			"truenas_service":               dataSourceTrueNASService(),
-			"truenas_share_nfs":             dataSourceTrueNASShareNFS(),
-			"truenas_share_smb":             dataSourceTrueNASShareSMB(),
-			"truenas_vm":                    dataSourceTrueNASVM(),
-			"truenas_zvol":                  dataSourceTrueNASZVOL(),
+			"truenas_cronjob":                dataSourceTrueNASCronjob(),
+			"truenas_dataset":                dataSourceTrueNASDataset(),
+			"truenas_dataset_encryption_key": dataSourceTrueNASDatasetEncryptionKey(),
+			"truenas_network_configuration":  dataSourceTrueNASNetworkConfiguration(),
+			"truenas_periodic_snapshot_task": dataSourceTrueNASPeriodicSnapshotTask(),
+			"truenas_pool":                   dataSourceTrueNASPool(),
+			"truenas_pool_ids":               dataSourceTrueNASPoolIDs(),
+			"truenas_pools":                  dataSourceTrueNASPools(),
+			"truenas_service":                dataSourceTrueNASService(),
+			"truenas_share_nfs":              dataSourceTrueNASShareNFS(),
+			"truenas_share_smb":              dataSourceTrueNASShareSMB(),
+			"truenas_system_info":            dataSourceTrueNASSystemInfo(),
+			"truenas_vm":                     dataSourceTrueNASVM(),
+			"truenas_zvol":                   dataSourceTrueNASZVOL(),
 		},
 		ConfigureContextFunc: providerConfigure,
 	}
@@ -57,16 +176,128 @@ func Provider() *schema.Provider {
 
 func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	apiKey := d.Get("api_key").(string)
+	username := d.Get("username").(string)
+	password := d.Get("password").(string)
 	baseURL := d.Get("base_url").(string)
 	debug := d.Get("debug").(bool)
 
 	// Warning or errors can be collected in a slice type
 	var diags diag.Diagnostics
 
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: apiKey},
-	)
-	tc := oauth2.NewClient(ctx, ts)
+	if apiKey != "" && username != "" {
+		return nil, diag.Errorf("only one of api_key or username and password can be set")
+	}
+
+	if apiKey == "" && (username == "" || password == "") {
+		return nil, diag.Errorf("either api_key or username and password must be set")
+	}
+
+	transport, err := newTransport(tlsOptions{
+		CACertPEM:          d.Get("ca_cert_pem").(string),
+		CACertFile:         d.Get("ca_cert_file").(string),
+		ClientCert:         d.Get("client_cert").(string),
+		ClientKey:          d.Get("client_key").(string),
+		InsecureSkipVerify: d.Get("insecure_skip_verify").(bool),
+	})
+
+	if err != nil {
+		return nil, diag.Errorf("error configuring TLS: %s", err)
+	}
+
+	minWait := d.Get("retry_min_wait").(int)
+	maxWait := d.Get("retry_max_wait").(int)
+
+	if minWait > maxWait {
+		return nil, diag.Errorf("retry_min_wait (%d) cannot be greater than retry_max_wait (%d)", minWait, maxWait)
+	}
+
+	var base http.RoundTripper = transport
+	var limiter *limitTransport
+
+	if limit := d.Get("max_concurrent_requests").(int); limit > 0 {
+		limiter = newLimitTransport(transport, limit)
+		base = limiter
+	}
+
+	retry := newRetryTransport(base, retryOptions{
+		MaxRetries: d.Get("max_retries").(int),
+		MinWait:    time.Duration(minWait) * time.Second,
+		MaxWait:    time.Duration(maxWait) * time.Second,
+	})
+
+	// oauth2 client wraps transport of the client passed in context
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: retry})
+
+	token := &oauth2.Token{AccessToken: apiKey}
+
+	if apiKey == "" {
+		token = &oauth2.Token{
+			AccessToken: base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+			TokenType:   "Basic",
+		}
+	}
+
+	c := newClient(api.NewAPIClient(newAPIConfiguration(ctx, baseURL, debug, token)))
+
+	if d.Get("generate_token").(bool) {
+		authToken, err := c.generateAuthToken(ctx, d.Get("token_ttl").(int))
+
+		if err != nil {
+			return nil, diag.Errorf("error generating authentication token: %s", err)
+		}
+
+		token = &oauth2.Token{AccessToken: authToken, TokenType: "Token"}
+		c = newClient(api.NewAPIClient(newAPIConfiguration(ctx, baseURL, debug, token)))
+	}
+
+	if d.Get("transport").(string) == "websocket" {
+		wsURL := d.Get("websocket_url").(string)
+
+		if wsURL == "" {
+			wsURL, err = websocketURL(baseURL)
+
+			if err != nil {
+				return nil, diag.Errorf("error building websocket URL from base_url: %s", err)
+			}
+		}
+
+		caller, err := newWebsocketCaller(wsURL, transport.TLSClientConfig, wsCredentials{
+			APIKey:   apiKey,
+			Username: username,
+			Password: password,
+		})
+
+		if err != nil {
+			return nil, diag.FromErr(err)
+		}
+
+		c.caller = caller
+
+		if limiter != nil {
+			c.caller = newLimitCaller(caller, limiter)
+		}
+	}
+
+	system, err := c.detectSystem(ctx)
+
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Unable to detect TrueNAS version",
+			Detail:   fmt.Sprintf("Attributes will not be validated against TrueNAS capabilities at plan time: %s", err),
+		})
+	} else {
+		log.Printf("[INFO] Connected to %s (%s)", system, system.Version)
+		c.system = system
+	}
+
+	return c, diags
+}
+
+// newAPIConfiguration creates API client configuration, every request is authenticated with given token,
+// token type is used as Authorization header scheme (Bearer for API keys, Basic or Token)
+func newAPIConfiguration(ctx context.Context, baseURL string, debug bool, token *oauth2.Token) *api.Configuration {
+	tc := oauth2.NewClient(ctx, oauth2.StaticTokenSource(token))
 
 	config := api.NewConfiguration()
 	config.Servers = api.ServerConfigurations{
@@ -77,6 +308,5 @@ func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}
 	config.Debug = debug
 	config.HTTPClient = tc
 
-	c := api.NewAPIClient(config)
-	return c, diags
+	return config
 }
@@ -1,8 +1,14 @@
 package truenas
 
 import (
+	"context"
+	"encoding/json"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 )
@@ -31,10 +37,135 @@ func init() {
 }
 
 func testAccPreCheck(t *testing.T) {
-	if v := os.Getenv("TRUENAS_API_KEY"); v == "" {
-		t.Fatal("TRUENAS_API_KEY must be set for acceptance tests")
+	if os.Getenv("TRUENAS_API_KEY") == "" && (os.Getenv("TRUENAS_USERNAME") == "" || os.Getenv("TRUENAS_PASSWORD") == "") {
+		t.Fatal("TRUENAS_API_KEY or TRUENAS_USERNAME and TRUENAS_PASSWORD must be set for acceptance tests")
 	}
 	if v := os.Getenv("TRUENAS_BASE_URL"); v == "" {
 		t.Fatal("TRUENAS_BASE_URL must be set for acceptance tests")
 	}
 }
+
+// newTestAuthServer serves pool list to requests authenticated with expected Authorization header,
+// and generates token for requests authenticated with basic auth
+func newTestAuthServer(t *testing.T, expectedAuth string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2.0/auth/generate_token":
+			if user, pass, ok := r.BasicAuth(); !ok || user != "root" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, float64(300), body["ttl"])
+
+			w.Write([]byte(`"generated-token"`))
+		case "/api/v2.0/pool":
+			if r.Header.Get("Authorization") != expectedAuth {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			w.Write([]byte(`[{"id": 1, "name": "Tank", "path": "/mnt/Tank"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProvider_basicAuth(t *testing.T) {
+	srv := newTestAuthServer(t, "Basic cm9vdDpzZWNyZXQ=")
+
+	err := testProviderListPools(t, map[string]interface{}{
+		"base_url": srv.URL + "/api/v2.0",
+		"username": "root",
+		"password": "secret",
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestProvider_generateToken(t *testing.T) {
+	srv := newTestAuthServer(t, "Token generated-token")
+
+	err := testProviderListPools(t, map[string]interface{}{
+		"base_url":       srv.URL + "/api/v2.0",
+		"username":       "root",
+		"password":       "secret",
+		"generate_token": true,
+		"token_ttl":      300,
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestProvider_authRequired(t *testing.T) {
+	t.Setenv("TRUENAS_API_KEY", "")
+	t.Setenv("TRUENAS_USERNAME", "")
+	t.Setenv("TRUENAS_PASSWORD", "")
+
+	testcases := []map[string]interface{}{
+		{"base_url": "http://127.0.0.1/api/v2.0"},
+		{"base_url": "http://127.0.0.1/api/v2.0", "api_key": "key", "username": "root", "password": "secret"},
+	}
+
+	for _, raw := range testcases {
+		d := schema.TestResourceDataRaw(t, Provider().Schema, raw)
+		_, diags := providerConfigure(context.Background(), d)
+
+		assert.True(t, diags.HasError(), "%v", raw)
+	}
+}
+
+func TestUnitProvider_websocketTransport(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	config := f.websocketProviderConfig(t)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(
+			testAccCheckFakeDestroy(f, "truenas_share_smb", "sharing/smb"),
+			testAccCheckFakeDestroy(f, "truenas_share_nfs", "sharing/nfs"),
+			testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+			testAccCheckFakeDestroy(f, "truenas_zvol", "pool/dataset"),
+			testAccCheckFakeDestroy(f, "truenas_vm", "vm"),
+			testAccCheckFakeDestroy(f, "truenas_cronjob", "cronjob"),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: config +
+					testAccCheckResourceTruenasShareSMBConfig(fakePoolName, "smb") +
+					testUnitResourceTruenasZVOLConfig(fakePoolName, 1073741824) +
+					testUnitResourceTruenasVMConfig("Test VM", 1) +
+					testUnitResourceTruenasCronjobConfig("echo test", "30"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("truenas_dataset.test", "mount_point", "/mnt/Tank/smb"),
+					resource.TestCheckResourceAttr("truenas_share_smb.smb", "path", "/mnt/Tank/smb"),
+					resource.TestCheckResourceAttr("truenas_zvol.test", "volsize", "1073741824"),
+					resource.TestCheckResourceAttr("truenas_vm.test", "vcpus", "1"),
+					resource.TestCheckResourceAttr("truenas_cronjob.test", "command", "echo test"),
+				),
+			},
+			{
+				Config: config +
+					testAccCheckResourceTruenasShareNFSConfig(fakePoolName, "smb") +
+					testUnitResourceTruenasZVOLConfig(fakePoolName, 2147483648) +
+					testUnitResourceTruenasVMConfig("Updated VM", 2) +
+					testUnitResourceTruenasCronjobConfig("echo updated", "45"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("truenas_share_nfs.nfs", "paths.0", "/mnt/Tank/smb"),
+					resource.TestCheckResourceAttr("truenas_share_nfs.nfs", "comment", "Testing NFS share"),
+					resource.TestCheckResourceAttr("truenas_zvol.test", "volsize", "2147483648"),
+					resource.TestCheckResourceAttr("truenas_vm.test", "vcpus", "2"),
+					resource.TestCheckResourceAttr("truenas_cronjob.test", "command", "echo updated"),
+				),
+			},
+		},
+	})
+}
@@ -5,9 +5,20 @@ import (
 	api "github.com/dariusbakunas/truenas-go-sdk"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"log"
 	"strconv"
 )
 
+var cronjobAPIAttrs = apiAttrMap{
+	"user":        "user",
+	"command":     "command",
+	"description": "description",
+	"enabled":     "enabled",
+	"stdout":      "hide_stdout",
+	"stderr":      "hide_stderr",
+	"schedule":    "schedule",
+}
+
 func resourceTrueNASCronjob() *schema.Resource {
 	return &schema.Resource{
 		Description:   "TrueNAS allows users to run specific commands or scripts on a regular schedule using cron(8). This can be helpful for running repetitive tasks.",
@@ -99,7 +110,7 @@ func resourceTrueNASCronjob() *schema.Resource {
 func resourceTrueNASCronjobRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 
 	id, err := strconv.Atoi(d.Id())
 
@@ -107,14 +118,16 @@ func resourceTrueNASCronjobRead(ctx context.Context, d *schema.ResourceData, m i
 		return diag.FromErr(err)
 	}
 
-	resp, _, err := c.CronjobApi.GetCronJob(ctx, int32(id)).Execute()
+	var resp api.CronJob
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
+	if err := c.invoke(ctx, "cronjob.get_instance", []interface{}{id}, &resp); err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS cronjob (%d) not found, removing from state", id)
+			d.SetId("")
+			return nil
 		}
-		return diag.Errorf("error getting cronjob: %s\n%s", err, body)
+		return apiErrorDiags(err, "error getting cronjob", nil)
 	}
 
 	d.Set("cronjob_id", strconv.Itoa(int(*resp.Id)))
@@ -145,19 +158,13 @@ func resourceTrueNASCronjobRead(ctx context.Context, d *schema.ResourceData, m i
 }
 
 func resourceTrueNASCronjobCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	job := expandJobInput(d)
 
-	resp, _, err := c.CronjobApi.CreateCronJob(ctx).
-		CreateCronjobParams(job).
-		Execute()
+	var resp api.CronJob
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error creating cronjob: %s\n%s", err, body)
+	if err := c.invoke(ctx, "cronjob.create", []interface{}{job}, &resp); err != nil {
+		return apiErrorDiags(err, "error creating cronjob", cronjobAPIAttrs)
 	}
 
 	d.SetId(strconv.Itoa(int(*resp.Id)))
@@ -166,7 +173,7 @@ func resourceTrueNASCronjobCreate(ctx context.Context, d *schema.ResourceData, m
 }
 
 func resourceTrueNASCronjobUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	job := expandJobInput(d)
 
 	id, err := strconv.Atoi(d.Id())
@@ -175,14 +182,8 @@ func resourceTrueNASCronjobUpdate(ctx context.Context, d *schema.ResourceData, m
 		return diag.FromErr(err)
 	}
 
-	_, _, err = c.CronjobApi.UpdateCronJob(ctx, int32(id)).CreateCronjobParams(job).Execute()
-
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error updating cronjob: %s\n%s", err, body)
+	if err := c.invoke(ctx, "cronjob.update", []interface{}{id, job}, nil); err != nil {
+		return apiErrorDiags(err, "error updating cronjob", cronjobAPIAttrs)
 	}
 
 	return resourceTrueNASCronjobRead(ctx, d, m)
@@ -191,7 +192,7 @@ func resourceTrueNASCronjobUpdate(ctx context.Context, d *schema.ResourceData, m
 func resourceTrueNASCronjobDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 
 	id, err := strconv.Atoi(d.Id())
 
@@ -199,14 +200,8 @@ func resourceTrueNASCronjobDelete(ctx context.Context, d *schema.ResourceData, m
 		return diag.FromErr(err)
 	}
 
-	_, err = c.CronjobApi.DeleteCronJob(ctx, int32(id)).Execute()
-
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error deleting cronjob: %s\n%s", err, body)
+	if err := c.invoke(ctx, "cronjob.delete", []interface{}{id}, nil); err != nil {
+		return apiErrorDiags(err, "error deleting cronjob", nil)
 	}
 	d.SetId("")
 
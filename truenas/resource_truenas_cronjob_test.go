@@ -0,0 +1,59 @@
+package truenas
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"testing"
+)
+
+func TestUnitResourceTruenasCronjob_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_cronjob.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_cronjob", "cronjob"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasCronjobConfig("echo test", "30"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "user", "root"),
+					resource.TestCheckResourceAttr(resourceName, "command", "echo test"),
+					resource.TestCheckResourceAttr(resourceName, "enabled", "true"),
+					resource.TestCheckResourceAttr(resourceName, "hide_stdout", "true"),
+					resource.TestCheckResourceAttr(resourceName, "hide_stderr", "false"),
+					resource.TestCheckResourceAttr(resourceName, "schedule.0.minute", "30"),
+					resource.TestCheckResourceAttr(resourceName, "schedule.0.hour", "*"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasCronjobConfig("echo updated", "45"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "command", "echo updated"),
+					resource.TestCheckResourceAttr(resourceName, "schedule.0.minute", "45"),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func testUnitResourceTruenasCronjobConfig(command string, minute string) string {
+	return fmt.Sprintf(`
+	resource "truenas_cronjob" "test" {
+		user = "root"
+		command = "%s"
+		description = "Test cronjob"
+		enabled = true
+		schedule {
+			minute = "%s"
+		}
+	}
+	`, command, minute)
+}
@@ -26,11 +26,49 @@ var supportedCompression = []string{"off", "lz4", "gzip", "gzip-1", "gzip-9", "z
 var encryptionAlgorithms = []string{"AES-128-CCM", "AES-192-CCM", "AES-256-CCM", "AES-128-GCM", "AES-192-GCM", "AES-256-GCM"}
 var recordSizes = []string{"512", "1K", "2K", "4K", "8K", "16K", "32K", "64K", "128K", "256K", "512K", "1024K"}
 
+var datasetAPIAttrs = apiAttrMap{
+	"name":                            "name",
+	"new_name":                        "name",
+	"aclmode":                         "acl_mode",
+	"atime":                           "atime",
+	"casesensitivity":                 "case_sensitivity",
+	"comments":                        "comments",
+	"compression":                     "compression",
+	"copies":                          "copies",
+	"deduplication":                   "deduplication",
+	"encryption":                      "encrypted",
+	"inherit_encryption":              "inherit_encryption",
+	"encryption_options.algorithm":    "encryption_algorithm",
+	"encryption_options.generate_key": "generate_key",
+	"encryption_options.key":          "encryption_key",
+	"encryption_options.passphrase":   "passphrase",
+	"encryption_options.pbkdf2iters":  "pbkdf2iters",
+	"exec":                            "exec",
+	"quota":                           "quota_bytes",
+	"quota_critical":                  "quota_critical",
+	"quota_warning":                   "quota_warning",
+	"refquota":                        "ref_quota_bytes",
+	"refquota_critical":               "ref_quota_critical",
+	"refquota_warning":                "ref_quota_warning",
+	"readonly":                        "readonly",
+	"recordsize":                      "record_size",
+	"share_type":                      "share_type",
+	"sync":                            "sync",
+	"snapdir":                         "snap_dir",
+	"user_properties":                 "user_properties",
+	"user_properties_update":          "user_properties",
+}
+
 // newDatasetPath creates new datasetPath struct
 // from TrueNAS dataset ID string, that comes in format: Pool/Parent/dataset_name
 func newDatasetPath(id string) datasetPath {
 	s := strings.Split(id, "/")
 
+	if len(s) == 1 {
+		// pool root dataset
+		return datasetPath{Pool: s[0]}
+	}
+
 	if len(s) == 2 {
 		// there is no Parent
 		return datasetPath{Pool: s[0], Name: s[1], Parent: ""}
@@ -41,6 +79,10 @@ func newDatasetPath(id string) datasetPath {
 }
 
 func (d datasetPath) String() string {
+	if d.Name == "" {
+		return d.Pool
+	}
+
 	if d.Parent == "" {
 		return fmt.Sprintf("%s/%s", d.Pool, d.Name)
 	} else {
@@ -50,11 +92,12 @@ func (d datasetPath) String() string {
 
 func resourceTrueNASDataset() *schema.Resource {
 	return &schema.Resource{
-		Description:   "A TrueNAS dataset is a file system that is created within a data storage pool. Datasets can contain files, directories (child datasets), and have individual permissions or flags",
+		Description:   "A TrueNAS dataset is a file system that is created within a data storage pool. Datasets can contain files, directories (child datasets), and have individual permissions or flags. Inheritable properties, except numeric `copies`, accept `inherit`, removing locally set property from configuration resets it to inherited value as well",
 		CreateContext: resourceTrueNASDatasetCreate,
 		ReadContext:   resourceTrueNASDatasetRead,
 		UpdateContext: resourceTrueNASDatasetUpdate,
 		DeleteContext: resourceTrueNASDatasetDelete,
+		CustomizeDiff: resourceTrueNASDatasetCustomizeDiff,
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
@@ -75,17 +118,35 @@ func resourceTrueNASDataset() *schema.Resource {
 				ForceNew:     true,
 			},
 			"parent": &schema.Schema{
-				Type:     schema.TypeString,
-				Optional: true,
-				Default:  "",
-				ForceNew: true,
+				Description: "Parent dataset path within the pool, changing it moves the dataset along with its children",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
 			},
 			"name": &schema.Schema{
-				Description:  "Unique identifier for the dataset. Cannot be changed after the dataset is created.",
+				Description:  "Dataset name, changing it renames the dataset in place",
 				Type:         schema.TypeString,
 				Required:     true,
 				ValidateFunc: validation.StringDoesNotContainAny("/"),
-				ForceNew:     true,
+			},
+			"clone_from_snapshot": &schema.Schema{
+				Description:   "Create dataset as a clone of snapshot, eg. Tank/data@base. Case sensitivity, share type and encryption are inherited from the snapshot",
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"case_sensitivity", "share_type", "encrypted", "encryption_algorithm", "passphrase", "encryption_key", "generate_key"},
+				ValidateFunc:  validation.StringMatch(regexp.MustCompile("^[^@]+@[^@]+$"), "snapshot must be in format pool/dataset@name"),
+			},
+			"origin": &schema.Schema{
+				Description: "Snapshot the dataset was cloned from, empty once clone is promoted",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"promote": &schema.Schema{
+				Description:  "Promote clone, so that it no longer depends on origin snapshot. Origin dataset becomes a clone of the promoted dataset, promotion can not be reverted",
+				Type:         schema.TypeBool,
+				Optional:     true,
+				RequiredWith: []string{"clone_from_snapshot"},
 			},
 			"acl_mode": &schema.Schema{
 				Type:          schema.TypeString,
@@ -93,18 +154,22 @@ func resourceTrueNASDataset() *schema.Resource {
 				Optional:      true,
 				Computed:      true,
 				ConflictsWith: []string{"share_type"},
-				ValidateFunc:  validation.StringInSlice([]string{"passthrough", "restricted"}, false),
+				ValidateFunc:  validation.StringInSlice([]string{"passthrough", "restricted", "inherit"}, false),
 			},
 			"acl_type": &schema.Schema{
-				Type:     schema.TypeString,
-				Computed: true,
+				Description:   "ACL type: `posix`, `nfsv4` or `off`",
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				ConflictsWith: []string{"share_type"},
+				ValidateFunc:  validation.StringInSlice(withInherit(aclTypes), false),
 			},
 			"atime": &schema.Schema{
 				Type:         schema.TypeString,
 				Description:  "Choose 'on' to update the access time for files when they are read. Choose 'off' to prevent producing log traffic when reading files",
 				Optional:     true,
 				Computed:     true,
-				ValidateFunc: validation.StringInSlice([]string{"on", "off"}, false),
+				ValidateFunc: validation.StringInSlice([]string{"on", "off", "inherit"}, false),
 			},
 			"case_sensitivity": &schema.Schema{
 				Type:          schema.TypeString,
@@ -123,9 +188,17 @@ func resourceTrueNASDataset() *schema.Resource {
 				Type:         schema.TypeString,
 				Optional:     true,
 				Computed:     true,
-				ValidateFunc: validation.StringInSlice(supportedCompression, false),
+				ValidateFunc: validation.StringInSlice(withInherit(supportedCompression), false),
+			},
+			"checksum": &schema.Schema{
+				Description:  "Checksum algorithm used to verify data integrity",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(checksumAlgorithms), false),
 			},
 			"copies": &schema.Schema{
+				Description:  "Number of data copies: 1, 2 or 3. Unlike string properties it does not accept `inherit`, remove it from config to inherit the value from parent dataset",
 				Type:         schema.TypeInt,
 				Optional:     true,
 				Computed:     true,
@@ -135,7 +208,14 @@ func resourceTrueNASDataset() *schema.Resource {
 				Type:         schema.TypeString,
 				Optional:     true,
 				Computed:     true,
-				ValidateFunc: validation.StringInSlice([]string{"on", "off", "verify"}, false),
+				ValidateFunc: validation.StringInSlice([]string{"on", "off", "verify", "inherit"}, false),
+			},
+			"dnode_size": &schema.Schema{
+				Description:  "Size of dnodes, `auto` is recommended with `xattr = \"sa\"`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(dnodeSizes), false),
 			},
 			"encrypted": &schema.Schema{
 				Type:     schema.TypeBool,
@@ -144,9 +224,9 @@ func resourceTrueNASDataset() *schema.Resource {
 				Computed: true,
 			},
 			"inherit_encryption": &schema.Schema{
-				Type:     schema.TypeBool,
-				ForceNew: true,
-				Optional: true,
+				Description: "Inherit encryption root of the parent dataset. Setting it to `false` on existing dataset makes it encryption root with its own key or passphrase",
+				Type:        schema.TypeBool,
+				Optional:    true,
 			},
 			"encryption_algorithm": &schema.Schema{
 				Type:         schema.TypeString,
@@ -158,36 +238,63 @@ func resourceTrueNASDataset() *schema.Resource {
 			"pbkdf2iters": &schema.Schema{
 				Type: schema.TypeInt,
 				//ConflictsWith: []string{"encryption_options.key"},
-				ForceNew: true,
 				Optional: true,
 				Computed: true,
 			},
 			"passphrase": &schema.Schema{
-				Type:      schema.TypeString,
-				Optional:  true,
-				ForceNew:  true,
-				Sensitive: true,
+				Description: "Encryption passphrase, changing it changes the key of existing dataset in place",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
 			},
 			"encryption_key": &schema.Schema{
+				Description:   "Hex encoded encryption key, changing it changes the key of existing dataset in place",
 				Type:          schema.TypeString,
 				ConflictsWith: []string{"passphrase"},
 				ValidateFunc:  validation.StringMatch(regexp.MustCompile("^[a-fA-F0-9]+$"), "key must be in hexadecimal format"),
 				Optional:      true,
-				ForceNew:      true,
 				Computed:      true,
 				Sensitive:     true,
 			},
 			"generate_key": &schema.Schema{
-				Type:     schema.TypeBool,
-				Optional: true,
-				ForceNew: true,
-				Computed: true,
+				Description: "Generate encryption key, switching it to true on existing dataset replaces its key with a generated one, switching it off keeps current key",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+			},
+			"encryption_root": &schema.Schema{
+				Description: "Dataset that holds encryption key of this dataset",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"key_format": &schema.Schema{
+				Description: "Encryption key format: hex or passphrase",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"key_loaded": &schema.Schema{
+				Description: "`true` if encryption key is loaded",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"locked": &schema.Schema{
+				Description: "Lock or unlock passphrase encrypted dataset, `passphrase` is used to unlock it. Only encryption roots can be locked",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
 			},
 			"exec": &schema.Schema{
 				Type:         schema.TypeString,
 				Optional:     true,
 				Computed:     true,
-				ValidateFunc: validation.StringInSlice([]string{"on", "off"}, false),
+				ValidateFunc: validation.StringInSlice([]string{"on", "off", "inherit"}, false),
+			},
+			"log_bias": &schema.Schema{
+				Description:  "Synchronous write handling: `latency` uses log devices, `throughput` writes to pool directly",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(logBiasModes), false),
 			},
 			"managed_by": &schema.Schema{
 				Type:     schema.TypeString,
@@ -197,6 +304,21 @@ func resourceTrueNASDataset() *schema.Resource {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"primary_cache": &schema.Schema{
+				Description:  "What is cached in ARC: `all`, `metadata` or `none`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(cacheModes), false),
+			},
+			"property_sources": &schema.Schema{
+				Description: "Source of ZFS properties keyed by property name: `local`, `inherited` or `default`",
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 			"quota_bytes": &schema.Schema{
 				Type:     schema.TypeInt,
 				Computed: true,
@@ -235,13 +357,20 @@ func resourceTrueNASDataset() *schema.Resource {
 				Type:         schema.TypeString,
 				Optional:     true,
 				Computed:     true,
-				ValidateFunc: validation.StringInSlice([]string{"on", "off"}, false),
+				ValidateFunc: validation.StringInSlice([]string{"on", "off", "inherit"}, false),
 			},
 			"record_size": &schema.Schema{
 				Type:         schema.TypeString,
 				Optional:     true,
 				Computed:     true,
-				ValidateFunc: validation.StringInSlice(recordSizes, false),
+				ValidateFunc: validation.StringInSlice(withInherit(recordSizes), false),
+			},
+			"secondary_cache": &schema.Schema{
+				Description:  "What is cached in L2ARC: `all`, `metadata` or `none`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(cacheModes), false),
 			},
 			"share_type": &schema.Schema{
 				Type:         schema.TypeString,
@@ -250,6 +379,13 @@ func resourceTrueNASDataset() *schema.Resource {
 				ForceNew:     true,
 				ValidateFunc: validation.StringInSlice([]string{"generic", "smb"}, false),
 			},
+			"special_small_block_size": &schema.Schema{
+				Description:  "Blocks up to this size are stored on special allocation class vdevs, `0` disables it",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(specialSmallBlockSizes), false),
+			},
 			"sync": &schema.Schema{
 				Type:         schema.TypeString,
 				Description:  "Sets the data write synchronization. `inherit` takes the sync settings from the parent dataset, `standard` uses the settings that have been requested by the client software, `always` waits for data writes to complete, and `disabled` never waits for writes to complete.",
@@ -261,51 +397,115 @@ func resourceTrueNASDataset() *schema.Resource {
 				Type:         schema.TypeString,
 				Optional:     true,
 				Computed:     true,
-				ValidateFunc: validation.StringInSlice([]string{"visible", "hidden"}, false),
+				ValidateFunc: validation.StringInSlice([]string{"visible", "hidden", "inherit"}, false),
+			},
+			"user_properties": &schema.Schema{
+				Description:  "ZFS user properties, eg. `org:owner`. Only properties declared here are managed, removing property from the map removes it from the dataset. Declared properties are always set locally, even if parent dataset has the same value",
+				Type:         schema.TypeMap,
+				Optional:     true,
+				ValidateFunc: validateUserProperties,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"xattr": &schema.Schema{
+				Description:  "Extended attributes storage: `sa` stores them in inodes, `on` in hidden directories",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(xattrModes), false),
 			},
 		},
 	}
 }
 
 func resourceTrueNASDatasetCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
+
+	if _, ok := d.GetOk("clone_from_snapshot"); ok {
+		return resourceTrueNASDatasetClone(ctx, d, m)
+	}
 
 	input := expandDataset(d)
 
 	log.Printf("[DEBUG] Creating TrueNAS dataset: %+v", input)
 
-	resp, _, err := c.DatasetApi.CreateDataset(ctx).CreateDatasetParams(input).Execute()
+	var resp api.Dataset
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error creating dataset: %s\n%s", err, body)
+	if err := c.invoke(ctx, "pool.dataset.create", []interface{}{input}, &resp); err != nil {
+		return apiErrorDiags(err, "error creating dataset", datasetAPIAttrs)
 	}
 
 	d.SetId(resp.Id)
 
 	log.Printf("[INFO] TrueNAS dataset (%s) created", resp.Id)
 
+	if d.Get("locked").(bool) {
+		if err := lockDataset(ctx, c, resp.Id); err != nil {
+			return apiErrorDiags(err, "error locking dataset", nil)
+		}
+	}
+
+	return resourceTrueNASDatasetRead(ctx, d, m)
+}
+
+// resourceTrueNASDatasetClone clones snapshot and sets remaining properties with update, since clone
+// only copies properties of origin
+func resourceTrueNASDatasetClone(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	id := datasetPath{
+		Pool:   d.Get("pool").(string),
+		Parent: d.Get("parent").(string),
+		Name:   d.Get("name").(string),
+	}.String()
+
+	if err := cloneSnapshot(ctx, c, d.Get("clone_from_snapshot").(string), id); err != nil {
+		return apiErrorDiags(err, "error cloning snapshot", datasetAPIAttrs)
+	}
+
+	d.SetId(id)
+
+	input := expandDatasetForUpdate(d)
+
+	log.Printf("[DEBUG] Updating TrueNAS dataset clone: %+v", input)
+
+	if err := c.invoke(ctx, "pool.dataset.update", []interface{}{id, input}, nil); err != nil {
+		return apiErrorDiags(err, "error updating dataset clone", datasetAPIAttrs)
+	}
+
+	if d.Get("promote").(bool) {
+		if err := promoteDataset(ctx, c, id); err != nil {
+			return apiErrorDiags(err, "error promoting dataset", nil)
+		}
+	}
+
+	if d.Get("locked").(bool) {
+		if err := lockDataset(ctx, c, id); err != nil {
+			return apiErrorDiags(err, "error locking dataset", nil)
+		}
+	}
+
 	return resourceTrueNASDatasetRead(ctx, d, m)
 }
 
 func resourceTrueNASDatasetRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 
 	id := d.Id()
 
-	resp, _, err := c.DatasetApi.GetDataset(ctx, id).Execute()
+	var resp api.Dataset
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
+	if err := c.invoke(ctx, "pool.dataset.get_instance", []interface{}{id}, &resp); err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS dataset (%s) not found, removing from state", id)
+			d.SetId("")
+			return nil
 		}
-		return diag.Errorf("error getting dataset: %s\n%s", err, body)
+		return apiErrorDiags(err, "error getting dataset", nil)
 	}
 
 	dpath := newDatasetPath(resp.Id)
@@ -319,16 +519,16 @@ func resourceTrueNASDatasetRead(ctx context.Context, d *schema.ResourceData, m i
 		d.Set("mount_point", *resp.Mountpoint)
 	}
 
-	if resp.Aclmode != nil && resp.Aclmode.Value != nil {
-		d.Set("acl_mode", strings.ToLower(*resp.Aclmode.Value))
+	if resp.Origin != nil {
+		d.Set("origin", derefString(resp.Origin.Value))
 	}
 
-	if resp.Acltype != nil && resp.Acltype.Value != nil {
-		d.Set("acl_type", strings.ToLower(*resp.Acltype.Value))
+	if resp.Aclmode != nil && resp.Aclmode.Value != nil {
+		setInheritableProperty(d, "acl_mode", resp.Aclmode, strings.ToLower(*resp.Aclmode.Value))
 	}
 
 	if resp.Atime != nil && resp.Atime.Value != nil {
-		d.Set("atime", strings.ToLower(*resp.Atime.Value))
+		setInheritableProperty(d, "atime", resp.Atime, strings.ToLower(*resp.Atime.Value))
 	}
 
 	if resp.Casesensitivity != nil && resp.Casesensitivity.Value != nil {
@@ -341,15 +541,15 @@ func resourceTrueNASDatasetRead(ctx context.Context, d *schema.ResourceData, m i
 	}
 
 	if resp.Compression != nil && resp.Compression.Value != nil {
-		d.Set("compression", strings.ToLower(*resp.Compression.Value))
+		setInheritableProperty(d, "compression", resp.Compression, strings.ToLower(*resp.Compression.Value))
 	}
 
 	if resp.Deduplication != nil && resp.Deduplication.Value != nil {
-		d.Set("deduplication", strings.ToLower(*resp.Deduplication.Value))
+		setInheritableProperty(d, "deduplication", resp.Deduplication, strings.ToLower(*resp.Deduplication.Value))
 	}
 
 	if resp.Exec != nil && resp.Exec.Value != nil {
-		d.Set("exec", strings.ToLower(*resp.Exec.Value))
+		setInheritableProperty(d, "exec", resp.Exec, strings.ToLower(*resp.Exec.Value))
 	}
 
 	if resp.Managedby != nil && resp.Managedby.Value != nil {
@@ -427,11 +627,11 @@ func resourceTrueNASDatasetRead(ctx context.Context, d *schema.ResourceData, m i
 	}
 
 	if resp.Readonly != nil && resp.Readonly.Value != nil {
-		d.Set("readonly", strings.ToLower(*resp.Readonly.Value))
+		setInheritableProperty(d, "readonly", resp.Readonly, strings.ToLower(*resp.Readonly.Value))
 	}
 
 	if resp.Recordsize != nil && resp.Recordsize.Value != nil {
-		d.Set("record_size", *resp.Recordsize.Value)
+		setInheritableProperty(d, "record_size", resp.Recordsize, *resp.Recordsize.Value)
 	}
 
 	// TODO: doublecheck, does not seem to be ever returned
@@ -441,12 +641,12 @@ func resourceTrueNASDatasetRead(ctx context.Context, d *schema.ResourceData, m i
 	//	}
 	//}
 
-	if resp.Sync != nil {
-		d.Set("sync", strings.ToLower(*resp.Sync.Value))
+	if resp.Sync != nil && resp.Sync.Value != nil {
+		setInheritableProperty(d, "sync", resp.Sync, strings.ToLower(*resp.Sync.Value))
 	}
 
 	if resp.Snapdir != nil && resp.Snapdir.Value != nil {
-		d.Set("snap_dir", strings.ToLower(*resp.Snapdir.Value))
+		setInheritableProperty(d, "snap_dir", resp.Snapdir, strings.ToLower(*resp.Snapdir.Value))
 	}
 
 	if resp.EncryptionAlgorithm != nil && resp.EncryptionAlgorithm.Value != nil {
@@ -467,47 +667,213 @@ func resourceTrueNASDatasetRead(ctx context.Context, d *schema.ResourceData, m i
 		d.Set("encrypted", *resp.Encrypted)
 	}
 
+	d.Set("encryption_root", resp.EncryptionRoot)
+	d.Set("key_loaded", resp.KeyLoaded)
+	d.Set("locked", resp.Locked)
+
+	if resp.KeyFormat != nil && resp.KeyFormat.Value != nil {
+		d.Set("key_format", strings.ToLower(*resp.KeyFormat.Value))
+	} else {
+		d.Set("key_format", "")
+	}
+
+	flattenDatasetProperties(d, datasetExtraProperties, &resp)
+	flattenUserProperties(d, &resp)
+
 	return diags
 }
 
+// resourceTrueNASDatasetUpdate unlocks dataset first, so that its key and properties can be changed, and locks it last
 func resourceTrueNASDatasetUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	var diags diag.Diagnostics
 
-	input := expandDatasetForUpdate(d)
+	c := m.(*Client)
+	id := d.Id()
+	locked := d.Get("locked").(bool)
+
+	if d.HasChanges("parent", "name") {
+		newID := datasetPath{
+			Pool:   d.Get("pool").(string),
+			Parent: d.Get("parent").(string),
+			Name:   d.Get("name").(string),
+		}.String()
+
+		// mount point is looked up before rename, dataset moved along with renamed parent is not found
+		oldPath, pathErr := datasetMountPath(ctx, c, id)
+
+		if err := renameDataset(ctx, c, id, newID); err != nil {
+			return apiErrorDiags(err, "error renaming dataset", datasetAPIAttrs)
+		}
+
+		if pathErr == nil {
+			diags = append(diags, datasetShareWarnings(ctx, c, id, oldPath, newID)...)
+		}
+		d.SetId(newID)
+		id = newID
+	}
+
+	// clone can not be demoted, so only promote = true is applied
+	if d.HasChange("promote") && d.Get("promote").(bool) {
+		if err := promoteDataset(ctx, c, id); err != nil {
+			return append(diags, apiErrorDiags(err, "error promoting dataset", nil)...)
+		}
+	}
+
+	if encDiags := updateDatasetEncryption(ctx, c, d, datasetAPIAttrs); encDiags.HasError() {
+		return append(diags, encDiags...)
+	}
+
+	if d.HasChangesExcept(append([]string{"locked", "parent", "name", "promote"}, datasetKeyAttrs...)...) {
+		input := expandDatasetForUpdate(d)
+
+		log.Printf("[DEBUG] Updating TrueNAS dataset: %+v", input)
+
+		if err := c.invoke(ctx, "pool.dataset.update", []interface{}{id, input}, nil); err != nil {
+			return apiErrorDiags(err, "error updating dataset", datasetAPIAttrs)
+		}
+
+		log.Printf("[INFO] TrueNAS dataset (%s) updated", id)
+	}
+
+	if d.HasChange("locked") && locked {
+		if err := lockDataset(ctx, c, id); err != nil {
+			return apiErrorDiags(err, "error locking dataset", nil)
+		}
+	}
+
+	return append(diags, resourceTrueNASDatasetRead(ctx, d, m)...)
+}
 
-	log.Printf("[DEBUG] Updating TrueNAS dataset: %+v", input)
+// renameDataset renames dataset or zvol, child datasets are moved along with it. Dataset that
+// is already at its new location, eg. moved along with renamed parent, is left as is
+func renameDataset(ctx context.Context, c *Client, oldID string, newID string) error {
+	log.Printf("[DEBUG] Renaming TrueNAS dataset %s to %s", oldID, newID)
 
-	_, _, err := c.DatasetApi.UpdateDataset(ctx, d.Id()).UpdateDatasetParams(input).Execute()
+	err := c.invoke(ctx, "pool.dataset.rename", []interface{}{oldID, map[string]interface{}{"new_name": newID}}, nil)
+
+	if err != nil && isNotFoundError(nil, err) {
+		if c.invoke(ctx, "pool.dataset.get_instance", []interface{}{newID}, nil) == nil {
+			log.Printf("[INFO] TrueNAS dataset %s has already been moved to %s", oldID, newID)
+			return nil
+		}
+	}
 
 	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
+		return err
+	}
+
+	log.Printf("[INFO] TrueNAS dataset %s renamed to %s", oldID, newID)
+
+	return nil
+}
+
+// customizeDiffRenamed marks attributes derived from dataset path as known only after apply, when
+// dataset or zvol is renamed in place, otherwise resources referencing them get stale values
+func customizeDiffRenamed(d *schema.ResourceDiff, attrs ...string) error {
+	if d.Id() == "" || !d.HasChanges("name", "parent") {
+		return nil
+	}
+
+	for _, attr := range attrs {
+		if err := d.SetNewComputed(attr); err != nil {
+			return err
 		}
-		return diag.Errorf("error updating dataset: %s\n%s", err, body)
 	}
 
-	log.Printf("[INFO] TrueNAS dataset (%s) updated", d.Id())
+	return nil
+}
 
-	return resourceTrueNASDatasetRead(ctx, d, m)
+// datasetShare is sharing.nfs.query or sharing.smb.query result, NFS shares have paths, SMB shares path
+type datasetShare struct {
+	ID    int      `json:"id"`
+	Path  string   `json:"path"`
+	Paths []string `json:"paths"`
+}
+
+// datasetShareWarnings warns about NFS and SMB shares still pointing at old mount point of renamed dataset,
+// shares are not updated, since they might be managed by other resources
+func datasetShareWarnings(ctx context.Context, c *Client, oldID string, oldPath string, newID string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	newPath, err := datasetMountPath(ctx, c, newID)
+
+	if err != nil {
+		log.Printf("[WARN] Unable to check shares of renamed dataset %s: %s", newID, err)
+		return nil
+	}
+
+	// local mountpoint property is kept by rename
+	if newPath == oldPath {
+		return nil
+	}
+
+	for _, shareType := range []string{"nfs", "smb"} {
+		var shares []datasetShare
+
+		if err := c.invoke(ctx, fmt.Sprintf("sharing.%s.query", shareType), nil, &shares); err != nil {
+			log.Printf("[WARN] Unable to check %s shares of renamed dataset %s: %s", shareType, newID, err)
+			continue
+		}
+
+		for _, share := range shares {
+			for _, p := range append(share.Paths, share.Path) {
+				if p == oldPath || strings.HasPrefix(p, oldPath+"/") {
+					diags = append(diags, diag.Diagnostic{
+						Severity: diag.Warning,
+						Summary:  fmt.Sprintf("%s share %d points at old location of dataset %s", strings.ToUpper(shareType), share.ID, oldID),
+						Detail:   fmt.Sprintf("Dataset was moved to %s, share path %s has to be updated to %s", newID, p, newPath+strings.TrimPrefix(p, oldPath)),
+					})
+					break
+				}
+			}
+		}
+	}
+
+	return diags
+}
+
+// resourceTrueNASDatasetCustomizeDiff validates encryption changes, that depend on dataset lock state, and plans inherit
+// for properties removed from config
+func resourceTrueNASDatasetCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if err := customizeDiffDatasetEncryption(d); err != nil {
+		return err
+	}
+
+	if err := customizeDiffRenamed(d, "dataset_id", "mount_point", "encryption_root"); err != nil {
+		return err
+	}
+
+	if d.Id() == "" {
+		return nil
+	}
+
+	inheritable := make(map[string]string)
+
+	for _, props := range []map[string]string{datasetInheritableProperties, datasetExtraProperties} {
+		for attr, name := range props {
+			inheritable[attr] = name
+		}
+	}
+
+	// ACL properties of SMB datasets are set by middleware
+	if d.Get("share_type").(string) != "" {
+		delete(inheritable, "acl_mode")
+		delete(inheritable, "acl_type")
+	}
+
+	return customizeDiffInheritRemoved(d, inheritable)
 }
 
 func resourceTrueNASDatasetDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id := d.Id()
 
 	log.Printf("[DEBUG] Deleting TrueNAS dataset: %s", id)
 
-	_, err := c.DatasetApi.DeleteDataset(ctx, id).Execute()
-
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error deleting dataset: %s\n%s", err, body)
+	if err := c.invokeOptionalJob(ctx, "pool.dataset.delete", []interface{}{id}); err != nil {
+		return apiErrorDiags(err, "error deleting dataset", nil)
 	}
 
 	log.Printf("[INFO] TrueNAS dataset (%s) deleted", id)
@@ -527,9 +893,7 @@ func expandDataset(d *schema.ResourceData) api.CreateDatasetParams {
 		Name: p.String(),
 	}
 
-	if sync, ok := d.GetOk("sync"); ok {
-		input.Sync = getStringPtr(strings.ToUpper(sync.(string)))
-	}
+	input.Sync = expandInheritableProperty(d, "sync", false)
 
 	if caseSensitivity, ok := d.GetOk("case_sensitivity"); ok {
 		input.Casesensitivity = getStringPtr(strings.ToUpper(caseSensitivity.(string)))
@@ -539,29 +903,16 @@ func expandDataset(d *schema.ResourceData) api.CreateDatasetParams {
 		input.Comments = getStringPtr(comments.(string))
 	}
 
-	if compression, ok := d.GetOk("compression"); ok {
-		input.Compression = getStringPtr(strings.ToUpper(compression.(string)))
-	}
-
-	if deduplication, ok := d.GetOk("deduplication"); ok {
-		input.Deduplication = getStringPtr(strings.ToUpper(deduplication.(string)))
-	}
+	input.Compression = expandInheritableProperty(d, "compression", false)
+	input.Deduplication = expandInheritableProperty(d, "deduplication", false)
 
 	if copies, ok := d.GetOk("copies"); ok {
 		input.Copies = getInt32Ptr(int32(copies.(int)))
 	}
 
-	if exec, ok := d.GetOk("exec"); ok {
-		input.Exec = getStringPtr(strings.ToUpper(exec.(string)))
-	}
-
-	if aclmode, ok := d.GetOk("acl_mode"); ok {
-		input.Aclmode = getStringPtr(strings.ToUpper(aclmode.(string)))
-	}
-
-	if atime, ok := d.GetOk("atime"); ok {
-		input.Atime = getStringPtr(strings.ToUpper(atime.(string)))
-	}
+	input.Exec = expandInheritableProperty(d, "exec", false)
+	input.Aclmode = expandInheritableProperty(d, "acl_mode", false)
+	input.Atime = expandInheritableProperty(d, "atime", false)
 
 	if quota, ok := d.GetOk("quota_bytes"); ok {
 		input.Quota = getInt64Ptr(int64(quota.(int)))
@@ -587,54 +938,25 @@ func expandDataset(d *schema.ResourceData) api.CreateDatasetParams {
 		input.RefquotaWarning = getInt64Ptr(int64(refQuotaWarning.(int)))
 	}
 
-	if readonly, ok := d.GetOk("readonly"); ok {
-		input.Readonly = getStringPtr(strings.ToUpper(readonly.(string)))
-	}
-
-	if recordSize, ok := d.GetOk("record_size"); ok {
-		input.Recordsize = getStringPtr(strings.ToUpper(recordSize.(string)))
-	}
+	input.Readonly = expandInheritableProperty(d, "readonly", false)
+	input.Recordsize = expandInheritableProperty(d, "record_size", false)
 
 	if shareType, ok := d.GetOk("share_type"); ok {
 		input.ShareType = getStringPtr(strings.ToUpper(shareType.(string)))
 	}
 
-	if snapDir, ok := d.GetOk("snap_dir"); ok {
-		input.Snapdir = getStringPtr(strings.ToUpper(snapDir.(string)))
-	}
-
-	encrypted := d.Get("encrypted")
-
-	if encrypted != nil {
-		input.Encryption = getBoolPtr(encrypted.(bool))
-	}
-
-	inheritEncryption := d.Get("inherit_encryption")
+	input.Snapdir = expandInheritableProperty(d, "snap_dir", false)
 
-	if inheritEncryption != nil {
-		input.InheritEncryption = getBoolPtr(inheritEncryption.(bool))
-	}
-
-	encOptions := &api.CreateDatasetParamsEncryptionOptions{}
-
-	if algorithm, ok := d.GetOk("encryption_algorithm"); ok {
-		encOptions.Algorithm = getStringPtr(algorithm.(string))
-	}
+	input.Encryption = getBoolPtr(d.Get("encrypted").(bool))
+	input.InheritEncryption = getBoolPtr(d.Get("inherit_encryption").(bool))
+	input.EncryptionOptions = expandDatasetEncryptionOptions(d)
 
-	if genKey, ok := d.GetOk("generate_key"); ok {
-		encOptions.GenerateKey = getBoolPtr(genKey.(bool))
-	}
-
-	if passphrase, ok := d.GetOk("passphrase"); ok {
-		encOptions.Passphrase = getStringPtr(passphrase.(string))
-	}
+	input.AdditionalProperties = expandDatasetProperties(d, datasetExtraProperties, false)
 
-	if key, ok := d.GetOk("encryption_key"); ok {
-		encOptions.Key = getStringPtr(key.(string))
+	if props := expandUserProperties(d); len(props) > 0 {
+		input.AdditionalProperties["user_properties"] = props
 	}
 
-	input.EncryptionOptions = encOptions
-
 	input.Type = getStringPtr(datasetType)
 	return input
 }
@@ -642,37 +964,22 @@ func expandDataset(d *schema.ResourceData) api.CreateDatasetParams {
 func expandDatasetForUpdate(d *schema.ResourceData) api.UpdateDatasetParams {
 	input := api.UpdateDatasetParams{}
 
-	if sync, ok := d.GetOk("sync"); ok {
-		input.Sync = getStringPtr(strings.ToUpper(sync.(string)))
-	}
+	input.Sync = expandInheritableProperty(d, "sync", true)
 
 	if comments, ok := d.GetOk("comments"); ok {
 		input.Comments = getStringPtr(comments.(string))
 	}
 
-	if compression, ok := d.GetOk("compression"); ok {
-		input.Compression = getStringPtr(strings.ToUpper(compression.(string)))
-	}
+	input.Compression = expandInheritableProperty(d, "compression", true)
+	input.Deduplication = expandInheritableProperty(d, "deduplication", true)
 
-	if deduplication, ok := d.GetOk("deduplication"); ok {
-		input.Deduplication = getStringPtr(strings.ToUpper(deduplication.(string)))
-	}
-
-	if copies, ok := d.GetOk("copies"); ok {
+	if copies, ok := d.GetOk("copies"); ok && d.HasChange("copies") {
 		input.Copies = getInt32Ptr(int32(copies.(int)))
 	}
 
-	if exec, ok := d.GetOk("exec"); ok {
-		input.Exec = getStringPtr(strings.ToUpper(exec.(string)))
-	}
-
-	if aclmode, ok := d.GetOk("acl_mode"); ok {
-		input.Aclmode = getStringPtr(strings.ToUpper(aclmode.(string)))
-	}
-
-	if atime, ok := d.GetOk("atime"); ok {
-		input.Atime = getStringPtr(strings.ToUpper(atime.(string)))
-	}
+	input.Exec = expandInheritableProperty(d, "exec", true)
+	input.Aclmode = expandInheritableProperty(d, "acl_mode", true)
+	input.Atime = expandInheritableProperty(d, "atime", true)
 
 	if quota, ok := d.GetOk("quota_bytes"); ok {
 		input.Quota = getInt64Ptr(int64(quota.(int)))
@@ -682,16 +989,19 @@ func expandDatasetForUpdate(d *schema.ResourceData) api.UpdateDatasetParams {
 		input.Refquota = getInt64Ptr(int64(refQuota.(int)))
 	}
 
-	if readonly, ok := d.GetOk("readonly"); ok {
-		input.Readonly = getStringPtr(strings.ToUpper(readonly.(string)))
-	}
+	input.Readonly = expandInheritableProperty(d, "readonly", true)
+	input.Recordsize = expandInheritableProperty(d, "record_size", true)
+	input.Snapdir = expandInheritableProperty(d, "snap_dir", true)
+
+	input.AdditionalProperties = expandDatasetProperties(d, datasetExtraProperties, true)
 
-	if recordSize, ok := d.GetOk("record_size"); ok {
-		input.Recordsize = getStringPtr(strings.ToUpper(recordSize.(string)))
+	// copies is numeric in SDK model, so INHERIT is sent as additional property
+	if inheritRemovedProperty(d, "copies") {
+		input.AdditionalProperties["copies"] = strings.ToUpper(propertyInherit)
 	}
 
-	if snapDir, ok := d.GetOk("snap_dir"); ok {
-		input.Snapdir = getStringPtr(strings.ToUpper(snapDir.(string)))
+	if props := expandUserPropertiesUpdate(d); len(props) > 0 {
+		input.AdditionalProperties["user_properties_update"] = props
 	}
 
 	return input
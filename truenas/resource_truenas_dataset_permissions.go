@@ -0,0 +1,499 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ACL types, see filesystem.getacl
+const (
+	aclTypeNFS4    = "NFS4"
+	aclTypePOSIX1E = "POSIX1E"
+)
+
+// aclBasicKey is perms and flags key of NFS4 ACL entries that use basic permission or inheritance sets
+const aclBasicKey = "BASIC"
+
+var nfs4BasicPerms = []string{"FULL_CONTROL", "MODIFY", "READ", "TRAVERSE", "NOPERMS"}
+var nfs4AdvancedPerms = []string{"READ_DATA", "WRITE_DATA", "APPEND_DATA", "READ_NAMED_ATTRS", "WRITE_NAMED_ATTRS", "EXECUTE", "DELETE_CHILD", "READ_ATTRIBUTES", "WRITE_ATTRIBUTES", "DELETE", "READ_ACL", "WRITE_ACL", "WRITE_OWNER", "SYNCHRONIZE"}
+var nfs4BasicFlags = []string{"INHERIT", "NOINHERIT"}
+var nfs4AdvancedFlags = []string{"FILE_INHERIT", "DIRECTORY_INHERIT", "NO_PROPAGATE_INHERIT", "INHERIT_ONLY", "INHERITED"}
+var posix1ePerms = []string{"READ", "WRITE", "EXECUTE"}
+
+var aclTags = []string{"owner@", "group@", "everyone@", "USER", "GROUP", "USER_OBJ", "GROUP_OBJ", "OTHER", "MASK"}
+
+var datasetPermissionsAPIAttrs = apiAttrMap{
+	"user":  "user",
+	"group": "group",
+	"mode":  "mode",
+	"acl":   "acl",
+}
+
+var filesystemModePattern = regexp.MustCompile(`^[0-7]{3,4}$`)
+
+// aclEntry is NFS4 or POSIX1E ACL entry, perms and flags are either
+// {"BASIC": "MODIFY"} or a set of advanced permissions, eg. {"READ_DATA": true}
+type aclEntry struct {
+	Tag     string                 `json:"tag"`
+	ID      *int                   `json:"id"`
+	Type    string                 `json:"type,omitempty"`
+	Perms   map[string]interface{} `json:"perms"`
+	Flags   map[string]interface{} `json:"flags,omitempty"`
+	Default *bool                  `json:"default,omitempty"`
+}
+
+// filesystemACL is filesystem.getacl result
+type filesystemACL struct {
+	Path    string     `json:"path"`
+	ACLType string     `json:"acltype"`
+	Trivial bool       `json:"trivial"`
+	UID     int        `json:"uid"`
+	GID     int        `json:"gid"`
+	ACL     []aclEntry `json:"acl"`
+}
+
+// filesystemStat is filesystem.stat result, only fields used by the provider are decoded
+type filesystemStat struct {
+	Mode  int    `json:"mode"`
+	UID   int    `json:"uid"`
+	GID   int    `json:"gid"`
+	User  string `json:"user"`
+	Group string `json:"group"`
+	ACL   bool   `json:"acl"`
+}
+
+func resourceTrueNASDatasetPermissions() *schema.Resource {
+	return &schema.Resource{
+		Description: "Manage owner and access permissions of a dataset mount point, either as POSIX mode or NFS4/POSIX1E ACL. " +
+			"ACL type follows dataset `acl_type`. Deleting the resource leaves permissions unchanged",
+		CreateContext: resourceTrueNASDatasetPermissionsCreate,
+		ReadContext:   resourceTrueNASDatasetPermissionsRead,
+		UpdateContext: resourceTrueNASDatasetPermissionsUpdate,
+		DeleteContext: resourceTrueNASDatasetPermissionsDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceTrueNASDatasetPermissionsImport,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"dataset_id": &schema.Schema{
+				Description: "Dataset ID, eg. Tank/data",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"user": &schema.Schema{
+				Description: "Owner user name",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+			},
+			"group": &schema.Schema{
+				Description: "Owner group name",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+			},
+			"uid": &schema.Schema{
+				Description: "Owner user ID",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"gid": &schema.Schema{
+				Description: "Owner group ID",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"mode": &schema.Schema{
+				Description:      "POSIX mode in octal format, eg. 0750, existing ACL is removed when mode is set",
+				Type:             schema.TypeString,
+				Optional:         true,
+				ConflictsWith:    []string{"acl"},
+				ValidateFunc:     validation.StringMatch(filesystemModePattern, "mode must be in octal format, eg. 0755"),
+				DiffSuppressFunc: suppressFilesystemModeDiff,
+			},
+			"acl_type": &schema.Schema{
+				Description: "ACL type of dataset: NFS4 or POSIX1E",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"acl": &schema.Schema{
+				Description:   "ACL entries, NFS4 entries are evaluated in order",
+				Type:          schema.TypeList,
+				Optional:      true,
+				ConflictsWith: []string{"mode"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"tag": &schema.Schema{
+							Description:  "Entry tag, NFS4: owner@, group@, everyone@, USER or GROUP, POSIX1E: USER_OBJ, GROUP_OBJ, OTHER, MASK, USER or GROUP",
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringInSlice(aclTags, false),
+						},
+						"id": &schema.Schema{
+							Description: "User or group ID for USER and GROUP tags",
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Default:     -1,
+						},
+						"type": &schema.Schema{
+							Description:  "NFS4 entry type: ALLOW or DENY",
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringInSlice([]string{"ALLOW", "DENY"}, false),
+						},
+						"perms": &schema.Schema{
+							Description: "Permissions, NFS4: one of basic permissions (FULL_CONTROL, MODIFY, READ, TRAVERSE) or advanced permissions, eg. READ_DATA, WRITE_DATA, " +
+								"POSIX1E: READ, WRITE, EXECUTE. Advanced NFS4 permissions that match basic permission are reported as basic one",
+							Type:     schema.TypeSet,
+							Required: true,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.StringInSlice(append(append(append([]string{}, nfs4BasicPerms...), nfs4AdvancedPerms...), posix1ePerms...), false),
+							},
+						},
+						"flags": &schema.Schema{
+							Description: "NFS4 inheritance flags, either one of basic flags (INHERIT, NOINHERIT) or advanced flags, eg. FILE_INHERIT, DIRECTORY_INHERIT",
+							Type:        schema.TypeSet,
+							Optional:    true,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.StringInSlice(append(append([]string{}, nfs4BasicFlags...), nfs4AdvancedFlags...), false),
+							},
+						},
+						"default": &schema.Schema{
+							Description: "`true` for POSIX1E default ACL entries, that are inherited by new files and directories",
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+						},
+					},
+				},
+			},
+			"recursive": &schema.Schema{
+				Description: "Apply permissions to all files and directories of the dataset",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"traverse": &schema.Schema{
+				Description: "Apply permissions to child datasets as well, requires `recursive`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+		},
+	}
+}
+
+// suppressFilesystemModeDiff ignores leading zeros of octal modes, eg. 0755 and 755
+func suppressFilesystemModeDiff(k, old, new string, d *schema.ResourceData) bool {
+	o, err := strconv.ParseUint(old, 8, 32)
+
+	if err != nil {
+		return false
+	}
+
+	n, err := strconv.ParseUint(new, 8, 32)
+
+	return err == nil && o == n
+}
+
+// datasetMountInfo is part of pool.dataset.get_instance result, mountpoint is null for datasets that are not mounted
+type datasetMountInfo struct {
+	Mountpoint *string `json:"mountpoint"`
+}
+
+// datasetMountPath returns path dataset is mounted at, it is looked up, since mountpoint property
+// can move dataset outside of /mnt/<id>
+func datasetMountPath(ctx context.Context, c *Client, id string) (string, error) {
+	var resp datasetMountInfo
+
+	if err := c.invoke(ctx, "pool.dataset.get_instance", []interface{}{id}, &resp); err != nil {
+		return "", err
+	}
+
+	// legacy and none mountpoints are not mounted by middleware
+	if resp.Mountpoint == nil || !strings.HasPrefix(*resp.Mountpoint, "/") {
+		return "", fmt.Errorf("dataset %s is not mounted", id)
+	}
+
+	return *resp.Mountpoint, nil
+}
+
+func resourceTrueNASDatasetPermissionsCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	id := d.Get("dataset_id").(string)
+
+	if diags := setDatasetPermissions(ctx, d, m.(*Client), id); diags != nil {
+		return diags
+	}
+
+	d.SetId(id)
+
+	return resourceTrueNASDatasetPermissionsRead(ctx, d, m)
+}
+
+func resourceTrueNASDatasetPermissionsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	id := d.Id()
+
+	path, err := datasetMountPath(ctx, c, id)
+
+	if err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS dataset (%s) not found, removing permissions from state", id)
+			d.SetId("")
+			return nil
+		}
+		return apiErrorDiags(err, "error getting dataset mount point", nil)
+	}
+
+	var stat filesystemStat
+
+	if err := c.invoke(ctx, "filesystem.stat", []interface{}{path}, &stat); err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS dataset (%s) mount point not found, removing permissions from state", id)
+			d.SetId("")
+			return nil
+		}
+		return apiErrorDiags(err, "error getting dataset permissions", nil)
+	}
+
+	var acl filesystemACL
+
+	if err := c.invoke(ctx, "filesystem.getacl", []interface{}{path, true}, &acl); err != nil {
+		return apiErrorDiags(err, "error getting dataset ACL", nil)
+	}
+
+	d.Set("dataset_id", id)
+	d.Set("user", stat.User)
+	d.Set("group", stat.Group)
+	d.Set("uid", stat.UID)
+	d.Set("gid", stat.GID)
+	d.Set("acl_type", acl.ACLType)
+
+	// mode and ACL are only read when managed, so that owner can be managed on its own
+	if _, ok := d.GetOk("mode"); ok {
+		d.Set("mode", fmt.Sprintf("%04o", stat.Mode&0o7777))
+	}
+
+	if _, ok := d.GetOk("acl"); ok {
+		if err := d.Set("acl", flattenACL(acl.ACLType, acl.ACL)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return nil
+}
+
+func resourceTrueNASDatasetPermissionsUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if d.HasChanges("user", "group", "mode", "acl") {
+		if diags := setDatasetPermissions(ctx, d, m.(*Client), d.Id()); diags != nil {
+			return diags
+		}
+	}
+
+	return resourceTrueNASDatasetPermissionsRead(ctx, d, m)
+}
+
+// resourceTrueNASDatasetPermissionsDelete only removes permissions from state, files keep their permissions
+func resourceTrueNASDatasetPermissionsDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	log.Printf("[INFO] TrueNAS dataset (%s) permissions removed from state, permissions are left unchanged", d.Id())
+	d.SetId("")
+
+	return nil
+}
+
+// resourceTrueNASDatasetPermissionsImport imports mode of datasets with trivial ACL and ACL entries otherwise
+func resourceTrueNASDatasetPermissionsImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	c := m.(*Client)
+
+	path, err := datasetMountPath(ctx, c, d.Id())
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting dataset mount point: %s", err)
+	}
+
+	var acl filesystemACL
+
+	if err := c.invoke(ctx, "filesystem.getacl", []interface{}{path, true}, &acl); err != nil {
+		return nil, fmt.Errorf("error getting dataset ACL: %s", err)
+	}
+
+	if acl.Trivial {
+		var stat filesystemStat
+
+		if err := c.invoke(ctx, "filesystem.stat", []interface{}{path}, &stat); err != nil {
+			return nil, fmt.Errorf("error getting dataset permissions: %s", err)
+		}
+
+		d.Set("mode", fmt.Sprintf("%04o", stat.Mode&0o7777))
+	} else if err := d.Set("acl", flattenACL(acl.ACLType, acl.ACL)); err != nil {
+		return nil, err
+	}
+
+	d.Set("recursive", false)
+	d.Set("traverse", false)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// setDatasetPermissions runs pool.dataset.permission job, that changes owner and either mode or ACL
+func setDatasetPermissions(ctx context.Context, d *schema.ResourceData, c *Client, id string) diag.Diagnostics {
+	options := map[string]interface{}{
+		"recursive": d.Get("recursive").(bool),
+		"traverse":  d.Get("traverse").(bool),
+	}
+
+	input := map[string]interface{}{
+		"options": options,
+	}
+
+	if user, ok := d.GetOk("user"); ok {
+		input["user"] = user.(string)
+	}
+
+	if group, ok := d.GetOk("group"); ok {
+		input["group"] = group.(string)
+	}
+
+	if mode, ok := d.GetOk("mode"); ok {
+		input["mode"] = mode.(string)
+		options["stripacl"] = true
+	}
+
+	if acl, ok := d.GetOk("acl"); ok {
+		input["acl"] = expandACL(acl.([]interface{}))
+	}
+
+	log.Printf("[DEBUG] Setting TrueNAS dataset (%s) permissions: %+v", id, input)
+
+	if err := c.invokeJob(ctx, "pool.dataset.permission", []interface{}{id, input}, nil); err != nil {
+		return apiErrorDiags(err, "error setting dataset permissions", datasetPermissionsAPIAttrs)
+	}
+
+	log.Printf("[INFO] TrueNAS dataset (%s) permissions set", id)
+
+	return nil
+}
+
+func expandACL(entries []interface{}) []aclEntry {
+	result := make([]aclEntry, 0, len(entries))
+
+	for _, e := range entries {
+		mEntry := e.(map[string]interface{})
+		id := mEntry["id"].(int)
+		perms := expandStrings(mEntry["perms"].(*schema.Set).List())
+		flags := expandStrings(mEntry["flags"].(*schema.Set).List())
+
+		entry := aclEntry{
+			Tag: mEntry["tag"].(string),
+			ID:  &id,
+		}
+
+		if entryType := mEntry["type"].(string); entryType != "" {
+			// NFS4 entry
+			entry.Type = entryType
+			entry.Perms = expandACLFlagSet(perms, nfs4BasicPerms)
+			entry.Flags = expandACLFlagSet(flags, nfs4BasicFlags)
+		} else {
+			entry.Perms = make(map[string]interface{})
+
+			for _, p := range posix1ePerms {
+				entry.Perms[p] = false
+			}
+
+			for _, p := range perms {
+				entry.Perms[p] = true
+			}
+
+			entry.Default = getBoolPtr(mEntry["default"].(bool))
+		}
+
+		result = append(result, entry)
+	}
+
+	return result
+}
+
+// expandACLFlagSet converts NFS4 perms or flags, single basic value becomes {"BASIC": value}
+func expandACLFlagSet(values []string, basic []string) map[string]interface{} {
+	if len(values) == 1 {
+		for _, b := range basic {
+			if values[0] == b {
+				return map[string]interface{}{aclBasicKey: b}
+			}
+		}
+	}
+
+	result := make(map[string]interface{}, len(values))
+
+	for _, v := range values {
+		result[v] = true
+	}
+
+	return result
+}
+
+func flattenACL(aclType string, entries []aclEntry) []interface{} {
+	result := make([]interface{}, 0, len(entries))
+
+	for _, e := range entries {
+		id := -1
+
+		if e.ID != nil {
+			id = *e.ID
+		}
+
+		mEntry := map[string]interface{}{
+			"tag":     e.Tag,
+			"id":      id,
+			"perms":   flattenACLFlagSet(e.Perms),
+			"flags":   []interface{}{},
+			"type":    "",
+			"default": false,
+		}
+
+		if aclType == aclTypeNFS4 {
+			mEntry["type"] = e.Type
+			mEntry["flags"] = flattenACLFlagSet(e.Flags)
+		} else if e.Default != nil {
+			mEntry["default"] = *e.Default
+		}
+
+		result = append(result, mEntry)
+	}
+
+	return result
+}
+
+func flattenACLFlagSet(values map[string]interface{}) []interface{} {
+	if basic, ok := values[aclBasicKey].(string); ok {
+		return []interface{}{basic}
+	}
+
+	keys := make([]string, 0, len(values))
+
+	for k, v := range values {
+		if enabled, ok := v.(bool); ok && enabled {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return flattenStringList(keys)
+}
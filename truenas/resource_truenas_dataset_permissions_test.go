@@ -0,0 +1,311 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestAccResourceTruenasDatasetPermissions_basic(t *testing.T) {
+	suffix := acctest.RandStringFromCharSet(5, acctest.CharSetAlphaNum)
+	datasetName := fmt.Sprintf("%s-%s", testResourcePrefix, suffix)
+	resourceName := "truenas_dataset_permissions.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckResourceTruenasDatasetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckResourceTruenasDatasetPermissionsConfig(testPoolName, datasetName, `mode = "0750"`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", fmt.Sprintf("%s/%s", testPoolName, datasetName)),
+					resource.TestCheckResourceAttr(resourceName, "user", "root"),
+					resource.TestCheckResourceAttr(resourceName, "uid", "0"),
+					resource.TestCheckResourceAttr(resourceName, "mode", "0750"),
+				),
+			},
+			{
+				ResourceName:            resourceName,
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"group"},
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasDatasetPermissions_posix(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset_permissions.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasDatasetPermissionsConfig(fakePoolName, "data", `
+					user = "apps"
+					group = "apps"
+					mode = "750"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/data"),
+					resource.TestCheckResourceAttr(resourceName, "user", "apps"),
+					resource.TestCheckResourceAttr(resourceName, "group", "apps"),
+					resource.TestCheckResourceAttr(resourceName, "uid", "568"),
+					resource.TestCheckResourceAttr(resourceName, "gid", "568"),
+					resource.TestCheckResourceAttr(resourceName, "mode", "0750"),
+					resource.TestCheckResourceAttr(resourceName, "acl_type", aclTypePOSIX1E),
+				),
+			},
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasDatasetPermissionsConfig(fakePoolName, "data", `
+					user = "apps"
+					group = "apps"
+
+					acl {
+						tag = "USER_OBJ"
+						perms = ["READ", "WRITE", "EXECUTE"]
+					}
+
+					acl {
+						tag = "GROUP_OBJ"
+						perms = ["READ", "EXECUTE"]
+					}
+
+					acl {
+						tag = "USER"
+						id = 65534
+						perms = ["READ"]
+					}
+
+					acl {
+						tag = "MASK"
+						perms = ["READ", "EXECUTE"]
+					}
+
+					acl {
+						tag = "OTHER"
+						perms = []
+					}
+
+					acl {
+						tag = "USER_OBJ"
+						perms = ["READ", "WRITE", "EXECUTE"]
+						default = true
+					}
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "mode", ""),
+					resource.TestCheckResourceAttr(resourceName, "acl.#", "6"),
+					resource.TestCheckResourceAttr(resourceName, "acl.2.tag", "USER"),
+					resource.TestCheckResourceAttr(resourceName, "acl.2.id", "65534"),
+					resource.TestCheckResourceAttr(resourceName, "acl.2.perms.#", "1"),
+					resource.TestCheckResourceAttr(resourceName, "acl.4.perms.#", "0"),
+					resource.TestCheckResourceAttr(resourceName, "acl.5.default", "true"),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+				// mode is left empty in state once ACL is managed instead
+				ImportStateVerifyIgnore: []string{"mode"},
+			},
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasDatasetPermissionsConfig(fakePoolName, "data", `
+					user = "unknown"
+				`),
+				ExpectError: regexp.MustCompile(`(?s)error setting dataset permissions.*user does not exist`),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasDatasetPermissions_nfs4(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset_permissions.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + `
+				resource "truenas_dataset" "test" {
+					name = "smb"
+					pool = "Tank"
+					share_type = "smb"
+				}
+
+				resource "truenas_dataset" "child" {
+					name = "child"
+					pool = "Tank"
+					parent = truenas_dataset.test.name
+					share_type = "smb"
+				}
+
+				resource "truenas_dataset_permissions" "test" {
+					dataset_id = truenas_dataset.test.id
+					group = "apps"
+					recursive = true
+					traverse = true
+
+					acl {
+						tag = "owner@"
+						type = "ALLOW"
+						perms = ["FULL_CONTROL"]
+						flags = ["INHERIT"]
+					}
+
+					acl {
+						tag = "GROUP"
+						id = 568
+						type = "ALLOW"
+						perms = ["MODIFY"]
+						flags = ["FILE_INHERIT", "DIRECTORY_INHERIT"]
+					}
+
+					acl {
+						tag = "everyone@"
+						type = "DENY"
+						perms = ["WRITE_DATA", "APPEND_DATA"]
+					}
+
+					depends_on = [truenas_dataset.child]
+				}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "acl_type", aclTypeNFS4),
+					resource.TestCheckResourceAttr(resourceName, "user", "root"),
+					resource.TestCheckResourceAttr(resourceName, "group", "apps"),
+					resource.TestCheckResourceAttr(resourceName, "acl.#", "3"),
+					resource.TestCheckResourceAttr(resourceName, "acl.0.perms.0", "FULL_CONTROL"),
+					resource.TestCheckResourceAttr(resourceName, "acl.0.flags.0", "INHERIT"),
+					resource.TestCheckResourceAttr(resourceName, "acl.1.flags.#", "2"),
+					resource.TestCheckResourceAttr(resourceName, "acl.2.type", "DENY"),
+					resource.TestCheckResourceAttr(resourceName, "acl.2.perms.#", "2"),
+					resource.TestCheckResourceAttr(resourceName, "acl.2.flags.#", "0"),
+					testAccCheckFakeDatasetPermissions(f, "Tank/smb/child", 0, 568),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+				// recursive and traverse only affect how permissions are applied
+				ImportStateVerifyIgnore: []string{"recursive", "traverse"},
+			},
+		},
+	})
+}
+
+func TestUnitDatasetMountPath(t *testing.T) {
+	f := newFakeTrueNAS(t)
+	c := f.client()
+	ctx := context.Background()
+
+	assert.NoError(t, c.invoke(ctx, "pool.dataset.create", []interface{}{map[string]interface{}{"name": "Tank/data"}}, nil))
+	assert.NoError(t, c.invoke(ctx, "pool.dataset.create", []interface{}{map[string]interface{}{
+		"name":               "Tank/secret",
+		"encryption":         true,
+		"encryption_options": map[string]interface{}{"passphrase": "passphrase"},
+	}}, nil))
+
+	path, err := datasetMountPath(ctx, c, "Tank/data")
+	assert.NoError(t, err)
+	assert.Equal(t, "/mnt/Tank/data", path)
+
+	f.mu.Lock()
+	f.datasets["Tank/data"].Mountpoint = "/srv/data"
+	f.mu.Unlock()
+
+	path, err = datasetMountPath(ctx, c, "Tank/data")
+	assert.NoError(t, err)
+	assert.Equal(t, "/srv/data", path)
+
+	var stat filesystemStat
+	assert.NoError(t, c.invoke(ctx, "filesystem.stat", []interface{}{path}, &stat))
+
+	assert.NoError(t, lockDataset(ctx, c, "Tank/secret"))
+
+	_, err = datasetMountPath(ctx, c, "Tank/secret")
+	assert.EqualError(t, err, "dataset Tank/secret is not mounted")
+
+	_, err = datasetMountPath(ctx, c, "Tank/missing")
+	assert.True(t, isNotFoundError(nil, err))
+}
+
+func Test_expandACL(t *testing.T) {
+	set := func(values ...interface{}) *schema.Set {
+		return schema.NewSet(schema.HashString, values)
+	}
+
+	entries := expandACL([]interface{}{
+		map[string]interface{}{"tag": "owner@", "id": -1, "type": "ALLOW", "perms": set("MODIFY"), "flags": set("INHERIT"), "default": false},
+		map[string]interface{}{"tag": "everyone@", "id": -1, "type": "ALLOW", "perms": set("READ_DATA", "EXECUTE"), "flags": set(), "default": false},
+		map[string]interface{}{"tag": "USER_OBJ", "id": -1, "type": "", "perms": set("READ"), "flags": set(), "default": true},
+	})
+
+	assert.Equal(t, map[string]interface{}{aclBasicKey: "MODIFY"}, entries[0].Perms)
+	assert.Equal(t, map[string]interface{}{aclBasicKey: "INHERIT"}, entries[0].Flags)
+	assert.Equal(t, map[string]interface{}{"READ_DATA": true, "EXECUTE": true}, entries[1].Perms)
+	assert.Equal(t, map[string]interface{}{}, entries[1].Flags)
+	assert.Equal(t, map[string]interface{}{"READ": true, "WRITE": false, "EXECUTE": false}, entries[2].Perms)
+	assert.Nil(t, entries[2].Flags)
+	assert.Equal(t, true, *entries[2].Default)
+
+	flattened := flattenACL(aclTypeNFS4, entries[:2])
+	assert.Equal(t, []interface{}{"MODIFY"}, flattened[0].(map[string]interface{})["perms"])
+	assert.Equal(t, []interface{}{"EXECUTE", "READ_DATA"}, flattened[1].(map[string]interface{})["perms"])
+}
+
+func Test_suppressFilesystemModeDiff(t *testing.T) {
+	assert.True(t, suppressFilesystemModeDiff("mode", "0755", "755", nil))
+	assert.False(t, suppressFilesystemModeDiff("mode", "0755", "750", nil))
+	assert.False(t, suppressFilesystemModeDiff("mode", "", "750", nil))
+}
+
+func testAccCheckResourceTruenasDatasetPermissionsConfig(pool string, datasetName string, permissions string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "test" {
+		name = "%s"
+		pool = "%s"
+	}
+
+	resource "truenas_dataset_permissions" "test" {
+		dataset_id = truenas_dataset.test.id
+		%s
+	}
+	`, datasetName, pool, permissions)
+}
+
+func testAccCheckFakeDatasetPermissions(f *fakeTrueNAS, id string, uid int, gid int) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		ds := f.datasets[id]
+
+		if ds == nil {
+			return fmt.Errorf("dataset %s not found", id)
+		}
+
+		if ds.UID != uid || ds.GID != gid || ds.ACL == nil {
+			return fmt.Errorf("dataset %s permissions were not applied: uid %d, gid %d, acl %v", id, ds.UID, ds.GID, ds.ACL)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,211 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var datasetRollbackAPIAttrs = apiAttrMap{
+	"id":                       "snapshot_name",
+	"options.recursive":        "recursive",
+	"options.recursive_clones": "recursive_clones",
+	"options.force":            "force",
+}
+
+func resourceTrueNASDatasetRollback() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Roll back dataset or zvol to a snapshot. Rollback is performed on create, change `triggers` to roll back again. Destroying the resource does not change the dataset",
+		CreateContext: resourceTrueNASDatasetRollbackCreate,
+		ReadContext:   resourceTrueNASDatasetRollbackRead,
+		DeleteContext: resourceTrueNASDatasetRollbackDelete,
+		Schema: map[string]*schema.Schema{
+			"dataset_id": &schema.Schema{
+				Description:  "Dataset or zvol ID, eg. Tank/data, or pool name to roll back pool root dataset",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateDatasetID,
+			},
+			"snapshot_name": &schema.Schema{
+				Description:  "Name of the snapshot to roll back to",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringDoesNotContainAny("@/"),
+			},
+			"recursive": &schema.Schema{
+				Description: "Destroy snapshots newer than the rollback snapshot, rollback fails if there are newer snapshots otherwise",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+			},
+			"recursive_clones": &schema.Schema{
+				Description: "Destroy newer snapshots along with their clones, implies `recursive`",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+			},
+			"force": &schema.Schema{
+				Description: "Force unmount of any clones",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+			},
+			"triggers": &schema.Schema{
+				Description: "Arbitrary map of values, that when changed will roll back the dataset again",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// validateDatasetID checks that ID is in pool/dataset format understood by newDatasetPath,
+// bare pool name refers to pool root dataset
+func validateDatasetID(v interface{}, k string) ([]string, []error) {
+	id := v.(string)
+
+	if strings.Contains(id, "@") {
+		return nil, []error{fmt.Errorf("invalid %s %q, expected format: pool or pool/dataset", k, id)}
+	}
+
+	if p := newDatasetPath(id); p.Pool == "" || p.String() != id {
+		return nil, []error{fmt.Errorf("invalid %s %q, expected format: pool or pool/dataset", k, id)}
+	}
+
+	return nil, nil
+}
+
+func resourceTrueNASDatasetRollbackCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	dataset := d.Get("dataset_id").(string)
+	id := dataset + "@" + d.Get("snapshot_name").(string)
+	recursive := d.Get("recursive").(bool)
+	recursiveClones := d.Get("recursive_clones").(bool)
+
+	// middleware refuses to roll back past newer snapshots as well, but without saying which
+	if !recursive && !recursiveClones {
+		newer, err := newerSnapshots(ctx, c, id)
+
+		if err != nil {
+			return apiErrorDiags(err, "error rolling back dataset", nil)
+		}
+
+		if len(newer) > 0 {
+			return diag.Errorf("%s has snapshots newer than %s: %s, set recursive = true to destroy them", dataset, id, strings.Join(newer, ", "))
+		}
+	}
+
+	options := map[string]interface{}{
+		"recursive":        recursive,
+		"recursive_clones": recursiveClones,
+		"force":            d.Get("force").(bool),
+	}
+
+	log.Printf("[DEBUG] Rolling back TrueNAS dataset %s to %s: %+v", dataset, id, options)
+
+	if err := c.invoke(ctx, "zfs.snapshot.rollback", []interface{}{id, options}, nil); err != nil {
+		return apiErrorDiags(err, "error rolling back dataset", datasetRollbackAPIAttrs)
+	}
+
+	d.SetId(id)
+
+	log.Printf("[INFO] TrueNAS dataset %s rolled back to %s", dataset, id)
+
+	return resourceTrueNASDatasetRollbackRead(ctx, d, m)
+}
+
+func resourceTrueNASDatasetRollbackRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	id := d.Id()
+
+	dataset, name, err := parseSnapshotID(id)
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// rollback is done once, so only rolled back dataset has to exist
+	if err := c.invoke(ctx, "pool.dataset.get_instance", []interface{}{dataset}, nil); err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS dataset (%s) not found, removing rollback from state", dataset)
+			d.SetId("")
+			return nil
+		}
+		return apiErrorDiags(err, "error getting dataset", nil)
+	}
+
+	d.Set("dataset_id", dataset)
+	d.Set("snapshot_name", name)
+
+	return nil
+}
+
+func resourceTrueNASDatasetRollbackDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	log.Printf("[DEBUG] Removing TrueNAS dataset rollback (%s) from state, dataset is left as is", d.Id())
+	d.SetId("")
+
+	return nil
+}
+
+// newerSnapshots returns IDs of dataset snapshots taken after snapshot id, ordered by creation
+func newerSnapshots(ctx context.Context, c *Client, id string) ([]string, error) {
+	dataset, _, err := parseSnapshotID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []snapshot
+
+	if err := c.invoke(ctx, "zfs.snapshot.query", []interface{}{queryFilter("dataset", dataset)}, &snapshots); err != nil {
+		return nil, err
+	}
+
+	txg := make(map[string]int64, len(snapshots))
+
+	for _, s := range snapshots {
+		// createtxg orders snapshots taken within the same second
+		v, err := strconv.ParseInt(s.rawProperty("createtxg"), 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("error parsing createtxg of %s: %s", s.ID, err)
+		}
+
+		txg[s.ID] = v
+	}
+
+	target, ok := txg[id]
+
+	if !ok {
+		return nil, fmt.Errorf("snapshot %s does not exist", id)
+	}
+
+	var newer []string
+
+	for s, v := range txg {
+		if v > target {
+			newer = append(newer, s)
+		}
+	}
+
+	sort.Slice(newer, func(i, j int) bool { return txg[newer[i]] < txg[newer[j]] })
+
+	return newer, nil
+}
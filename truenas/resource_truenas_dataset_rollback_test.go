@@ -0,0 +1,203 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestAccResourceTruenasDatasetRollback_basic(t *testing.T) {
+	suffix := acctest.RandStringFromCharSet(5, acctest.CharSetAlphaNum)
+	datasetName := fmt.Sprintf("%s-%s", testResourcePrefix, suffix)
+	resourceName := "truenas_dataset_rollback.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckResourceTruenasDatasetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckResourceTruenasDatasetRollbackConfig(testPoolName, datasetName, `triggers = { run = "1" }`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", fmt.Sprintf("%s/%s@base", testPoolName, datasetName)),
+					resource.TestCheckResourceAttr(resourceName, "dataset_id", fmt.Sprintf("%s/%s", testPoolName, datasetName)),
+					resource.TestCheckResourceAttr(resourceName, "snapshot_name", "base"),
+				),
+			},
+			{
+				Config: testAccCheckResourceTruenasDatasetRollbackConfig(testPoolName, datasetName, `triggers = { run = "2" }`),
+				Check:  resource.TestCheckResourceAttr(resourceName, "triggers.run", "2"),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasDatasetRollback_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset_rollback.test"
+	c := f.client()
+	ctx := context.Background()
+
+	testFakeCloneOrigin(t, f, map[string]interface{}{"name": "Tank/data"}, "base")
+	assert.NoError(t, c.invoke(ctx, "zfs.snapshot.create", []interface{}{map[string]interface{}{"dataset": "Tank/data", "name": "later"}}, nil))
+	assert.NoError(t, c.invoke(ctx, "zfs.snapshot.clone", []interface{}{map[string]interface{}{"snapshot": "Tank/data@later", "dataset_dst": "Tank/dev"}}, nil))
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasDatasetRollbackConfig("Tank/data@base", ""),
+				ExpectError: regexp.MustCompile(`invalid dataset_id "Tank/data@base", expected format: pool or pool/dataset`),
+			},
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasDatasetRollbackConfig("Tank/data", ""),
+				ExpectError: regexp.MustCompile(`Tank/data has snapshots newer than Tank/data@base: Tank/data@later, set recursive = true`),
+			},
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasDatasetRollbackConfig("Tank/data", "recursive = true"),
+				ExpectError: regexp.MustCompile(`clones of previous snapshots exist`),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetRollbackConfig("Tank/data", `
+					recursive_clones = true
+					triggers = { run = "1" }
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/data@base"),
+					resource.TestCheckResourceAttr(resourceName, "dataset_id", "Tank/data"),
+					resource.TestCheckResourceAttr(resourceName, "snapshot_name", "base"),
+					testAccCheckFakeDatasetRollback(f, "Tank/data", "Tank/data@base"),
+					func(s *terraform.State) error {
+						f.mu.Lock()
+						defer f.mu.Unlock()
+
+						if f.snapshots["Tank/data@later"] != nil || f.datasets["Tank/dev"] != nil {
+							return fmt.Errorf("newer snapshot and its clone were not destroyed")
+						}
+
+						return nil
+					},
+				),
+			},
+			{
+				PreConfig: func() {
+					f.mu.Lock()
+					defer f.mu.Unlock()
+
+					f.datasets["Tank/data"].Rollback = ""
+				},
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetRollbackConfig("Tank/data", `
+					recursive_clones = true
+					triggers = { run = "2" }
+				`),
+				Check: testAccCheckFakeDatasetRollback(f, "Tank/data", "Tank/data@base"),
+			},
+			{
+				// pool root dataset
+				PreConfig: func() {
+					assert.NoError(t, c.invoke(ctx, "zfs.snapshot.create", []interface{}{map[string]interface{}{"dataset": "Tank", "name": "base"}}, nil))
+				},
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetRollbackConfig("Tank", ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank@base"),
+					testAccCheckFakeDatasetRollback(f, "Tank", "Tank@base"),
+				),
+			},
+		},
+	})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// destroy leaves dataset and snapshot as they are
+	assert.NotNil(t, f.datasets["Tank/data"])
+	assert.NotNil(t, f.snapshots["Tank/data@base"])
+}
+
+func TestUnitResourceTruenasDatasetRollback_missingSnapshot(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + `
+				resource "truenas_dataset" "test" {
+					name = "data"
+					pool = "Tank"
+				}
+
+				resource "truenas_dataset_rollback" "test" {
+					dataset_id = truenas_dataset.test.id
+					snapshot_name = "missing"
+				}
+				`,
+				ExpectError: regexp.MustCompile("snapshot Tank/data@missing does not exist"),
+			},
+		},
+	})
+}
+
+func Test_validateDatasetID(t *testing.T) {
+	for _, id := range []string{"Tank", "Tank/data", "Tank/data/child"} {
+		_, errs := validateDatasetID(id, "dataset_id")
+		assert.Empty(t, errs, id)
+	}
+
+	for _, id := range []string{"", "Tank@snap", "Tank/", "/data", "Tank//data", "Tank/data/", "Tank/data@snap"} {
+		_, errs := validateDatasetID(id, "dataset_id")
+		assert.NotEmpty(t, errs, id)
+	}
+}
+
+func testAccCheckResourceTruenasDatasetRollbackConfig(pool string, datasetName string, options string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "test" {
+		name = "%s"
+		pool = "%s"
+	}
+
+	resource "truenas_snapshot" "base" {
+		dataset = truenas_dataset.test.id
+		name = "base"
+	}
+
+	resource "truenas_dataset_rollback" "test" {
+		dataset_id = truenas_dataset.test.id
+		snapshot_name = truenas_snapshot.base.name
+		%s
+	}
+	`, datasetName, pool, options)
+}
+
+func testUnitResourceTruenasDatasetRollbackConfig(datasetID string, options string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset_rollback" "test" {
+		dataset_id = "%s"
+		snapshot_name = "base"
+		%s
+	}
+	`, datasetID, options)
+}
+
+func testAccCheckFakeDatasetRollback(f *fakeTrueNAS, id string, snapshot string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if ds := f.datasets[id]; ds == nil || ds.Rollback != snapshot {
+			return fmt.Errorf("dataset %s was not rolled back to %s", id, snapshot)
+		}
+
+		return nil
+	}
+}
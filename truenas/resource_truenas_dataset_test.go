@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	api "github.com/dariusbakunas/truenas-go-sdk"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 	"github.com/stretchr/testify/assert"
+	"regexp"
 	"testing"
 )
 
@@ -50,8 +52,651 @@ func TestAccResourceTruenasDataset_basic(t *testing.T) {
 	})
 }
 
+func TestUnitResourceTruenasDataset_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasDatasetConfig(fakePoolName, "data"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/data"),
+					resource.TestCheckResourceAttr(resourceName, "mount_point", "/mnt/Tank/data"),
+					resource.TestCheckResourceAttr(resourceName, "comments", "Test dataset"),
+					resource.TestCheckResourceAttr(resourceName, "compression", "gzip"),
+					resource.TestCheckResourceAttr(resourceName, "copies", "2"),
+					resource.TestCheckResourceAttr(resourceName, "quota_bytes", "2147483648"),
+					resource.TestCheckResourceAttr(resourceName, "quota_critical", "90"),
+					resource.TestCheckResourceAttr(resourceName, "record_size", "256K"),
+					resource.TestCheckResourceAttr(resourceName, "case_sensitivity", "mixed"),
+				),
+			},
+			{
+				Config: f.providerConfig() + fmt.Sprintf(`
+				resource "truenas_dataset" "test" {
+					name = "data"
+					pool = "%s"
+					comments = "Updated dataset"
+					compression = "lz4"
+					atime = "on"
+					quota_bytes = 4294967296
+					record_size = "1024K"
+					case_sensitivity = "mixed"
+				}
+				`, fakePoolName),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "comments", "Updated dataset"),
+					resource.TestCheckResourceAttr(resourceName, "compression", "lz4"),
+					resource.TestCheckResourceAttr(resourceName, "atime", "on"),
+					resource.TestCheckResourceAttr(resourceName, "quota_bytes", "4294967296"),
+					resource.TestCheckResourceAttr(resourceName, "record_size", "1024K"),
+				),
+			},
+			{
+				ResourceName:            resourceName,
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"inherit_encryption", "generate_key"},
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasDataset_encryption(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset.secret"
+	childName := "truenas_dataset.child"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`passphrase = "first-passphrase"`, `inherit_encryption = true`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "encrypted", "true"),
+					resource.TestCheckResourceAttr(resourceName, "encryption_root", "Tank/secret"),
+					resource.TestCheckResourceAttr(resourceName, "key_format", "passphrase"),
+					resource.TestCheckResourceAttr(resourceName, "key_loaded", "true"),
+					resource.TestCheckResourceAttr(resourceName, "locked", "false"),
+					resource.TestCheckResourceAttr(childName, "encrypted", "true"),
+					resource.TestCheckResourceAttr(childName, "encryption_root", "Tank/secret"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`passphrase = "second-passphrase"`, `inherit_encryption = true`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "encryption_root", "Tank/secret"),
+					testAccCheckFakeDatasetKey(f, "Tank/secret", "second-passphrase"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`
+					passphrase = "second-passphrase"
+					locked = true
+				`, `inherit_encryption = true`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "locked", "true"),
+					resource.TestCheckResourceAttr(resourceName, "key_loaded", "false"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`
+					passphrase = "third-passphrase"
+					locked = true
+				`, `inherit_encryption = true`),
+				ExpectError: regexp.MustCompile("dataset Tank/secret is locked, set locked = false to change passphrase"),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`
+					passphrase = "third-passphrase"
+					locked = false
+				`, `inherit_encryption = true`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "locked", "false"),
+					testAccCheckFakeDatasetKey(f, "Tank/secret", "third-passphrase"),
+				),
+			},
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`passphrase = "third-passphrase"`, `inherit_encryption = false`),
+				ExpectError: regexp.MustCompile("dataset Tank/secret/child key can not be changed without new passphrase"),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`passphrase = "third-passphrase"`, `
+					inherit_encryption = false
+					passphrase = "child-passphrase"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(childName, "locked", "false"),
+					resource.TestCheckResourceAttr(childName, "encryption_root", "Tank/secret/child"),
+					resource.TestCheckResourceAttr(childName, "key_format", "passphrase"),
+					testAccCheckFakeDatasetKey(f, "Tank/secret/child", "child-passphrase"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`passphrase = "third-passphrase"`, `inherit_encryption = true`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(childName, "encryption_root", "Tank/secret"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`
+					encryption_key = "`+fakeGeneratedKey+`"
+					locked = true
+				`, `inherit_encryption = true`),
+				ExpectError: regexp.MustCompile("only passphrase encrypted datasets can be locked"),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasDataset_keyChange(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset.secret"
+	key := "ffeeddccbbaa99887766554433221100ffeeddccbbaa99887766554433221100"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`generate_key = true`, `inherit_encryption = true`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "key_format", "hex"),
+					testAccCheckFakeDatasetKey(f, "Tank/secret", fakeGeneratedKey),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`generate_key = false`, `inherit_encryption = true`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "generate_key", "false"),
+					testAccCheckFakeDatasetKey(f, "Tank/secret", fakeGeneratedKey),
+					testAccCheckFakeJobCount(f, "pool.dataset.change_key", 0),
+				),
+			},
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`pbkdf2iters = 500000`, `inherit_encryption = true`),
+				ExpectError: regexp.MustCompile("pbkdf2iters can only be changed for passphrase encrypted datasets"),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetEncryptionConfig(`encryption_key = "`+key+`"`, `inherit_encryption = true`),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckFakeDatasetKey(f, "Tank/secret", key),
+					testAccCheckFakeJobCount(f, "pool.dataset.change_key", 1),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitDatasetUnlock_invalidPassphrase(t *testing.T) {
+	f := newFakeTrueNAS(t)
+	c := f.client()
+	ctx := context.Background()
+
+	err := c.invoke(ctx, "pool.dataset.create", []interface{}{map[string]interface{}{
+		"name":               "Tank/secret",
+		"encryption":         true,
+		"encryption_options": map[string]interface{}{"passphrase": "passphrase"},
+	}}, nil)
+
+	assert.NoError(t, err)
+	assert.NoError(t, lockDataset(ctx, c, "Tank/secret"))
+	assert.EqualError(t, unlockDataset(ctx, c, "Tank/secret", "wrong", ""), "failed to unlock Tank/secret: Invalid Key")
+	assert.NoError(t, unlockDataset(ctx, c, "Tank/secret", "passphrase", ""))
+}
+
+func TestUnitResourceTruenasDataset_rename(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset.test"
+
+	var original *fakeDataset
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetRenameConfig("old", "data", "apps"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/old/data"),
+					resource.TestCheckResourceAttr("truenas_dataset.child", "id", "Tank/old/data/apps"),
+					func(s *terraform.State) error {
+						f.mu.Lock()
+						defer f.mu.Unlock()
+
+						original = f.datasets["Tank/old/data"]
+
+						return nil
+					},
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetRenameConfig("old", "media", "apps"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/old/media"),
+					resource.TestCheckResourceAttr(resourceName, "name", "media"),
+					resource.TestCheckResourceAttr(resourceName, "mount_point", "/mnt/Tank/old/media"),
+					resource.TestCheckResourceAttr("truenas_dataset.child", "id", "Tank/old/media/apps"),
+					resource.TestCheckResourceAttr("truenas_dataset.child", "comments", "/mnt/Tank/old/media"),
+					testAccCheckFakeDatasetMoved(f, "Tank/old/data", "Tank/old/media", &original),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetRenameConfig("new", "media", "apps"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/new/media"),
+					resource.TestCheckResourceAttr(resourceName, "parent", "new"),
+					resource.TestCheckResourceAttr("truenas_dataset.child", "id", "Tank/new/media/apps"),
+					resource.TestCheckResourceAttr("truenas_dataset.child", "parent", "new/media"),
+					testAccCheckFakeDatasetMoved(f, "Tank/old/media", "Tank/new/media", &original),
+				),
+			},
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasDatasetRenameConfig("new", "media", "media"),
+				ExpectError: regexp.MustCompile("Tank/new/media already exists"),
+			},
+		},
+	})
+}
+
+func TestUnitDatasetShareWarnings(t *testing.T) {
+	f := newFakeTrueNAS(t)
+	c := f.client()
+	ctx := context.Background()
+
+	for _, name := range []string{"Tank/data", "Tank/data/exports", "Tank/database", "Tank/archive"} {
+		assert.NoError(t, c.invoke(ctx, "pool.dataset.create", []interface{}{map[string]interface{}{"name": name}}, nil))
+	}
+
+	assert.NoError(t, c.invoke(ctx, "sharing.nfs.create", []interface{}{map[string]interface{}{"paths": []interface{}{"/mnt/Tank/data/exports"}}}, nil))
+	assert.NoError(t, c.invoke(ctx, "sharing.smb.create", []interface{}{map[string]interface{}{"name": "data", "path": "/mnt/Tank/data"}}, nil))
+	assert.NoError(t, c.invoke(ctx, "sharing.smb.create", []interface{}{map[string]interface{}{"name": "database", "path": "/mnt/Tank/database"}}, nil))
+
+	oldPath, err := datasetMountPath(ctx, c, "Tank/data")
+	assert.NoError(t, err)
+	assert.NoError(t, renameDataset(ctx, c, "Tank/data", "Tank/archive/data"))
+
+	diags := datasetShareWarnings(ctx, c, "Tank/data", oldPath, "Tank/archive/data")
+
+	assert.Len(t, diags, 2)
+	assert.Equal(t, diag.Warning, diags[0].Severity)
+	assert.Contains(t, diags[0].Summary, "NFS share")
+	assert.Contains(t, diags[0].Detail, "/mnt/Tank/archive/data/exports")
+	assert.Contains(t, diags[1].Summary, "SMB share")
+	assert.Contains(t, diags[1].Detail, "/mnt/Tank/archive/data")
+}
+
+func TestUnitResourceTruenasDataset_clone(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset.test"
+
+	testFakeCloneOrigin(t, f, map[string]interface{}{"name": "Tank/prod", "compression": "ZSTD", "atime": "OFF"}, "base")
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+			testAccCheckFakeSnapshotExists(f, "Tank/prod@base"),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasDatasetCloneConfig(`case_sensitivity = "insensitive"`),
+				ExpectError: regexp.MustCompile(`"clone_from_snapshot": conflicts with case_sensitivity`),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetCloneConfig(`comments = "Test environment"`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/test"),
+					resource.TestCheckResourceAttr(resourceName, "origin", "Tank/prod@base"),
+					resource.TestCheckResourceAttr(resourceName, "comments", "Test environment"),
+					resource.TestCheckResourceAttr(resourceName, "compression", "zstd"),
+					resource.TestCheckResourceAttr(resourceName, "atime", "off"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetCloneConfig(`
+					comments = "Test environment"
+					atime = "on"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "origin", "Tank/prod@base"),
+					resource.TestCheckResourceAttr(resourceName, "atime", "on"),
+				),
+			},
+			{
+				ResourceName:            resourceName,
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"clone_from_snapshot", "promote", "inherit_encryption", "generate_key"},
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasDataset_clonePromote(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset.test"
+
+	testFakeCloneOrigin(t, f, map[string]interface{}{"name": "Tank/prod"}, "base")
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetCloneConfig(""),
+				Check:  resource.TestCheckResourceAttr(resourceName, "origin", "Tank/prod@base"),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetCloneConfig("promote = true"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "origin", ""),
+					resource.TestCheckResourceAttr(resourceName, "promote", "true"),
+					testAccCheckFakeSnapshotExists(f, "Tank/test@base"),
+					func(s *terraform.State) error {
+						f.mu.Lock()
+						defer f.mu.Unlock()
+
+						if origin := f.datasets["Tank/prod"].Origin; origin != "Tank/test@base" {
+							return fmt.Errorf("expected Tank/prod to be a clone of Tank/test@base, got %q", origin)
+						}
+
+						return nil
+					},
+				),
+			},
+			{
+				// promoted clone can only be destroyed once former origin dataset is gone
+				PreConfig: func() {
+					f.mu.Lock()
+					defer f.mu.Unlock()
+
+					delete(f.datasets, "Tank/prod")
+					delete(f.snapshots, "Tank/test@base")
+				},
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetCloneConfig("promote = true"),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasDataset_properties(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasDatasetPropertiesConfig(`special_small_block_size = "3K"`),
+				ExpectError: regexp.MustCompile(`expected special_small_block_size to be one of`),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetPropertiesConfig(`
+					acl_type = "nfsv4"
+					checksum = "sha256"
+					dnode_size = "auto"
+					log_bias = "inherit"
+					primary_cache = "metadata"
+					secondary_cache = "none"
+					special_small_block_size = "64K"
+					xattr = "on"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "acl_type", "nfsv4"),
+					resource.TestCheckResourceAttr(resourceName, "checksum", "sha256"),
+					resource.TestCheckResourceAttr(resourceName, "dnode_size", "auto"),
+					resource.TestCheckResourceAttr(resourceName, "log_bias", "inherit"),
+					resource.TestCheckResourceAttr(resourceName, "primary_cache", "metadata"),
+					resource.TestCheckResourceAttr(resourceName, "secondary_cache", "none"),
+					resource.TestCheckResourceAttr(resourceName, "special_small_block_size", "64K"),
+					resource.TestCheckResourceAttr(resourceName, "xattr", "on"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.checksum", "local"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.logbias", "inherited"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.sync", "default"),
+					testAccCheckFakeDatasetProperty(f, "Tank/parent/data", "special_small_block_size", "65536"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetPropertiesConfig(`
+					acl_type = "nfsv4"
+					checksum = "inherit"
+					dnode_size = "auto"
+					log_bias = "latency"
+					primary_cache = "inherit"
+					secondary_cache = "none"
+					special_small_block_size = "0"
+					xattr = "on"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "checksum", "inherit"),
+					resource.TestCheckResourceAttr(resourceName, "log_bias", "latency"),
+					resource.TestCheckResourceAttr(resourceName, "primary_cache", "inherit"),
+					resource.TestCheckResourceAttr(resourceName, "special_small_block_size", "0"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.checksum", "default"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.logbias", "local"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.primarycache", "default"),
+					testAccCheckFakeDatasetProperty(f, "Tank/parent/data", "checksum", "ON"),
+					testAccCheckFakeDatasetProperty(f, "Tank/parent/data", "logbias", "LATENCY"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasDataset_inherit(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetInheritConfig(`
+					compression = "zstd"
+					atime = "off"
+					copies = 2
+					sync = "inherit"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "compression", "zstd"),
+					resource.TestCheckResourceAttr(resourceName, "atime", "off"),
+					resource.TestCheckResourceAttr(resourceName, "copies", "2"),
+					resource.TestCheckResourceAttr(resourceName, "sync", "inherit"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.compression", "local"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.sync", "default"),
+					resource.TestCheckResourceAttr("truenas_dataset.smb", "acl_type", "nfsv4"),
+				),
+			},
+			{
+				// removed properties are inherited
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetInheritConfig(`
+					sync = "always"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "compression", "gzip"),
+					resource.TestCheckResourceAttr(resourceName, "atime", "on"),
+					resource.TestCheckResourceAttr(resourceName, "copies", "1"),
+					resource.TestCheckResourceAttr(resourceName, "sync", "always"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.compression", "inherited"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.atime", "default"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.copies", "default"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.sync", "local"),
+					resource.TestCheckResourceAttr("truenas_dataset.smb", "acl_type", "nfsv4"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetInheritConfig(`
+					compression = "inherit"
+					sync = "inherit"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "compression", "inherit"),
+					resource.TestCheckResourceAttr(resourceName, "sync", "inherit"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.sync", "default"),
+					testAccCheckFakeDatasetProperty(f, "Tank/parent/data", "sync", "STANDARD"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasDataset_userProperties(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasDatasetUserPropertiesConfig(`owner = "ops"`, ""),
+				ExpectError: regexp.MustCompile(`invalid user_properties key "owner", expected format: namespace:name`),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetUserPropertiesConfig(`
+					"org:owner" = "ops"
+					"org:cost-center" = "42"
+				`, ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "user_properties.%", "2"),
+					resource.TestCheckResourceAttr(resourceName, "user_properties.org:owner", "ops"),
+					resource.TestCheckResourceAttr(resourceName, "user_properties.org:cost-center", "42"),
+				),
+			},
+			{
+				// properties not declared in config are left as they are
+				PreConfig: func() {
+					f.mu.Lock()
+					defer f.mu.Unlock()
+
+					f.datasets["Tank/parent/data"].User["org:unmanaged"] = "script"
+				},
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetUserPropertiesConfig(`
+					"org:owner" = "dba"
+				`, `
+				data "truenas_dataset" "test" {
+					dataset_id = truenas_dataset.test.id
+					depends_on = [truenas_dataset.test]
+				}
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "user_properties.%", "1"),
+					resource.TestCheckResourceAttr(resourceName, "user_properties.org:owner", "dba"),
+					resource.TestCheckResourceAttr("data.truenas_dataset.test", "user_properties.%", "3"),
+					resource.TestCheckResourceAttr("data.truenas_dataset.test", "user_properties.org:owner", "dba"),
+					resource.TestCheckResourceAttr("data.truenas_dataset.test", "user_properties.org:unmanaged", "script"),
+					resource.TestCheckResourceAttr("data.truenas_dataset.test", "user_properties.org:backup-tier", "gold"),
+					func(s *terraform.State) error {
+						f.mu.Lock()
+						defer f.mu.Unlock()
+
+						if _, ok := f.datasets["Tank/parent/data"].User["org:cost-center"]; ok {
+							return fmt.Errorf("org:cost-center was not removed")
+						}
+
+						return nil
+					},
+				),
+			},
+			{
+				// managed property removed outside of terraform is set again
+				PreConfig: func() {
+					f.mu.Lock()
+					defer f.mu.Unlock()
+
+					delete(f.datasets["Tank/parent/data"].User, "org:owner")
+				},
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetUserPropertiesConfig(`
+					"org:owner" = "dba"
+				`, ""),
+				Check: resource.TestCheckResourceAttr(resourceName, "user_properties.org:owner", "dba"),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetUserPropertiesConfig(`
+					"org:owner" = "dba"
+					"org:backup-tier" = "gold"
+				`, ""),
+				Check: resource.TestCheckResourceAttr(resourceName, "user_properties.org:backup-tier", "gold"),
+			},
+			{
+				// managed property that is only inherited from parent, even with the same value, is set locally again
+				PreConfig: func() {
+					f.mu.Lock()
+					defer f.mu.Unlock()
+
+					delete(f.datasets["Tank/parent/data"].User, "org:backup-tier")
+				},
+				Config: f.providerConfig() + testUnitResourceTruenasDatasetUserPropertiesConfig(`
+					"org:owner" = "dba"
+					"org:backup-tier" = "gold"
+				`, ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "user_properties.org:backup-tier", "gold"),
+					func(s *terraform.State) error {
+						f.mu.Lock()
+						defer f.mu.Unlock()
+
+						if _, ok := f.datasets["Tank/parent/data"].User["org:backup-tier"]; !ok {
+							return fmt.Errorf("org:backup-tier was not set locally")
+						}
+
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasDataset_missingParent(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + fmt.Sprintf(`
+				resource "truenas_dataset" "test" {
+					name = "data"
+					pool = "%s"
+					parent = "missing"
+				}
+				`, fakePoolName),
+				ExpectError: regexp.MustCompile("Parent dataset Tank/missing does not exist"),
+			},
+		},
+	})
+}
+
 func testAccCheckResourceTruenasDatasetDestroy(s *terraform.State) error {
-	client := testAccProvider.Meta().(*api.APIClient)
+	client := testAccProvider.Meta().(*Client)
 
 	// loop through the resources in state, verifying each widget
 	// is destroyed
@@ -83,6 +728,7 @@ func Test_datasetPathString(t *testing.T) {
 	}{
 		{path: datasetPath{Pool: "Tank", Parent: "home", Name: "Test"}, expected: "Tank/home/Test"},
 		{path: datasetPath{Pool: "Tank", Parent: "", Name: "Test"}, expected: "Tank/Test"},
+		{path: datasetPath{Pool: "Tank"}, expected: "Tank"},
 		{path: datasetPath{Pool: "Tank", Parent: "//home/sub//", Name: "Test"}, expected: "Tank/home/sub/Test"},
 		{path: datasetPath{Pool: "TankV2", Parent: "/home/", Name: "Test"}, expected: "TankV2/home/Test"},
 	}
@@ -100,6 +746,7 @@ func Test_newDatasetPath(t *testing.T) {
 	}{
 		{expected: datasetPath{Pool: "Tank", Parent: "home", Name: "Test"}, path: "Tank/home/Test"},
 		{expected: datasetPath{Pool: "Tank", Parent: "", Name: "Test"}, path: "Tank/Test"},
+		{expected: datasetPath{Pool: "Tank"}, path: "Tank"},
 		{expected: datasetPath{Pool: "Tank", Parent: "home/sub", Name: "Test"}, path: "Tank/home/sub/Test"},
 		{expected: datasetPath{Pool: "TankV2", Parent: "home", Name: "Test"}, path: "TankV2/home/Test"},
 	}
@@ -136,6 +783,58 @@ func testAccCheckResourceTruenasDatasetConfig(pool string, name string) string {
 	`, name, pool)
 }
 
+func testUnitResourceTruenasDatasetEncryptionConfig(secret string, child string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "secret" {
+		name = "secret"
+		pool = "%s"
+		encrypted = true
+		%s
+	}
+
+	resource "truenas_dataset" "child" {
+		name = "child"
+		pool = "%s"
+		parent = truenas_dataset.secret.name
+		%s
+	}
+	`, fakePoolName, secret, fakePoolName, child)
+}
+
+func testAccCheckFakeDatasetKey(f *fakeTrueNAS, id string, key string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if ds := f.datasets[id]; ds == nil || ds.Key != key {
+			return fmt.Errorf("dataset %s key was not changed", id)
+		}
+
+		return nil
+	}
+}
+
+func testAccCheckFakeJobCount(f *fakeTrueNAS, method string, expected int) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		count := 0
+
+		for _, job := range f.jobs {
+			if job["method"] == method {
+				count++
+			}
+		}
+
+		if count != expected {
+			return fmt.Errorf("expected %d %s jobs, got %d", expected, method, count)
+		}
+
+		return nil
+	}
+}
+
 func testAccCheckTruenasDatasetResourceExists(n string, dataset *api.Dataset) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[n]
@@ -147,7 +846,7 @@ func testAccCheckTruenasDatasetResourceExists(n string, dataset *api.Dataset) re
 			return fmt.Errorf("no dataset ID is set")
 		}
 
-		client := testAccProvider.Meta().(*api.APIClient)
+		client := testAccProvider.Meta().(*Client)
 
 		resp, _, err := client.DatasetApi.GetDataset(context.Background(), rs.Primary.ID).Execute()
 
@@ -163,3 +862,167 @@ func testAccCheckTruenasDatasetResourceExists(n string, dataset *api.Dataset) re
 		return nil
 	}
 }
+
+func testUnitResourceTruenasDatasetRenameConfig(parent string, name string, sibling string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "old" {
+		name = "old"
+		pool = "Tank"
+	}
+
+	resource "truenas_dataset" "new" {
+		name = "new"
+		pool = "Tank"
+	}
+
+	resource "truenas_dataset" "test" {
+		name = "%s"
+		pool = "Tank"
+		parent = truenas_dataset.%s.name
+	}
+
+	resource "truenas_dataset" "child" {
+		name = "apps"
+		pool = "Tank"
+		parent = "${truenas_dataset.test.parent}/${truenas_dataset.test.name}"
+		comments = truenas_dataset.test.mount_point
+	}
+
+	resource "truenas_dataset" "sibling" {
+		name = "%s"
+		pool = "Tank"
+		parent = truenas_dataset.new.name
+	}
+	`, name, parent, sibling)
+}
+
+// testAccCheckFakeDatasetMoved checks dataset was renamed in place rather than re-created
+func testAccCheckFakeDatasetMoved(f *fakeTrueNAS, oldID string, newID string, original **fakeDataset) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if _, ok := f.datasets[oldID]; ok {
+			return fmt.Errorf("dataset %s still exists", oldID)
+		}
+
+		if ds := f.datasets[newID]; ds == nil || ds != *original {
+			return fmt.Errorf("dataset %s was not moved to %s", oldID, newID)
+		}
+
+		return nil
+	}
+}
+
+func testUnitResourceTruenasDatasetCloneConfig(options string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "test" {
+		name = "test"
+		pool = "Tank"
+		clone_from_snapshot = "Tank/prod@base"
+		%s
+	}
+	`, options)
+}
+
+// testFakeCloneOrigin creates dataset or zvol outside of terraform along with snapshot to clone
+func testFakeCloneOrigin(t *testing.T, f *fakeTrueNAS, dataset map[string]interface{}, snapshot string) {
+	c := f.client()
+	ctx := context.Background()
+
+	if err := c.invoke(ctx, "pool.dataset.create", []interface{}{dataset}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.invoke(ctx, "zfs.snapshot.create", []interface{}{map[string]interface{}{"dataset": dataset["name"], "name": snapshot}}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testAccCheckFakeSnapshotExists(f *fakeTrueNAS, id string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if f.snapshots[id] == nil {
+			return fmt.Errorf("snapshot %s not found", id)
+		}
+
+		return nil
+	}
+}
+
+func testUnitResourceTruenasDatasetPropertiesConfig(options string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "parent" {
+		name = "parent"
+		pool = "Tank"
+		log_bias = "throughput"
+	}
+
+	resource "truenas_dataset" "test" {
+		name = "data"
+		pool = "Tank"
+		parent = truenas_dataset.parent.name
+		%s
+	}
+	`, options)
+}
+
+func testAccCheckFakeDatasetProperty(f *fakeTrueNAS, id string, name string, expected string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if ds := f.datasets[id]; ds == nil || ds.Props[name] != expected {
+			return fmt.Errorf("expected %s property %s to be %q", id, name, expected)
+		}
+
+		return nil
+	}
+}
+
+func testUnitResourceTruenasDatasetInheritConfig(options string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "parent" {
+		name = "parent"
+		pool = "Tank"
+		compression = "gzip"
+	}
+
+	resource "truenas_dataset" "test" {
+		name = "data"
+		pool = "Tank"
+		parent = truenas_dataset.parent.name
+		%s
+	}
+
+	resource "truenas_dataset" "smb" {
+		name = "smb"
+		pool = "Tank"
+		share_type = "smb"
+	}
+	`, options)
+}
+
+func testUnitResourceTruenasDatasetUserPropertiesConfig(props string, extra string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "parent" {
+		name = "parent"
+		pool = "Tank"
+		user_properties = {
+			"org:backup-tier" = "gold"
+		}
+	}
+
+	resource "truenas_dataset" "test" {
+		name = "data"
+		pool = "Tank"
+		parent = truenas_dataset.parent.name
+		user_properties = {
+			%s
+		}
+	}
+	%s
+	`, props, extra)
+}
@@ -0,0 +1,291 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Quota types accepted by pool.dataset.set_quota, object quotas limit number of files owned by user or group
+const (
+	quotaTypeUser     = "USER"
+	quotaTypeGroup    = "GROUP"
+	quotaTypeUserObj  = "USEROBJ"
+	quotaTypeGroupObj = "GROUPOBJ"
+)
+
+var userQuotaTypes = []string{quotaTypeUser, quotaTypeGroup, quotaTypeUserObj, quotaTypeGroupObj}
+
+var datasetUserQuotaAPIAttrs = apiAttrMap{
+	"quotas.0.id":          "name",
+	"quotas.0.quota_type":  "quota_type",
+	"quotas.0.quota_value": "quota_value",
+}
+
+// datasetQuota is pool.dataset.get_quota entry, USER and GROUP entries report object quotas as well
+type datasetQuota struct {
+	QuotaType      string  `json:"quota_type"`
+	ID             int     `json:"id"`
+	Name           *string `json:"name"`
+	Quota          int64   `json:"quota"`
+	UsedBytes      int64   `json:"used_bytes"`
+	UsedPercent    float64 `json:"used_percent"`
+	ObjQuota       int64   `json:"obj_quota"`
+	ObjUsed        int64   `json:"obj_used"`
+	ObjUsedPercent float64 `json:"obj_used_percent"`
+}
+
+// limit returns quota value of given quota type
+func (q *datasetQuota) limit(quotaType string) int64 {
+	if isObjectQuota(quotaType) {
+		return q.ObjQuota
+	}
+
+	return q.Quota
+}
+
+func isObjectQuota(quotaType string) bool {
+	return quotaType == quotaTypeUserObj || quotaType == quotaTypeGroupObj
+}
+
+// userQuotaID returns dataset quota ID in format pool/dataset:TYPE:name
+func userQuotaID(dataset string, quotaType string, name string) string {
+	return strings.Join([]string{dataset, quotaType, name}, ":")
+}
+
+// parseUserQuotaID splits dataset quota ID into dataset, quota type and user or group name
+func parseUserQuotaID(id string) (string, string, string, error) {
+	s := strings.Split(id, ":")
+
+	if len(s) < 3 || s[len(s)-1] == "" || strings.Join(s[:len(s)-2], ":") == "" {
+		return "", "", "", fmt.Errorf("invalid quota ID %q, expected format: pool/dataset:TYPE:name", id)
+	}
+
+	quotaType := s[len(s)-2]
+
+	for _, t := range userQuotaTypes {
+		if t == quotaType {
+			return strings.Join(s[:len(s)-2], ":"), quotaType, s[len(s)-1], nil
+		}
+	}
+
+	return "", "", "", fmt.Errorf("invalid quota type %q in ID %q, expected one of: %s", quotaType, id, strings.Join(userQuotaTypes, ", "))
+}
+
+func resourceTrueNASDatasetUserQuota() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Manage ZFS user or group quota on a dataset, limiting space or number of objects owned by a single user or group",
+		CreateContext: resourceTrueNASDatasetUserQuotaCreate,
+		ReadContext:   resourceTrueNASDatasetUserQuotaRead,
+		UpdateContext: resourceTrueNASDatasetUserQuotaUpdate,
+		DeleteContext: resourceTrueNASDatasetUserQuotaDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceTrueNASDatasetUserQuotaImport,
+		},
+		Schema: map[string]*schema.Schema{
+			"dataset_id": &schema.Schema{
+				Description: "Dataset ID, eg. Tank/home",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"quota_type": &schema.Schema{
+				Description:  "Quota type: USER or GROUP limit space in bytes, USEROBJ or GROUPOBJ limit number of objects",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(userQuotaTypes, false),
+			},
+			"name": &schema.Schema{
+				Description:  "User or group name, or numeric ID",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringDoesNotContainAny(":"),
+			},
+			"quota_value": &schema.Schema{
+				Description:  "Quota in bytes for USER and GROUP quotas, or number of objects for USEROBJ and GROUPOBJ quotas",
+				Type:         schema.TypeInt,
+				Required:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"xid": &schema.Schema{
+				Description: "Numeric user or group ID",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"used_bytes": &schema.Schema{
+				Description: "Space used by user or group in bytes",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"used_percent": &schema.Schema{
+				Description: "Used space in percent of quota, only set for USER and GROUP quotas",
+				Type:        schema.TypeFloat,
+				Computed:    true,
+			},
+			"obj_used": &schema.Schema{
+				Description: "Number of objects owned by user or group",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"obj_used_percent": &schema.Schema{
+				Description: "Used objects in percent of quota, only set for USEROBJ and GROUPOBJ quotas",
+				Type:        schema.TypeFloat,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceTrueNASDatasetUserQuotaCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	dataset := d.Get("dataset_id").(string)
+	quotaType := d.Get("quota_type").(string)
+	name := d.Get("name").(string)
+
+	if err := setDatasetQuota(ctx, m.(*Client), dataset, quotaType, name, int64(d.Get("quota_value").(int))); err != nil {
+		return apiErrorDiags(err, "error creating dataset quota", datasetUserQuotaAPIAttrs)
+	}
+
+	d.SetId(userQuotaID(dataset, quotaType, name))
+
+	log.Printf("[INFO] TrueNAS dataset quota (%s) created", d.Id())
+
+	return resourceTrueNASDatasetUserQuotaRead(ctx, d, m)
+}
+
+func resourceTrueNASDatasetUserQuotaRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+	id := d.Id()
+
+	dataset, quotaType, name, err := parseUserQuotaID(id)
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	quota, err := getDatasetQuota(ctx, c, dataset, quotaType, name)
+
+	if err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS dataset (%s) not found, removing quota from state", dataset)
+			d.SetId("")
+			return nil
+		}
+		return apiErrorDiags(err, "error getting dataset quota", nil)
+	}
+
+	// quota set to 0 is the same as no quota
+	if quota == nil || quota.limit(quotaType) == 0 {
+		log.Printf("[WARN] TrueNAS dataset quota (%s) not found, removing from state", id)
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("dataset_id", dataset)
+	d.Set("quota_type", quotaType)
+	d.Set("name", name)
+	d.Set("quota_value", quota.limit(quotaType))
+	d.Set("xid", quota.ID)
+	d.Set("used_bytes", quota.UsedBytes)
+	d.Set("obj_used", quota.ObjUsed)
+
+	if isObjectQuota(quotaType) {
+		d.Set("used_percent", 0)
+		d.Set("obj_used_percent", quota.ObjUsedPercent)
+	} else {
+		d.Set("used_percent", quota.UsedPercent)
+		d.Set("obj_used_percent", 0)
+	}
+
+	return nil
+}
+
+func resourceTrueNASDatasetUserQuotaUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	dataset, quotaType, name, err := parseUserQuotaID(d.Id())
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := setDatasetQuota(ctx, m.(*Client), dataset, quotaType, name, int64(d.Get("quota_value").(int))); err != nil {
+		return apiErrorDiags(err, "error updating dataset quota", datasetUserQuotaAPIAttrs)
+	}
+
+	log.Printf("[INFO] TrueNAS dataset quota (%s) updated", d.Id())
+
+	return resourceTrueNASDatasetUserQuotaRead(ctx, d, m)
+}
+
+func resourceTrueNASDatasetUserQuotaDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	dataset, quotaType, name, err := parseUserQuotaID(d.Id())
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[DEBUG] Deleting TrueNAS dataset quota: %s", d.Id())
+
+	if err := setDatasetQuota(ctx, m.(*Client), dataset, quotaType, name, 0); err != nil {
+		// quotas are destroyed along with dataset
+		if isNotFoundError(nil, err) {
+			d.SetId("")
+			return nil
+		}
+		return apiErrorDiags(err, "error deleting dataset quota", nil)
+	}
+
+	log.Printf("[INFO] TrueNAS dataset quota (%s) deleted", d.Id())
+	d.SetId("")
+
+	return nil
+}
+
+func resourceTrueNASDatasetUserQuotaImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	if _, _, _, err := parseUserQuotaID(d.Id()); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// setDatasetQuota sets user or group quota of a dataset, quota value 0 removes quota
+func setDatasetQuota(ctx context.Context, c *Client, dataset string, quotaType string, name string, value int64) error {
+	quotas := []interface{}{
+		map[string]interface{}{
+			"quota_type":  quotaType,
+			"id":          name,
+			"quota_value": value,
+		},
+	}
+
+	log.Printf("[DEBUG] Setting TrueNAS dataset (%s) quota: %+v", dataset, quotas)
+
+	return c.invoke(ctx, "pool.dataset.set_quota", []interface{}{dataset, quotas}, nil)
+}
+
+// getDatasetQuota returns quota entry of user or group given by name or numeric ID, or nil if there is none.
+// Object quotas are reported by USER and GROUP entries
+func getDatasetQuota(ctx context.Context, c *Client, dataset string, quotaType string, name string) (*datasetQuota, error) {
+	queryType := strings.TrimSuffix(quotaType, "OBJ")
+
+	var quotas []datasetQuota
+
+	if err := c.invoke(ctx, "pool.dataset.get_quota", []interface{}{dataset, queryType}, &quotas); err != nil {
+		return nil, err
+	}
+
+	for i := range quotas {
+		if (quotas[i].Name != nil && *quotas[i].Name == name) || strconv.Itoa(quotas[i].ID) == name {
+			return &quotas[i], nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,185 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestAccResourceTruenasDatasetUserQuota_basic(t *testing.T) {
+	suffix := acctest.RandStringFromCharSet(5, acctest.CharSetAlphaNum)
+	datasetName := fmt.Sprintf("%s-%s", testResourcePrefix, suffix)
+	resourceName := "truenas_dataset_user_quota.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckResourceTruenasDatasetUserQuotaDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckResourceTruenasDatasetUserQuotaConfig(testPoolName, datasetName, "USER", "root", 1073741824),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", fmt.Sprintf("%s/%s:USER:root", testPoolName, datasetName)),
+					resource.TestCheckResourceAttr(resourceName, "quota_value", "1073741824"),
+					resource.TestCheckResourceAttr(resourceName, "xid", "0"),
+					resource.TestCheckResourceAttrSet(resourceName, "used_bytes"),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasDatasetUserQuota_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_dataset_user_quota.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasDatasetUserQuotaConfig(fakePoolName, "home", "USER", "apps", 1073741824) + `
+				resource "truenas_dataset_user_quota" "objects" {
+					dataset_id = truenas_dataset.test.id
+					quota_type = "USEROBJ"
+					name = "568"
+					quota_value = 10000
+				}
+
+				resource "truenas_dataset_user_quota" "group" {
+					dataset_id = truenas_dataset.test.id
+					quota_type = "GROUP"
+					name = "nogroup"
+					quota_value = 5368709120
+				}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/home:USER:apps"),
+					resource.TestCheckResourceAttr(resourceName, "quota_value", "1073741824"),
+					resource.TestCheckResourceAttr(resourceName, "xid", "568"),
+					resource.TestCheckResourceAttr(resourceName, "used_bytes", "0"),
+					resource.TestCheckResourceAttr("truenas_dataset_user_quota.objects", "id", "Tank/home:USEROBJ:568"),
+					resource.TestCheckResourceAttr("truenas_dataset_user_quota.objects", "quota_value", "10000"),
+					resource.TestCheckResourceAttr("truenas_dataset_user_quota.objects", "xid", "568"),
+					resource.TestCheckResourceAttr("truenas_dataset_user_quota.group", "xid", "65534"),
+					resource.TestCheckResourceAttr("truenas_dataset_user_quota.group", "quota_value", "5368709120"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasDatasetUserQuotaConfig(fakePoolName, "home", "USER", "apps", 2147483648),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "quota_value", "2147483648"),
+					testAccCheckFakeDatasetQuotas(f, "Tank/home", map[string]int64{"USER:568": 2147483648}),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				ResourceName:  resourceName,
+				ImportState:   true,
+				ImportStateId: "Tank/home:apps",
+				ExpectError:   regexp.MustCompile("expected format: pool/dataset:TYPE:name"),
+			},
+			{
+				Config:      f.providerConfig() + testAccCheckResourceTruenasDatasetUserQuotaConfig(fakePoolName, "home", "USER", "unknown", 1073741824),
+				ExpectError: regexp.MustCompile(`unknown: user does not exist`),
+			},
+		},
+	})
+}
+
+func Test_parseUserQuotaID(t *testing.T) {
+	dataset, quotaType, name, err := parseUserQuotaID("Tank/home:GROUPOBJ:staff")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Tank/home", dataset)
+	assert.Equal(t, quotaTypeGroupObj, quotaType)
+	assert.Equal(t, "staff", name)
+
+	for _, id := range []string{"Tank/home", "Tank/home:USER", ":USER:alice", "Tank/home:USER:", "Tank/home:DATASET:alice"} {
+		_, _, _, err := parseUserQuotaID(id)
+		assert.Error(t, err, id)
+	}
+}
+
+func testAccCheckResourceTruenasDatasetUserQuotaConfig(pool string, datasetName string, quotaType string, name string, value int64) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "test" {
+		name = "%s"
+		pool = "%s"
+	}
+
+	resource "truenas_dataset_user_quota" "test" {
+		dataset_id = truenas_dataset.test.id
+		quota_type = "%s"
+		name = "%s"
+		quota_value = %d
+	}
+	`, datasetName, pool, quotaType, name, value)
+}
+
+func testAccCheckFakeDatasetQuotas(f *fakeTrueNAS, id string, expected map[string]int64) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		ds := f.datasets[id]
+
+		if ds == nil {
+			return fmt.Errorf("dataset %s not found", id)
+		}
+
+		if !assert.ObjectsAreEqual(expected, ds.Quotas) {
+			return fmt.Errorf("expected dataset %s quotas %v, got %v", id, expected, ds.Quotas)
+		}
+
+		return nil
+	}
+}
+
+func testAccCheckResourceTruenasDatasetUserQuotaDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*Client)
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "truenas_dataset_user_quota" {
+			continue
+		}
+
+		dataset, quotaType, name, err := parseUserQuotaID(rs.Primary.ID)
+
+		if err != nil {
+			return err
+		}
+
+		quota, err := getDatasetQuota(context.Background(), client, dataset, quotaType, name)
+
+		if err != nil {
+			if isNotFoundError(nil, err) {
+				continue
+			}
+
+			return fmt.Errorf("Error occured while checking for absence of dataset quota (%s): %s", rs.Primary.ID, err)
+		}
+
+		if quota != nil && quota.limit(quotaType) != 0 {
+			return fmt.Errorf("dataset quota (%s) still exists", rs.Primary.ID)
+		}
+	}
+
+	return testAccCheckResourceTruenasDatasetDestroy(s)
+}
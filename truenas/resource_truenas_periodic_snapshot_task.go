@@ -0,0 +1,339 @@
+package truenas
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"log"
+	"regexp"
+	"strconv"
+)
+
+var periodicSnapshotTaskAPIAttrs = apiAttrMap{
+	"dataset":        "dataset",
+	"recursive":      "recursive",
+	"exclude":        "exclude",
+	"lifetime_value": "lifetime_value",
+	"lifetime_unit":  "lifetime_unit",
+	"naming_schema":  "naming_schema",
+	"schedule":       "schedule",
+	"allow_empty":    "allow_empty",
+	"enabled":        "enabled",
+}
+
+var snapshotLifetimeUnits = []string{"HOUR", "DAY", "WEEK", "MONTH", "YEAR"}
+
+var taskScheduleTimePattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// taskSchedule is cron-like schedule of periodic tasks, begin and end limit
+// time window during the day when task is allowed to run
+type taskSchedule struct {
+	Minute string `json:"minute"`
+	Hour   string `json:"hour"`
+	Dom    string `json:"dom"`
+	Month  string `json:"month"`
+	Dow    string `json:"dow"`
+	Begin  string `json:"begin,omitempty"`
+	End    string `json:"end,omitempty"`
+}
+
+// periodicSnapshotTask is pool.snapshottask.query result and create/update params
+type periodicSnapshotTask struct {
+	ID            int           `json:"id,omitempty"`
+	Dataset       string        `json:"dataset"`
+	Recursive     bool          `json:"recursive"`
+	Exclude       []string      `json:"exclude"`
+	LifetimeValue int           `json:"lifetime_value"`
+	LifetimeUnit  string        `json:"lifetime_unit"`
+	NamingSchema  string        `json:"naming_schema"`
+	Schedule      *taskSchedule `json:"schedule,omitempty"`
+	AllowEmpty    bool          `json:"allow_empty"`
+	Enabled       bool          `json:"enabled"`
+}
+
+// taskScheduleSchema returns schedule block schema shared by periodic tasks, it follows truenas_cronjob schedule
+func taskScheduleSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "Task schedule",
+		Type:        schema.TypeList,
+		Required:    true,
+		MaxItems:    1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"minute": &schema.Schema{
+					Type:     schema.TypeString,
+					Default:  "00",
+					Optional: true,
+				},
+				"hour": &schema.Schema{
+					Type:     schema.TypeString,
+					Default:  "*",
+					Optional: true,
+				},
+				"dom": &schema.Schema{
+					Type:     schema.TypeString,
+					Default:  "*",
+					Optional: true,
+				},
+				"month": &schema.Schema{
+					Type:     schema.TypeString,
+					Default:  "*",
+					Optional: true,
+				},
+				"dow": &schema.Schema{
+					Type:     schema.TypeString,
+					Default:  "*",
+					Optional: true,
+				},
+				"begin": &schema.Schema{
+					Description:  "Start of time window when task is allowed to run, in HH:MM format",
+					Type:         schema.TypeString,
+					Default:      "00:00",
+					Optional:     true,
+					ValidateFunc: validation.StringMatch(taskScheduleTimePattern, "time must be in HH:MM format"),
+				},
+				"end": &schema.Schema{
+					Description:  "End of time window when task is allowed to run, in HH:MM format",
+					Type:         schema.TypeString,
+					Default:      "23:59",
+					Optional:     true,
+					ValidateFunc: validation.StringMatch(taskScheduleTimePattern, "time must be in HH:MM format"),
+				},
+			},
+		},
+	}
+}
+
+func resourceTrueNASPeriodicSnapshotTask() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Periodic snapshot task takes scheduled snapshots of a dataset and removes them after configured lifetime",
+		CreateContext: resourceTrueNASPeriodicSnapshotTaskCreate,
+		ReadContext:   resourceTrueNASPeriodicSnapshotTaskRead,
+		UpdateContext: resourceTrueNASPeriodicSnapshotTaskUpdate,
+		DeleteContext: resourceTrueNASPeriodicSnapshotTaskDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Schema: map[string]*schema.Schema{
+			"task_id": &schema.Schema{
+				Description: "Periodic snapshot task ID",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"dataset": &schema.Schema{
+				Description: "Dataset or zvol to snapshot, eg. Tank/data",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"recursive": &schema.Schema{
+				Description: "Snapshot child datasets as well",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"exclude": &schema.Schema{
+				Description: "Child datasets to exclude from recursive snapshots",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"lifetime_value": &schema.Schema{
+				Description:  "How long snapshots are kept, in `lifetime_unit` units",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      2,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"lifetime_unit": &schema.Schema{
+				Description:  "Snapshot lifetime unit: HOUR, DAY, WEEK, MONTH or YEAR",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "WEEK",
+				ValidateFunc: validation.StringInSlice(snapshotLifetimeUnits, false),
+			},
+			"naming_schema": &schema.Schema{
+				Description: "Snapshot name format, strftime(3) sequences are replaced with snapshot time, eg. auto-%Y-%m-%d_%H-%M",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "auto-%Y-%m-%d_%H-%M",
+			},
+			"allow_empty": &schema.Schema{
+				Description: "Take snapshots even if dataset has not changed since the last one",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"enabled": &schema.Schema{
+				Description: "`true` if task is enabled",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"schedule": taskScheduleSchema(),
+		},
+	}
+}
+
+func resourceTrueNASPeriodicSnapshotTaskCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	input := expandPeriodicSnapshotTask(d)
+
+	log.Printf("[DEBUG] Creating TrueNAS periodic snapshot task: %+v", input)
+
+	var resp periodicSnapshotTask
+
+	if err := c.invoke(ctx, "pool.snapshottask.create", []interface{}{input}, &resp); err != nil {
+		return apiErrorDiags(err, "error creating periodic snapshot task", periodicSnapshotTaskAPIAttrs)
+	}
+
+	d.SetId(strconv.Itoa(resp.ID))
+
+	log.Printf("[INFO] TrueNAS periodic snapshot task (%d) created", resp.ID)
+
+	return resourceTrueNASPeriodicSnapshotTaskRead(ctx, d, m)
+}
+
+func resourceTrueNASPeriodicSnapshotTaskRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	id, err := strconv.Atoi(d.Id())
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	var resp periodicSnapshotTask
+
+	if err := c.invoke(ctx, "pool.snapshottask.get_instance", []interface{}{id}, &resp); err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS periodic snapshot task (%d) not found, removing from state", id)
+			d.SetId("")
+			return nil
+		}
+		return apiErrorDiags(err, "error getting periodic snapshot task", nil)
+	}
+
+	if err := flattenPeriodicSnapshotTask(d, &resp); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceTrueNASPeriodicSnapshotTaskUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	id, err := strconv.Atoi(d.Id())
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	input := expandPeriodicSnapshotTask(d)
+
+	log.Printf("[DEBUG] Updating TrueNAS periodic snapshot task: %+v", input)
+
+	if err := c.invoke(ctx, "pool.snapshottask.update", []interface{}{id, input}, nil); err != nil {
+		return apiErrorDiags(err, "error updating periodic snapshot task", periodicSnapshotTaskAPIAttrs)
+	}
+
+	log.Printf("[INFO] TrueNAS periodic snapshot task (%d) updated", id)
+
+	return resourceTrueNASPeriodicSnapshotTaskRead(ctx, d, m)
+}
+
+func resourceTrueNASPeriodicSnapshotTaskDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	id, err := strconv.Atoi(d.Id())
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[DEBUG] Deleting TrueNAS periodic snapshot task: %d", id)
+
+	if err := c.invoke(ctx, "pool.snapshottask.delete", []interface{}{id}, nil); err != nil {
+		return apiErrorDiags(err, "error deleting periodic snapshot task", nil)
+	}
+
+	log.Printf("[INFO] TrueNAS periodic snapshot task (%d) deleted", id)
+	d.SetId("")
+
+	return nil
+}
+
+func expandPeriodicSnapshotTask(d *schema.ResourceData) periodicSnapshotTask {
+	return periodicSnapshotTask{
+		Dataset:       d.Get("dataset").(string),
+		Recursive:     d.Get("recursive").(bool),
+		Exclude:       expandStrings(d.Get("exclude").(*schema.Set).List()),
+		LifetimeValue: d.Get("lifetime_value").(int),
+		LifetimeUnit:  d.Get("lifetime_unit").(string),
+		NamingSchema:  d.Get("naming_schema").(string),
+		Schedule:      expandTaskSchedule(d.Get("schedule").([]interface{})),
+		AllowEmpty:    d.Get("allow_empty").(bool),
+		Enabled:       d.Get("enabled").(bool),
+	}
+}
+
+func flattenPeriodicSnapshotTask(d *schema.ResourceData, task *periodicSnapshotTask) error {
+	d.Set("task_id", task.ID)
+	d.Set("dataset", task.Dataset)
+	d.Set("recursive", task.Recursive)
+
+	if err := d.Set("exclude", flattenStringList(task.Exclude)); err != nil {
+		return err
+	}
+
+	d.Set("lifetime_value", task.LifetimeValue)
+	d.Set("lifetime_unit", task.LifetimeUnit)
+	d.Set("naming_schema", task.NamingSchema)
+	d.Set("allow_empty", task.AllowEmpty)
+	d.Set("enabled", task.Enabled)
+
+	if task.Schedule != nil {
+		if err := d.Set("schedule", flattenTaskSchedule(*task.Schedule)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func expandTaskSchedule(s []interface{}) *taskSchedule {
+	if len(s) == 0 || s[0] == nil {
+		return nil
+	}
+
+	mSchedule := s[0].(map[string]interface{})
+
+	return &taskSchedule{
+		Minute: mSchedule["minute"].(string),
+		Hour:   mSchedule["hour"].(string),
+		Dom:    mSchedule["dom"].(string),
+		Month:  mSchedule["month"].(string),
+		Dow:    mSchedule["dow"].(string),
+		Begin:  mSchedule["begin"].(string),
+		End:    mSchedule["end"].(string),
+	}
+}
+
+func flattenTaskSchedule(s taskSchedule) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"minute": s.Minute,
+			"hour":   s.Hour,
+			"dom":    s.Dom,
+			"month":  s.Month,
+			"dow":    s.Dow,
+			"begin":  s.Begin,
+			"end":    s.End,
+		},
+	}
+}
@@ -0,0 +1,134 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"strconv"
+	"testing"
+)
+
+func TestAccResourceTruenasPeriodicSnapshotTask_basic(t *testing.T) {
+	suffix := acctest.RandStringFromCharSet(5, acctest.CharSetAlphaNum)
+	datasetName := fmt.Sprintf("%s-%s", testResourcePrefix, suffix)
+	resourceName := "truenas_periodic_snapshot_task.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckResourceTruenasPeriodicSnapshotTaskDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckResourceTruenasPeriodicSnapshotTaskConfig(testPoolName, datasetName, 2),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "dataset", fmt.Sprintf("%s/%s", testPoolName, datasetName)),
+					resource.TestCheckResourceAttr(resourceName, "recursive", "true"),
+					resource.TestCheckResourceAttr(resourceName, "lifetime_value", "2"),
+					resource.TestCheckResourceAttr(resourceName, "lifetime_unit", "DAY"),
+					resource.TestCheckResourceAttr(resourceName, "naming_schema", "tf-%Y%m%d-%H%M"),
+					resource.TestCheckResourceAttr(resourceName, "schedule.0.minute", "15"),
+					resource.TestCheckResourceAttr(resourceName, "schedule.0.begin", "08:00"),
+					resource.TestCheckResourceAttr(resourceName, "schedule.0.end", "18:00"),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasPeriodicSnapshotTask_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_periodic_snapshot_task.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_periodic_snapshot_task", "pool/snapshottask"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasPeriodicSnapshotTaskConfig(fakePoolName, "data", 2),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "dataset", "Tank/data"),
+					resource.TestCheckResourceAttr(resourceName, "recursive", "true"),
+					resource.TestCheckTypeSetElemAttr(resourceName, "exclude.*", "Tank/data/scratch"),
+					resource.TestCheckResourceAttr(resourceName, "lifetime_value", "2"),
+					resource.TestCheckResourceAttr(resourceName, "allow_empty", "false"),
+					resource.TestCheckResourceAttr(resourceName, "enabled", "true"),
+					resource.TestCheckResourceAttr(resourceName, "schedule.0.hour", "*"),
+					resource.TestCheckResourceAttr(resourceName, "schedule.0.begin", "08:00"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasPeriodicSnapshotTaskConfig(fakePoolName, "data", 5),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "lifetime_value", "5"),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func testAccCheckResourceTruenasPeriodicSnapshotTaskConfig(pool string, datasetName string, lifetime int) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "test" {
+		name = "%s"
+		pool = "%s"
+	}
+
+	resource "truenas_periodic_snapshot_task" "test" {
+		dataset = truenas_dataset.test.id
+		recursive = true
+		exclude = ["${truenas_dataset.test.id}/scratch"]
+		lifetime_value = %d
+		lifetime_unit = "DAY"
+		naming_schema = "tf-%%Y%%m%%d-%%H%%M"
+		allow_empty = false
+
+		schedule {
+			minute = "15"
+			begin = "08:00"
+			end = "18:00"
+		}
+	}
+	`, datasetName, pool, lifetime)
+}
+
+func testAccCheckResourceTruenasPeriodicSnapshotTaskDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*Client)
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "truenas_periodic_snapshot_task" {
+			continue
+		}
+
+		id, err := strconv.Atoi(rs.Primary.ID)
+
+		if err != nil {
+			return fmt.Errorf("could not convert ID of periodic snapshot task: %s", rs.Primary.ID)
+		}
+
+		err = client.invoke(context.Background(), "pool.snapshottask.get_instance", []interface{}{id}, nil)
+
+		if err == nil {
+			return fmt.Errorf("periodic snapshot task (%s) still exists", rs.Primary.ID)
+		}
+
+		if !isNotFoundError(nil, err) {
+			return fmt.Errorf("Error occured while checking for absence of periodic snapshot task (%s): %s", rs.Primary.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,744 @@
+package truenas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	api "github.com/dariusbakunas/truenas-go-sdk"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	vdevTypeStripe = "STRIPE"
+	// vdevTypeDisk is reported by middleware for single disk vdevs, eg. disks of a stripe
+	vdevTypeDisk = "DISK"
+)
+
+const (
+	poolDeleteActionDestroy = "DESTROY"
+	poolDeleteActionExport  = "EXPORT"
+)
+
+// poolVdevClasses are pool topology vdev classes, spares are handled separately as a plain disk list
+var poolVdevClasses = []string{"data", "log", "cache", "special", "dedup"}
+
+var vdevTypes = []string{vdevTypeStripe, "MIRROR", "RAIDZ1", "RAIDZ2", "RAIDZ3"}
+var checksumAlgorithms = []string{"on", "off", "fletcher2", "fletcher4", "sha256", "sha512", "skein", "edonr", "blake3"}
+
+var poolAPIAttrs = apiAttrMap{
+	"name":                           "name",
+	"encryption":                     "encrypted",
+	"encryption_options.algorithm":   "encryption_algorithm",
+	"encryption_options.passphrase":  "passphrase",
+	"encryption_options.key":         "encryption_key",
+	"encryption_options.pbkdf2iters": "pbkdf2iters",
+	"deduplication":                  "deduplication",
+	"checksum":                       "checksum",
+	"autotrim":                       "autotrim",
+	"topology":                       "topology",
+}
+
+// poolVdev is a node of pool topology as reported by pool.query, top level vdevs
+// have disks as children, unless vdev is a single disk
+type poolVdev struct {
+	Name     string     `json:"name"`
+	Type     string     `json:"type"`
+	Disk     *string    `json:"disk"`
+	Device   *string    `json:"device"`
+	Status   string     `json:"status"`
+	Children []poolVdev `json:"children"`
+}
+
+// poolTopology is pool topology as reported by pool.query
+type poolTopology struct {
+	Data    []poolVdev `json:"data"`
+	Log     []poolVdev `json:"log"`
+	Cache   []poolVdev `json:"cache"`
+	Spare   []poolVdev `json:"spare"`
+	Special []poolVdev `json:"special"`
+	Dedup   []poolVdev `json:"dedup"`
+}
+
+// apiDate is a timestamp as encoded by middleware, eg. {"$date": 1670000000000}
+type apiDate struct {
+	Date int64 `json:"$date"`
+}
+
+// poolScan is state of the last pool scrub or resilver
+type poolScan struct {
+	Function   *string  `json:"function"`
+	State      *string  `json:"state"`
+	StartTime  *apiDate `json:"start_time"`
+	EndTime    *apiDate `json:"end_time"`
+	Percentage *float64 `json:"percentage"`
+	Errors     *int64   `json:"errors"`
+}
+
+// storagePool is pool.query result
+type storagePool struct {
+	ID            int                `json:"id"`
+	Name          string             `json:"name"`
+	GUID          string             `json:"guid"`
+	Path          string             `json:"path"`
+	Status        string             `json:"status"`
+	Healthy       bool               `json:"healthy"`
+	IsDecrypted   bool               `json:"is_decrypted"`
+	Encrypt       int                `json:"encrypt"`
+	Autotrim      api.CompositeValue `json:"autotrim"`
+	Topology      *poolTopology      `json:"topology"`
+	Scan          *poolScan          `json:"scan"`
+	Size          *int64             `json:"size"`
+	Allocated     *int64             `json:"allocated"`
+	Free          *int64             `json:"free"`
+	Fragmentation json.Number        `json:"fragmentation"`
+}
+
+// poolVdevParams is a vdev of pool.create and pool.update topology
+type poolVdevParams struct {
+	Type  string   `json:"type"`
+	Disks []string `json:"disks"`
+}
+
+// poolTopologyParams is pool.create and pool.update topology, on update vdevs are added to the pool
+type poolTopologyParams struct {
+	Data    []poolVdevParams `json:"data,omitempty"`
+	Log     []poolVdevParams `json:"log,omitempty"`
+	Cache   []poolVdevParams `json:"cache,omitempty"`
+	Spares  []string         `json:"spares,omitempty"`
+	Special []poolVdevParams `json:"special,omitempty"`
+	Dedup   []poolVdevParams `json:"dedup,omitempty"`
+}
+
+func (t *poolTopologyParams) isEmpty() bool {
+	return len(t.Data) == 0 && len(t.Log) == 0 && len(t.Cache) == 0 && len(t.Spares) == 0 &&
+		len(t.Special) == 0 && len(t.Dedup) == 0
+}
+
+// class returns pointer to vdev list of a topology class, eg. data or log
+func (t *poolTopologyParams) class(name string) *[]poolVdevParams {
+	switch name {
+	case "data":
+		return &t.Data
+	case "log":
+		return &t.Log
+	case "cache":
+		return &t.Cache
+	case "special":
+		return &t.Special
+	case "dedup":
+		return &t.Dedup
+	}
+
+	return nil
+}
+
+type poolEncryptionOptions struct {
+	GenerateKey bool    `json:"generate_key"`
+	Algorithm   string  `json:"algorithm,omitempty"`
+	Pbkdf2iters *int    `json:"pbkdf2iters,omitempty"`
+	Passphrase  *string `json:"passphrase,omitempty"`
+	Key         *string `json:"key,omitempty"`
+}
+
+// poolCreateParams are pool.create params
+type poolCreateParams struct {
+	Name              string                 `json:"name"`
+	Encryption        bool                   `json:"encryption"`
+	EncryptionOptions *poolEncryptionOptions `json:"encryption_options,omitempty"`
+	Deduplication     *string                `json:"deduplication,omitempty"`
+	Checksum          *string                `json:"checksum,omitempty"`
+	Topology          poolTopologyParams     `json:"topology"`
+}
+
+// poolUpdateParams are pool.update params
+type poolUpdateParams struct {
+	Topology *poolTopologyParams `json:"topology,omitempty"`
+	Autotrim *string             `json:"autotrim,omitempty"`
+}
+
+// poolRootDataset holds properties of pool root dataset that are managed by truenas_pool
+type poolRootDataset struct {
+	Checksum      *api.CompositeValue `json:"checksum"`
+	Deduplication *api.CompositeValue `json:"deduplication"`
+}
+
+func poolVdevSchema(description string, types []string) *schema.Schema {
+	return &schema.Schema{
+		Description: description,
+		Type:        schema.TypeList,
+		Optional:    true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"type": &schema.Schema{
+					Description:  fmt.Sprintf("Vdev type: %s. Only one STRIPE vdev per class is allowed, it holds all single disks of the class", strings.Join(types, ", ")),
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validation.StringInSlice(types, false),
+				},
+				"disks": &schema.Schema{
+					Description: "Disk names, eg. sda",
+					Type:        schema.TypeList,
+					Required:    true,
+					MinItems:    1,
+					Elem: &schema.Schema{
+						Type: schema.TypeString,
+					},
+				},
+			},
+		},
+	}
+}
+
+func resourceTrueNASPool() *schema.Resource {
+	data := poolVdevSchema("Data vdevs", vdevTypes)
+	data.Optional = false
+	data.Required = true
+	data.MinItems = 1
+
+	return &schema.Resource{
+		Description: "ZFS storage pool. New vdevs can be added to existing pool, but existing vdevs can not be changed. " +
+			"Disks of STRIPE vdevs are reported as a single STRIPE vdev of each class, so the pool is grown by adding disks to it",
+		CreateContext: resourceTrueNASPoolCreate,
+		ReadContext:   resourceTrueNASPoolRead,
+		UpdateContext: resourceTrueNASPoolUpdate,
+		DeleteContext: resourceTrueNASPoolDelete,
+		CustomizeDiff: resourceTrueNASPoolCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceTrueNASPoolImport,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"pool_id": &schema.Schema{
+				Description: "Pool ID",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"name": &schema.Schema{
+				Description: "Pool name",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"topology": &schema.Schema{
+				Description: "Pool vdev topology",
+				Type:        schema.TypeList,
+				Required:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"data":    data,
+						"log":     poolVdevSchema("SLOG vdevs", []string{vdevTypeStripe, "MIRROR"}),
+						"cache":   poolVdevSchema("L2ARC vdevs", []string{vdevTypeStripe}),
+						"special": poolVdevSchema("Special allocation class vdevs for metadata and small blocks", vdevTypes),
+						"dedup":   poolVdevSchema("Deduplication table vdevs", vdevTypes),
+						"spares": &schema.Schema{
+							Description: "Hot spare disk names",
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+			"encrypted": &schema.Schema{
+				Description: "Encrypt pool root dataset",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Computed:    true,
+			},
+			"encryption_algorithm": &schema.Schema{
+				Description:  "Encryption algorithm, eg. AES-256-GCM",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(encryptionAlgorithms, false),
+			},
+			"pbkdf2iters": &schema.Schema{
+				Description: "Number of PBKDF2 iterations for passphrase encryption",
+				Type:        schema.TypeInt,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"passphrase": &schema.Schema{
+				Description:   "Encryption passphrase",
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				Sensitive:     true,
+				ConflictsWith: []string{"encryption_key"},
+			},
+			"encryption_key": &schema.Schema{
+				Description:   "Hex encoded encryption key, key is generated if neither key nor passphrase are set",
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				Sensitive:     true,
+				ConflictsWith: []string{"passphrase"},
+				ValidateFunc:  validation.StringMatch(regexp.MustCompile("^[a-fA-F0-9]+$"), "key must be in hexadecimal format"),
+			},
+			"deduplication": &schema.Schema{
+				Description:  "Deduplication of pool root dataset, inherited by child datasets: on, off or verify",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice([]string{"on", "off", "verify"}, false),
+			},
+			"checksum": &schema.Schema{
+				Description:  "Checksum algorithm of pool root dataset, inherited by child datasets",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(checksumAlgorithms, false),
+			},
+			"autotrim": &schema.Schema{
+				Description:  "Automatic TRIM of pool disks: on or off",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice([]string{"on", "off"}, false),
+			},
+			"delete_action": &schema.Schema{
+				Description:  "What happens to the pool when resource is deleted: DESTROY (requires `allow_destroy`) or EXPORT, that disconnects the pool and keeps its data on disks",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      poolDeleteActionDestroy,
+				ValidateFunc: validation.StringInSlice([]string{poolDeleteActionDestroy, poolDeleteActionExport}, false),
+			},
+			"allow_destroy": &schema.Schema{
+				Description: "Must be set to `true` before pool with DESTROY `delete_action` can be destroyed, protects pool data from accidental deletion",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"path": &schema.Schema{
+				Description: "Pool mount path",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"guid": &schema.Schema{
+				Description: "Pool GUID",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"status": &schema.Schema{
+				Description: "Pool status, eg. ONLINE or DEGRADED",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"healthy": &schema.Schema{
+				Description: "`true` if pool is healthy",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// resourceTrueNASPoolCustomizeDiff makes sure topology changes only add vdevs, so that plan fails
+// instead of pool being replaced or update failing half-way
+func resourceTrueNASPoolCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if err := validatePoolStripes(expandPoolTopology(d.Get("topology").([]interface{}))); err != nil {
+		return err
+	}
+
+	if d.Id() == "" || !d.HasChange("topology") {
+		return nil
+	}
+
+	o, n := d.GetChange("topology")
+
+	_, err := poolTopologyAdditions(expandPoolTopology(o.([]interface{})), expandPoolTopology(n.([]interface{})))
+	return err
+}
+
+func resourceTrueNASPoolCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	input := poolCreateParams{
+		Name:       d.Get("name").(string),
+		Encryption: d.Get("encrypted").(bool),
+		Topology:   expandPoolTopology(d.Get("topology").([]interface{})),
+	}
+
+	if input.Encryption {
+		options := &poolEncryptionOptions{}
+
+		if algorithm, ok := d.GetOk("encryption_algorithm"); ok {
+			options.Algorithm = algorithm.(string)
+		}
+
+		if iters, ok := d.GetOk("pbkdf2iters"); ok {
+			i := iters.(int)
+			options.Pbkdf2iters = &i
+		}
+
+		if passphrase, ok := d.GetOk("passphrase"); ok {
+			options.Passphrase = getStringPtr(passphrase.(string))
+		}
+
+		if key, ok := d.GetOk("encryption_key"); ok {
+			options.Key = getStringPtr(key.(string))
+		}
+
+		options.GenerateKey = options.Passphrase == nil && options.Key == nil
+		input.EncryptionOptions = options
+	}
+
+	if deduplication, ok := d.GetOk("deduplication"); ok {
+		input.Deduplication = getStringPtr(strings.ToUpper(deduplication.(string)))
+	}
+
+	if checksum, ok := d.GetOk("checksum"); ok {
+		input.Checksum = getStringPtr(strings.ToUpper(checksum.(string)))
+	}
+
+	log.Printf("[DEBUG] Creating TrueNAS pool: %s", input.Name)
+
+	var resp storagePool
+
+	if err := c.invokeJob(ctx, "pool.create", []interface{}{input}, &resp); err != nil {
+		return apiErrorDiags(err, "error creating pool", poolAPIAttrs)
+	}
+
+	d.SetId(strconv.Itoa(resp.ID))
+
+	log.Printf("[INFO] TrueNAS pool (%d) created", resp.ID)
+
+	if autotrim, ok := d.GetOk("autotrim"); ok {
+		update := poolUpdateParams{Autotrim: getStringPtr(strings.ToUpper(autotrim.(string)))}
+
+		if err := c.invokeJob(ctx, "pool.update", []interface{}{resp.ID, update}, nil); err != nil {
+			return apiErrorDiags(err, "error updating pool", poolAPIAttrs)
+		}
+	}
+
+	return resourceTrueNASPoolRead(ctx, d, m)
+}
+
+func resourceTrueNASPoolRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	id, err := strconv.Atoi(d.Id())
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	var resp storagePool
+
+	if err := c.invoke(ctx, "pool.get_instance", []interface{}{id}, &resp); err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS pool (%d) not found, removing from state", id)
+			d.SetId("")
+			return nil
+		}
+		return apiErrorDiags(err, "error getting pool", nil)
+	}
+
+	d.Set("pool_id", resp.ID)
+	d.Set("name", resp.Name)
+	d.Set("path", resp.Path)
+	d.Set("guid", resp.GUID)
+	d.Set("status", resp.Status)
+	d.Set("healthy", resp.Healthy)
+	d.Set("encrypted", resp.Encrypt > 0)
+
+	if resp.Autotrim.Value != nil {
+		d.Set("autotrim", strings.ToLower(*resp.Autotrim.Value))
+	}
+
+	if resp.Topology != nil {
+		if err := d.Set("topology", flattenPoolTopology(resp.Topology)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	var root poolRootDataset
+
+	if err := c.invoke(ctx, "pool.dataset.get_instance", []interface{}{resp.Name}, &root); err != nil {
+		return apiErrorDiags(err, "error getting pool root dataset", nil)
+	}
+
+	if root.Checksum != nil && root.Checksum.Value != nil {
+		d.Set("checksum", strings.ToLower(*root.Checksum.Value))
+	}
+
+	if root.Deduplication != nil && root.Deduplication.Value != nil {
+		d.Set("deduplication", strings.ToLower(*root.Deduplication.Value))
+	}
+
+	return nil
+}
+
+func resourceTrueNASPoolUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	id, err := strconv.Atoi(d.Id())
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if d.HasChanges("topology", "autotrim") {
+		input := poolUpdateParams{}
+
+		if d.HasChange("topology") {
+			o, n := d.GetChange("topology")
+			additions, err := poolTopologyAdditions(expandPoolTopology(o.([]interface{})), expandPoolTopology(n.([]interface{})))
+
+			if err != nil {
+				return diag.FromErr(err)
+			}
+
+			if !additions.isEmpty() {
+				input.Topology = &additions
+			}
+		}
+
+		if d.HasChange("autotrim") {
+			input.Autotrim = getStringPtr(strings.ToUpper(d.Get("autotrim").(string)))
+		}
+
+		log.Printf("[DEBUG] Updating TrueNAS pool (%d): %+v", id, input)
+
+		if err := c.invokeJob(ctx, "pool.update", []interface{}{id, input}, nil); err != nil {
+			return apiErrorDiags(err, "error updating pool", poolAPIAttrs)
+		}
+
+		log.Printf("[INFO] TrueNAS pool (%d) updated", id)
+	}
+
+	if d.HasChanges("checksum", "deduplication") {
+		name := d.Get("name").(string)
+		input := map[string]interface{}{}
+
+		if checksum, ok := d.GetOk("checksum"); ok {
+			input["checksum"] = strings.ToUpper(checksum.(string))
+		}
+
+		if deduplication, ok := d.GetOk("deduplication"); ok {
+			input["deduplication"] = strings.ToUpper(deduplication.(string))
+		}
+
+		log.Printf("[DEBUG] Updating TrueNAS pool root dataset (%s): %+v", name, input)
+
+		if err := c.invoke(ctx, "pool.dataset.update", []interface{}{name, input}, nil); err != nil {
+			return apiErrorDiags(err, "error updating pool root dataset", poolAPIAttrs)
+		}
+	}
+
+	return resourceTrueNASPoolRead(ctx, d, m)
+}
+
+func resourceTrueNASPoolDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	id, err := strconv.Atoi(d.Id())
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	destroy := d.Get("delete_action").(string) == poolDeleteActionDestroy
+
+	if destroy && !d.Get("allow_destroy").(bool) {
+		return diag.Errorf("pool %s is protected from destruction: set allow_destroy = true to destroy it, or delete_action = %q to export it", d.Get("name"), poolDeleteActionExport)
+	}
+
+	options := map[string]interface{}{
+		"destroy": destroy,
+	}
+
+	log.Printf("[DEBUG] Deleting TrueNAS pool (%d), destroy: %t", id, destroy)
+
+	if err := c.invokeJob(ctx, "pool.export", []interface{}{id, options}, nil); err != nil {
+		return apiErrorDiags(err, "error deleting pool", nil)
+	}
+
+	log.Printf("[INFO] TrueNAS pool (%d) deleted", id)
+	d.SetId("")
+
+	return nil
+}
+
+// resourceTrueNASPoolImport sets delete options to defaults, imported pool is protected from destruction
+func resourceTrueNASPoolImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	d.Set("delete_action", poolDeleteActionDestroy)
+	d.Set("allow_destroy", false)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// poolTopologyAdditions returns vdevs that have to be added to the pool to get from old to new topology.
+// Existing vdevs must not change, except STRIPE vdevs that can get more disks
+func poolTopologyAdditions(old poolTopologyParams, updated poolTopologyParams) (poolTopologyParams, error) {
+	additions := poolTopologyParams{}
+
+	for _, class := range poolVdevClasses {
+		o, n := *old.class(class), *updated.class(class)
+
+		if len(n) < len(o) {
+			return additions, fmt.Errorf("%s vdevs can not be removed from pool", class)
+		}
+
+		for i, vdev := range o {
+			if vdev.Type != n[i].Type {
+				return additions, fmt.Errorf("type of %s vdev %d can not be changed from %s to %s", class, i, vdev.Type, n[i].Type)
+			}
+
+			if !isStringListPrefix(vdev.Disks, n[i].Disks) || (vdev.Type != vdevTypeStripe && len(vdev.Disks) != len(n[i].Disks)) {
+				return additions, fmt.Errorf("disks of %s vdev %d can not be changed, only new vdevs can be added to pool", class, i)
+			}
+
+			if extra := n[i].Disks[len(vdev.Disks):]; len(extra) > 0 {
+				*additions.class(class) = append(*additions.class(class), poolVdevParams{Type: vdevTypeStripe, Disks: extra})
+			}
+		}
+
+		*additions.class(class) = append(*additions.class(class), n[len(o):]...)
+	}
+
+	if !isStringListPrefix(old.Spares, updated.Spares) {
+		return additions, errors.New("spares can not be removed from pool, only new spares can be added")
+	}
+
+	additions.Spares = updated.Spares[len(old.Spares):]
+
+	return additions, nil
+}
+
+// validatePoolStripes rejects more than one STRIPE vdev per class, single disk vdevs are read back
+// as one STRIPE vdev, so separate STRIPE blocks would never match pool topology
+func validatePoolStripes(t poolTopologyParams) error {
+	for _, class := range poolVdevClasses {
+		stripes := 0
+
+		for _, vdev := range *t.class(class) {
+			if vdev.Type == vdevTypeStripe {
+				stripes++
+			}
+		}
+
+		if stripes > 1 {
+			return fmt.Errorf("only one STRIPE %s vdev is allowed, list all single disks of the class in it", class)
+		}
+	}
+
+	return nil
+}
+
+func isStringListPrefix(prefix []string, list []string) bool {
+	if len(prefix) > len(list) {
+		return false
+	}
+
+	for i, s := range prefix {
+		if list[i] != s {
+			return false
+		}
+	}
+
+	return true
+}
+
+func expandPoolTopology(t []interface{}) poolTopologyParams {
+	topology := poolTopologyParams{}
+
+	if len(t) == 0 || t[0] == nil {
+		return topology
+	}
+
+	mTopology := t[0].(map[string]interface{})
+
+	for _, class := range poolVdevClasses {
+		vdevs := topology.class(class)
+
+		for _, v := range mTopology[class].([]interface{}) {
+			mVdev := v.(map[string]interface{})
+
+			*vdevs = append(*vdevs, poolVdevParams{
+				Type:  mVdev["type"].(string),
+				Disks: expandStrings(mVdev["disks"].([]interface{})),
+			})
+		}
+	}
+
+	topology.Spares = expandStrings(mTopology["spares"].([]interface{}))
+
+	return topology
+}
+
+// flattenPoolTopology converts pool topology into schema, single disk vdevs
+// of each class are merged into one STRIPE vdev
+func flattenPoolTopology(t *poolTopology) []interface{} {
+	classes := map[string][]poolVdev{
+		"data":    t.Data,
+		"log":     t.Log,
+		"cache":   t.Cache,
+		"special": t.Special,
+		"dedup":   t.Dedup,
+	}
+
+	mTopology := make(map[string]interface{})
+
+	for class, vdevs := range classes {
+		result := make([]interface{}, 0, len(vdevs))
+		var stripe map[string]interface{}
+
+		for _, vdev := range vdevs {
+			if vdev.Type == vdevTypeDisk {
+				if stripe == nil {
+					stripe = map[string]interface{}{"type": vdevTypeStripe, "disks": []interface{}{}}
+					result = append(result, stripe)
+				}
+
+				stripe["disks"] = append(stripe["disks"].([]interface{}), poolVdevDisk(vdev))
+				continue
+			}
+
+			disks := make([]interface{}, 0, len(vdev.Children))
+
+			for _, child := range vdev.Children {
+				disks = append(disks, poolVdevDisk(child))
+			}
+
+			result = append(result, map[string]interface{}{"type": vdev.Type, "disks": disks})
+		}
+
+		mTopology[class] = result
+	}
+
+	spares := make([]interface{}, 0, len(t.Spare))
+
+	for _, vdev := range t.Spare {
+		spares = append(spares, poolVdevDisk(vdev))
+	}
+
+	mTopology["spares"] = spares
+
+	return []interface{}{mTopology}
+}
+
+func poolVdevDisk(vdev poolVdev) string {
+	if vdev.Disk != nil {
+		return *vdev.Disk
+	}
+
+	return ""
+}
@@ -0,0 +1,303 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestAccResourceTruenasPool_basic destroys data on disks listed in TRUENAS_POOL_DISKS,
+// at least three unused disks are required
+func TestAccResourceTruenasPool_basic(t *testing.T) {
+	disks := strings.Split(os.Getenv("TRUENAS_POOL_DISKS"), ",")
+
+	if len(disks) < 3 {
+		t.Skip("TRUENAS_POOL_DISKS must list at least three unused disks to run pool acceptance tests")
+	}
+
+	suffix := acctest.RandStringFromCharSet(5, acctest.CharSetAlphaNum)
+	poolName := fmt.Sprintf("%s-%s", testResourcePrefix, suffix)
+	resourceName := "truenas_pool.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckResourceTruenasPoolDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckResourceTruenasPoolConfig(poolName, disks[:2], ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "name", poolName),
+					resource.TestCheckResourceAttr(resourceName, "topology.0.data.0.type", "MIRROR"),
+					resource.TestCheckResourceAttr(resourceName, "healthy", "true"),
+				),
+			},
+			{
+				Config: testAccCheckResourceTruenasPoolConfig(poolName, disks[:2], fmt.Sprintf(`spares = ["%s"]`, disks[2])),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "topology.0.spares.#", "1"),
+				),
+			},
+			{
+				ResourceName:            resourceName,
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"allow_destroy"},
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasPool_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_pool.test"
+	cache := `
+		cache {
+			type = "STRIPE"
+			disks = ["sdh"]
+		}`
+	grown := cache + `
+		data {
+			type = "STRIPE"
+			disks = ["sdf"]
+		}
+		spares = ["sde"]`
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_pool", "pool"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasPoolConfig("Backup", []string{"sdc", "sdd"}, cache+`
+				cache {
+					type = "STRIPE"
+					disks = ["sdi"]
+				}`),
+				ExpectError: regexp.MustCompile("only one STRIPE cache vdev is allowed"),
+			},
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasPoolConfig("Backup", []string{"sdc", "sdd"}, cache),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet(resourceName, "pool_id"),
+					resource.TestCheckResourceAttr(resourceName, "path", "/mnt/Backup"),
+					resource.TestCheckResourceAttr(resourceName, "topology.0.data.#", "1"),
+					resource.TestCheckResourceAttr(resourceName, "topology.0.data.0.type", "MIRROR"),
+					resource.TestCheckResourceAttr(resourceName, "topology.0.data.0.disks.1", "sdd"),
+					resource.TestCheckResourceAttr(resourceName, "topology.0.cache.0.type", "STRIPE"),
+					resource.TestCheckResourceAttr(resourceName, "topology.0.cache.0.disks.0", "sdh"),
+					resource.TestCheckResourceAttr(resourceName, "checksum", "sha256"),
+					resource.TestCheckResourceAttr(resourceName, "deduplication", "off"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasPoolConfig("Backup", []string{"sdc", "sdd"}, grown),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "topology.0.data.#", "2"),
+					resource.TestCheckResourceAttr(resourceName, "topology.0.data.1.disks.0", "sdf"),
+					resource.TestCheckResourceAttr(resourceName, "topology.0.spares.0", "sde"),
+				),
+			},
+			{
+				ResourceName:            resourceName,
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"allow_destroy"},
+			},
+			{
+				Config:      f.providerConfig() + testAccCheckResourceTruenasPoolConfig("Backup", []string{"sdc", "sdg"}, grown),
+				ExpectError: regexp.MustCompile("disks of data vdev 0 can not be changed"),
+			},
+			{
+				Config: f.providerConfig() + strings.Replace(testAccCheckResourceTruenasPoolConfig("Backup", []string{"sdc", "sdd"}, grown), "allow_destroy = true", "", 1),
+				Check:  resource.TestCheckResourceAttr(resourceName, "allow_destroy", "false"),
+			},
+			{
+				Config:      f.providerConfig() + strings.Replace(testAccCheckResourceTruenasPoolConfig("Backup", []string{"sdc", "sdd"}, grown), "allow_destroy = true", "", 1),
+				Destroy:     true,
+				ExpectError: regexp.MustCompile("pool Backup is protected from destruction"),
+			},
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasPoolConfig("Backup", []string{"sdc", "sdd"}, grown),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasPool_export(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	config := f.providerConfig() + `
+	resource "truenas_pool" "test" {
+		name = "Scratch"
+		delete_action = "EXPORT"
+
+		topology {
+			data {
+				type = "STRIPE"
+				disks = ["sdc"]
+			}
+		}
+	}
+	`
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy: func(s *terraform.State) error {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			if _, ok := f.datasets["Scratch"]; ok {
+				return fmt.Errorf("pool Scratch was not exported")
+			}
+
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check:  resource.TestCheckResourceAttr("truenas_pool.test", "topology.0.data.0.disks.#", "1"),
+			},
+			{
+				Config: strings.Replace(config, `["sdc"]`, `["sdc", "sdd"]`, 1),
+				Check:  resource.TestCheckResourceAttr("truenas_pool.test", "topology.0.data.0.disks.#", "2"),
+			},
+		},
+	})
+}
+
+func Test_poolTopologyAdditions(t *testing.T) {
+	old := poolTopologyParams{
+		Data:   []poolVdevParams{{Type: "MIRROR", Disks: []string{"sda", "sdb"}}},
+		Cache:  []poolVdevParams{{Type: "STRIPE", Disks: []string{"sdc"}}},
+		Spares: []string{"sdd"},
+	}
+
+	additions, err := poolTopologyAdditions(old, poolTopologyParams{
+		Data: []poolVdevParams{
+			{Type: "MIRROR", Disks: []string{"sda", "sdb"}},
+			{Type: "MIRROR", Disks: []string{"sde", "sdf"}},
+		},
+		Log:    []poolVdevParams{{Type: "STRIPE", Disks: []string{"sdg"}}},
+		Cache:  []poolVdevParams{{Type: "STRIPE", Disks: []string{"sdc", "sdh"}}},
+		Spares: []string{"sdd", "sdi"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, poolTopologyParams{
+		Data:   []poolVdevParams{{Type: "MIRROR", Disks: []string{"sde", "sdf"}}},
+		Log:    []poolVdevParams{{Type: "STRIPE", Disks: []string{"sdg"}}},
+		Cache:  []poolVdevParams{{Type: "STRIPE", Disks: []string{"sdh"}}},
+		Spares: []string{"sdi"},
+	}, additions)
+
+	additions, err = poolTopologyAdditions(old, old)
+	assert.NoError(t, err)
+	assert.True(t, additions.isEmpty())
+
+	_, err = poolTopologyAdditions(old, poolTopologyParams{Data: old.Data, Spares: old.Spares})
+	assert.EqualError(t, err, "cache vdevs can not be removed from pool")
+
+	_, err = poolTopologyAdditions(old, poolTopologyParams{
+		Data:  []poolVdevParams{{Type: "MIRROR", Disks: []string{"sda", "sdb", "sde"}}},
+		Cache: old.Cache, Spares: old.Spares,
+	})
+	assert.EqualError(t, err, "disks of data vdev 0 can not be changed, only new vdevs can be added to pool")
+
+	_, err = poolTopologyAdditions(old, poolTopologyParams{
+		Data:  []poolVdevParams{{Type: "RAIDZ1", Disks: []string{"sda", "sdb"}}},
+		Cache: old.Cache, Spares: old.Spares,
+	})
+	assert.EqualError(t, err, "type of data vdev 0 can not be changed from MIRROR to RAIDZ1")
+
+	_, err = poolTopologyAdditions(old, poolTopologyParams{Data: old.Data, Cache: old.Cache})
+	assert.EqualError(t, err, "spares can not be removed from pool, only new spares can be added")
+}
+
+func Test_validatePoolStripes(t *testing.T) {
+	assert.NoError(t, validatePoolStripes(poolTopologyParams{
+		Data:  []poolVdevParams{{Type: "MIRROR", Disks: []string{"sda", "sdb"}}, {Type: "STRIPE", Disks: []string{"sdc", "sdd"}}},
+		Cache: []poolVdevParams{{Type: "STRIPE", Disks: []string{"sde"}}},
+	}))
+
+	assert.EqualError(t, validatePoolStripes(poolTopologyParams{
+		Data: []poolVdevParams{{Type: "STRIPE", Disks: []string{"sda"}}, {Type: "MIRROR", Disks: []string{"sdb", "sdc"}}, {Type: "STRIPE", Disks: []string{"sdd"}}},
+	}), "only one STRIPE data vdev is allowed, list all single disks of the class in it")
+}
+
+func Test_flattenPoolTopology(t *testing.T) {
+	disk := func(name string) poolVdev {
+		return poolVdev{Type: vdevTypeDisk, Disk: &name}
+	}
+
+	topology := &poolTopology{
+		Data:  []poolVdev{{Type: "RAIDZ1", Children: []poolVdev{disk("sda"), disk("sdb"), disk("sdc")}}},
+		Cache: []poolVdev{disk("sdd"), disk("sde")},
+		Spare: []poolVdev{disk("sdf")},
+	}
+
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"data":    []interface{}{map[string]interface{}{"type": "RAIDZ1", "disks": []interface{}{"sda", "sdb", "sdc"}}},
+		"log":     []interface{}{},
+		"cache":   []interface{}{map[string]interface{}{"type": "STRIPE", "disks": []interface{}{"sdd", "sde"}}},
+		"special": []interface{}{},
+		"dedup":   []interface{}{},
+		"spares":  []interface{}{"sdf"},
+	}}, flattenPoolTopology(topology))
+}
+
+func testAccCheckResourceTruenasPoolConfig(name string, mirror []string, topology string) string {
+	return fmt.Sprintf(`
+	resource "truenas_pool" "test" {
+		name = "%s"
+		checksum = "sha256"
+		allow_destroy = true
+
+		topology {
+			data {
+				type = "MIRROR"
+				disks = ["%s"]
+			}
+			%s
+		}
+	}
+	`, name, strings.Join(mirror, `", "`), topology)
+}
+
+func testAccCheckResourceTruenasPoolDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*Client)
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "truenas_pool" {
+			continue
+		}
+
+		id, err := strconv.Atoi(rs.Primary.ID)
+
+		if err != nil {
+			return fmt.Errorf("could not convert ID of pool: %s", rs.Primary.ID)
+		}
+
+		err = client.invoke(context.Background(), "pool.get_instance", []interface{}{id}, nil)
+
+		if err == nil {
+			return fmt.Errorf("pool (%s) still exists", rs.Primary.ID)
+		}
+
+		if !isNotFoundError(nil, err) {
+			return fmt.Errorf("Error occured while checking for absence of pool (%s): %s", rs.Primary.ID, err)
+		}
+	}
+
+	return nil
+}
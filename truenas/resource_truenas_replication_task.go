@@ -0,0 +1,749 @@
+package truenas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"log"
+	"strconv"
+	"time"
+)
+
+var replicationTaskAPIAttrs = apiAttrMap{
+	"name":                                "name",
+	"direction":                           "direction",
+	"transport":                           "transport",
+	"ssh_credentials":                     "ssh_credentials",
+	"netcat_active_side":                  "netcat_active_side",
+	"netcat_active_side_listen_address":   "netcat_active_side_listen_address",
+	"netcat_active_side_port_min":         "netcat_active_side_port_min",
+	"netcat_active_side_port_max":         "netcat_active_side_port_max",
+	"netcat_passive_side_connect_address": "netcat_passive_side_connect_address",
+	"sudo":                                "sudo",
+	"source_datasets":                     "source_datasets",
+	"target_dataset":                      "target_dataset",
+	"recursive":                           "recursive",
+	"exclude":                             "exclude",
+	"properties":                          "properties",
+	"properties_exclude":                  "properties_exclude",
+	"properties_override":                 "properties_override",
+	"replicate":                           "replicate",
+	"encryption":                          "encryption",
+	"encryption_key":                      "encryption_key",
+	"encryption_key_format":               "encryption_key_format",
+	"encryption_key_location":             "encryption_key_location",
+	"periodic_snapshot_tasks":             "periodic_snapshot_tasks",
+	"naming_schema":                       "naming_schema",
+	"also_include_naming_schema":          "also_include_naming_schema",
+	"auto":                                "auto",
+	"schedule":                            "schedule",
+	"restrict_schedule":                   "restrict_schedule",
+	"only_matching_schedule":              "only_matching_schedule",
+	"allow_from_scratch":                  "allow_from_scratch",
+	"readonly":                            "readonly",
+	"hold_pending_snapshots":              "hold_pending_snapshots",
+	"retention_policy":                    "retention_policy",
+	"lifetime_value":                      "lifetime_value",
+	"lifetime_unit":                       "lifetime_unit",
+	"compression":                         "compression",
+	"speed_limit":                         "speed_limit",
+	"large_block":                         "large_block",
+	"embed":                               "embed",
+	"compressed":                          "compressed",
+	"retries":                             "retries",
+	"logging_level":                       "logging_level",
+	"enabled":                             "enabled",
+}
+
+const replicationTransportLocal = "LOCAL"
+
+// replicationTaskParams are replication.create and replication.update params, nil values
+// are sent as null, so that optional settings can be cleared on update
+type replicationTaskParams struct {
+	Name                            string            `json:"name"`
+	Direction                       string            `json:"direction"`
+	Transport                       string            `json:"transport"`
+	SSHCredentials                  *int              `json:"ssh_credentials"`
+	NetcatActiveSide                *string           `json:"netcat_active_side"`
+	NetcatActiveSideListenAddress   *string           `json:"netcat_active_side_listen_address"`
+	NetcatActiveSidePortMin         *int              `json:"netcat_active_side_port_min"`
+	NetcatActiveSidePortMax         *int              `json:"netcat_active_side_port_max"`
+	NetcatPassiveSideConnectAddress *string           `json:"netcat_passive_side_connect_address"`
+	Sudo                            bool              `json:"sudo"`
+	SourceDatasets                  []string          `json:"source_datasets"`
+	TargetDataset                   string            `json:"target_dataset"`
+	Recursive                       bool              `json:"recursive"`
+	Exclude                         []string          `json:"exclude"`
+	Properties                      bool              `json:"properties"`
+	PropertiesExclude               []string          `json:"properties_exclude"`
+	PropertiesOverride              map[string]string `json:"properties_override"`
+	Replicate                       bool              `json:"replicate"`
+	Encryption                      bool              `json:"encryption"`
+	EncryptionKey                   *string           `json:"encryption_key"`
+	EncryptionKeyFormat             *string           `json:"encryption_key_format"`
+	EncryptionKeyLocation           *string           `json:"encryption_key_location"`
+	PeriodicSnapshotTasks           []int             `json:"periodic_snapshot_tasks"`
+	NamingSchema                    []string          `json:"naming_schema"`
+	AlsoIncludeNamingSchema         []string          `json:"also_include_naming_schema"`
+	Auto                            bool              `json:"auto"`
+	Schedule                        *taskSchedule     `json:"schedule"`
+	RestrictSchedule                *taskSchedule     `json:"restrict_schedule"`
+	OnlyMatchingSchedule            bool              `json:"only_matching_schedule"`
+	AllowFromScratch                bool              `json:"allow_from_scratch"`
+	Readonly                        string            `json:"readonly"`
+	HoldPendingSnapshots            bool              `json:"hold_pending_snapshots"`
+	RetentionPolicy                 string            `json:"retention_policy"`
+	LifetimeValue                   *int              `json:"lifetime_value"`
+	LifetimeUnit                    *string           `json:"lifetime_unit"`
+	Compression                     *string           `json:"compression"`
+	SpeedLimit                      *int              `json:"speed_limit"`
+	LargeBlock                      bool              `json:"large_block"`
+	Embed                           bool              `json:"embed"`
+	Compressed                      bool              `json:"compressed"`
+	Retries                         int               `json:"retries"`
+	LoggingLevel                    *string           `json:"logging_level"`
+	Enabled                         bool              `json:"enabled"`
+}
+
+// keychainCredential is keychaincredential.query result, only fields used by the provider are decoded
+type keychainCredential struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// replicationTaskState is replication task state as reported by middleware
+type replicationTaskState struct {
+	State string  `json:"state"`
+	Error *string `json:"error"`
+}
+
+// replicationTask is replication.query result, middleware expands ssh credentials and
+// periodic snapshot tasks into objects, so these fields shadow the ones of replicationTaskParams
+type replicationTask struct {
+	replicationTaskParams
+	ID                    int                    `json:"id"`
+	SSHCredentials        *keychainCredential    `json:"ssh_credentials"`
+	PeriodicSnapshotTasks []periodicSnapshotTask `json:"periodic_snapshot_tasks"`
+	State                 *replicationTaskState  `json:"state"`
+}
+
+func resourceTrueNASReplicationTask() *schema.Resource {
+	schedule := taskScheduleSchema()
+	schedule.Description = "Replication schedule, required for PULL replication or PUSH replication that is not bound to periodic snapshot tasks"
+	schedule.Required = false
+	schedule.Optional = true
+
+	restrictSchedule := taskScheduleSchema()
+	restrictSchedule.Description = "Restricts when PUSH replication bound to periodic snapshot tasks runs"
+	restrictSchedule.Required = false
+	restrictSchedule.Optional = true
+
+	return &schema.Resource{
+		Description:   "Replication task sends ZFS snapshots of one or more datasets to another dataset, either locally or over SSH",
+		CreateContext: resourceTrueNASReplicationTaskCreate,
+		ReadContext:   resourceTrueNASReplicationTaskRead,
+		UpdateContext: resourceTrueNASReplicationTaskUpdate,
+		DeleteContext: resourceTrueNASReplicationTaskDelete,
+		CustomizeDiff: resourceTrueNASReplicationTaskCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceTrueNASReplicationTaskImport,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"task_id": &schema.Schema{
+				Description: "Replication task ID",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"name": &schema.Schema{
+				Description: "Replication task name",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"direction": &schema.Schema{
+				Description:  "Replication direction: PUSH or PULL",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice([]string{"PUSH", "PULL"}, false),
+			},
+			"transport": &schema.Schema{
+				Description:  "Replication transport: SSH, SSH+NETCAT or LOCAL",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice([]string{"SSH", "SSH+NETCAT", replicationTransportLocal}, false),
+			},
+			"ssh_credentials": &schema.Schema{
+				Description: "ID of SSH connection keychain credential, required for SSH and SSH+NETCAT transports",
+				Type:        schema.TypeInt,
+				Optional:    true,
+			},
+			"netcat_active_side": &schema.Schema{
+				Description:  "Side that opens netcat connection for SSH+NETCAT transport: LOCAL or REMOTE",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"LOCAL", "REMOTE"}, false),
+			},
+			"netcat_active_side_listen_address": &schema.Schema{
+				Description: "Address netcat active side listens on",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"netcat_active_side_port_min": &schema.Schema{
+				Description:  "Lowest port netcat active side can listen on",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			"netcat_active_side_port_max": &schema.Schema{
+				Description:  "Highest port netcat active side can listen on",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IsPortNumber,
+			},
+			"netcat_passive_side_connect_address": &schema.Schema{
+				Description: "Address netcat passive side connects to",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"sudo": &schema.Schema{
+				Description: "Use sudo for ZFS commands on remote system",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"source_datasets": &schema.Schema{
+				Description: "Datasets to replicate",
+				Type:        schema.TypeList,
+				Required:    true,
+				MinItems:    1,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"target_dataset": &schema.Schema{
+				Description: "Dataset to replicate snapshots into",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"recursive": &schema.Schema{
+				Description: "Replicate child datasets as well",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"exclude": &schema.Schema{
+				Description: "Child datasets to exclude from recursive replication",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"properties": &schema.Schema{
+				Description: "Send dataset properties along with snapshots",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"properties_exclude": &schema.Schema{
+				Description: "Dataset properties that are not sent",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"properties_override": &schema.Schema{
+				Description: "Dataset properties to override on target",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"replicate": &schema.Schema{
+				Description: "Replicate entire dataset tree including properties, snapshots and clones (zfs send -R)",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"encryption": &schema.Schema{
+				Description: "Encrypt target dataset",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"encryption_key": &schema.Schema{
+				Description: "Target dataset encryption key",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"encryption_key_format": &schema.Schema{
+				Description:  "Target dataset encryption key format: HEX or PASSPHRASE",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"HEX", "PASSPHRASE"}, false),
+			},
+			"encryption_key_location": &schema.Schema{
+				Description: "Path to store encryption key on target system, `$TrueNAS` stores it in TrueNAS database",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"periodic_snapshot_tasks": &schema.Schema{
+				Description: "IDs of periodic snapshot tasks that create snapshots to replicate, PUSH replication runs after these tasks",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"naming_schema": &schema.Schema{
+				Description: "Naming schemas of snapshots to replicate, used by PULL replication",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"also_include_naming_schema": &schema.Schema{
+				Description: "Naming schemas of additional snapshots to replicate, used by PUSH replication",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"auto": &schema.Schema{
+				Description: "Run replication automatically, either after periodic snapshot tasks or on schedule",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"schedule":          schedule,
+			"restrict_schedule": restrictSchedule,
+			"only_matching_schedule": &schema.Schema{
+				Description: "Only replicate snapshots that match schedule or restrict_schedule",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"allow_from_scratch": &schema.Schema{
+				Description: "Destroy target snapshots and replicate from scratch if target has no snapshots in common with source",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"readonly": &schema.Schema{
+				Description:  "Target dataset readonly policy: SET, REQUIRE or IGNORE",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "SET",
+				ValidateFunc: validation.StringInSlice([]string{"SET", "REQUIRE", "IGNORE"}, false),
+			},
+			"hold_pending_snapshots": &schema.Schema{
+				Description: "Prevent source snapshots that failed to replicate from being deleted",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"retention_policy": &schema.Schema{
+				Description:  "Target snapshot retention policy: SOURCE (same as source), CUSTOM (lifetime_value and lifetime_unit) or NONE (keep forever)",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "NONE",
+				ValidateFunc: validation.StringInSlice([]string{"SOURCE", "CUSTOM", "NONE"}, false),
+			},
+			"lifetime_value": &schema.Schema{
+				Description:  "How long target snapshots are kept with CUSTOM retention policy, in `lifetime_unit` units",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"lifetime_unit": &schema.Schema{
+				Description:  "Target snapshot lifetime unit: HOUR, DAY, WEEK, MONTH or YEAR",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice(snapshotLifetimeUnits, false),
+			},
+			"compression": &schema.Schema{
+				Description:  "Stream compression for SSH transport: LZ4, PIGZ or PLZIP, stream is not compressed if not set",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"LZ4", "PIGZ", "PLZIP"}, false),
+			},
+			"speed_limit": &schema.Schema{
+				Description:  "Transfer speed limit for SSH transport in bytes per second, speed is not limited if not set",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"large_block": &schema.Schema{
+				Description: "Allow sending large blocks (zfs send -L)",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"embed": &schema.Schema{
+				Description: "Send embedded data blocks (zfs send -e)",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"compressed": &schema.Schema{
+				Description: "Send compressed blocks as is (zfs send -c)",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"retries": &schema.Schema{
+				Description:  "Number of retries before replication is considered failed",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      5,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"logging_level": &schema.Schema{
+				Description:  "Replication logging level: DEBUG, INFO, WARNING or ERROR, system default is used if not set",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"DEBUG", "INFO", "WARNING", "ERROR"}, false),
+			},
+			"enabled": &schema.Schema{
+				Description: "`true` if task is enabled",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			"run_on_create": &schema.Schema{
+				Description: "Run replication once task is created and wait for it to finish, bounded by create timeout",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"state": &schema.Schema{
+				Description: "State of the last replication run, eg. PENDING, RUNNING, FINISHED or ERROR",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// resourceTrueNASReplicationTaskCustomizeDiff validates attributes that depend on transport and retention policy
+func resourceTrueNASReplicationTaskCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	transport := d.Get("transport").(string)
+	_, hasCredentials := d.GetOk("ssh_credentials")
+
+	if transport == replicationTransportLocal && hasCredentials {
+		return errors.New("ssh_credentials can not be set for LOCAL transport")
+	}
+
+	if transport != replicationTransportLocal && !hasCredentials && d.NewValueKnown("ssh_credentials") {
+		return fmt.Errorf("ssh_credentials are required for %s transport", transport)
+	}
+
+	if transport != "SSH" {
+		for _, attr := range []string{"compression", "speed_limit"} {
+			if _, ok := d.GetOk(attr); ok {
+				return fmt.Errorf("%s is only supported by SSH transport", attr)
+			}
+		}
+	}
+
+	if d.Get("retention_policy").(string) == "CUSTOM" {
+		_, hasValue := d.GetOk("lifetime_value")
+		_, hasUnit := d.GetOk("lifetime_unit")
+
+		if !hasValue || !hasUnit {
+			return errors.New("lifetime_value and lifetime_unit are required for CUSTOM retention policy")
+		}
+	}
+
+	return nil
+}
+
+func resourceTrueNASReplicationTaskCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	input := expandReplicationTask(d)
+
+	log.Printf("[DEBUG] Creating TrueNAS replication task: %s", input.Name)
+
+	var resp replicationTask
+
+	if err := c.invoke(ctx, "replication.create", []interface{}{input}, &resp); err != nil {
+		return apiErrorDiags(err, "error creating replication task", replicationTaskAPIAttrs)
+	}
+
+	d.SetId(strconv.Itoa(resp.ID))
+
+	log.Printf("[INFO] TrueNAS replication task (%d) created", resp.ID)
+
+	if d.Get("run_on_create").(bool) {
+		log.Printf("[DEBUG] Running TrueNAS replication task (%d)", resp.ID)
+
+		if err := c.invokeJob(ctx, "replication.run", []interface{}{resp.ID}, nil); err != nil {
+			return apiErrorDiags(err, "error running replication task", nil)
+		}
+
+		log.Printf("[INFO] TrueNAS replication task (%d) finished", resp.ID)
+	}
+
+	return resourceTrueNASReplicationTaskRead(ctx, d, m)
+}
+
+func resourceTrueNASReplicationTaskRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	id, err := strconv.Atoi(d.Id())
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	var resp replicationTask
+
+	if err := c.invoke(ctx, "replication.get_instance", []interface{}{id}, &resp); err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS replication task (%d) not found, removing from state", id)
+			d.SetId("")
+			return nil
+		}
+		return apiErrorDiags(err, "error getting replication task", nil)
+	}
+
+	if err := flattenReplicationTask(d, &resp); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+func resourceTrueNASReplicationTaskUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	id, err := strconv.Atoi(d.Id())
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	input := expandReplicationTask(d)
+
+	log.Printf("[DEBUG] Updating TrueNAS replication task: %d", id)
+
+	if err := c.invoke(ctx, "replication.update", []interface{}{id, input}, nil); err != nil {
+		return apiErrorDiags(err, "error updating replication task", replicationTaskAPIAttrs)
+	}
+
+	log.Printf("[INFO] TrueNAS replication task (%d) updated", id)
+
+	return resourceTrueNASReplicationTaskRead(ctx, d, m)
+}
+
+func resourceTrueNASReplicationTaskDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	id, err := strconv.Atoi(d.Id())
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	log.Printf("[DEBUG] Deleting TrueNAS replication task: %d", id)
+
+	if err := c.invoke(ctx, "replication.delete", []interface{}{id}, nil); err != nil {
+		return apiErrorDiags(err, "error deleting replication task", nil)
+	}
+
+	log.Printf("[INFO] TrueNAS replication task (%d) deleted", id)
+	d.SetId("")
+
+	return nil
+}
+
+// resourceTrueNASReplicationTaskImport sets run_on_create, that can not be read back, to default
+func resourceTrueNASReplicationTaskImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	d.Set("run_on_create", false)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func expandReplicationTask(d *schema.ResourceData) replicationTaskParams {
+	input := replicationTaskParams{
+		Name:                    d.Get("name").(string),
+		Direction:               d.Get("direction").(string),
+		Transport:               d.Get("transport").(string),
+		Sudo:                    d.Get("sudo").(bool),
+		SourceDatasets:          expandStrings(d.Get("source_datasets").([]interface{})),
+		TargetDataset:           d.Get("target_dataset").(string),
+		Recursive:               d.Get("recursive").(bool),
+		Exclude:                 expandStrings(d.Get("exclude").(*schema.Set).List()),
+		Properties:              d.Get("properties").(bool),
+		PropertiesExclude:       expandStrings(d.Get("properties_exclude").(*schema.Set).List()),
+		PropertiesOverride:      convertStringMap(d.Get("properties_override").(map[string]interface{})),
+		Replicate:               d.Get("replicate").(bool),
+		Encryption:              d.Get("encryption").(bool),
+		PeriodicSnapshotTasks:   []int{},
+		NamingSchema:            expandStrings(d.Get("naming_schema").([]interface{})),
+		AlsoIncludeNamingSchema: expandStrings(d.Get("also_include_naming_schema").([]interface{})),
+		Auto:                    d.Get("auto").(bool),
+		Schedule:                expandTaskSchedule(d.Get("schedule").([]interface{})),
+		RestrictSchedule:        expandTaskSchedule(d.Get("restrict_schedule").([]interface{})),
+		OnlyMatchingSchedule:    d.Get("only_matching_schedule").(bool),
+		AllowFromScratch:        d.Get("allow_from_scratch").(bool),
+		Readonly:                d.Get("readonly").(string),
+		HoldPendingSnapshots:    d.Get("hold_pending_snapshots").(bool),
+		RetentionPolicy:         d.Get("retention_policy").(string),
+		LargeBlock:              d.Get("large_block").(bool),
+		Embed:                   d.Get("embed").(bool),
+		Compressed:              d.Get("compressed").(bool),
+		Retries:                 d.Get("retries").(int),
+		Enabled:                 d.Get("enabled").(bool),
+	}
+
+	for _, id := range d.Get("periodic_snapshot_tasks").(*schema.Set).List() {
+		input.PeriodicSnapshotTasks = append(input.PeriodicSnapshotTasks, id.(int))
+	}
+
+	optionalInt := func(attr string) *int {
+		if v, ok := d.GetOk(attr); ok {
+			i := v.(int)
+			return &i
+		}
+		return nil
+	}
+
+	optionalString := func(attr string) *string {
+		if v, ok := d.GetOk(attr); ok {
+			return getStringPtr(v.(string))
+		}
+		return nil
+	}
+
+	input.SSHCredentials = optionalInt("ssh_credentials")
+	input.NetcatActiveSide = optionalString("netcat_active_side")
+	input.NetcatActiveSideListenAddress = optionalString("netcat_active_side_listen_address")
+	input.NetcatActiveSidePortMin = optionalInt("netcat_active_side_port_min")
+	input.NetcatActiveSidePortMax = optionalInt("netcat_active_side_port_max")
+	input.NetcatPassiveSideConnectAddress = optionalString("netcat_passive_side_connect_address")
+	input.EncryptionKey = optionalString("encryption_key")
+	input.EncryptionKeyFormat = optionalString("encryption_key_format")
+	input.EncryptionKeyLocation = optionalString("encryption_key_location")
+	input.LifetimeValue = optionalInt("lifetime_value")
+	input.LifetimeUnit = optionalString("lifetime_unit")
+	input.Compression = optionalString("compression")
+	input.SpeedLimit = optionalInt("speed_limit")
+	input.LoggingLevel = optionalString("logging_level")
+
+	return input
+}
+
+func flattenReplicationTask(d *schema.ResourceData, task *replicationTask) error {
+	d.Set("task_id", task.ID)
+	d.Set("name", task.Name)
+	d.Set("direction", task.Direction)
+	d.Set("transport", task.Transport)
+
+	if task.SSHCredentials != nil {
+		d.Set("ssh_credentials", task.SSHCredentials.ID)
+	} else {
+		d.Set("ssh_credentials", nil)
+	}
+
+	d.Set("netcat_active_side", task.NetcatActiveSide)
+	d.Set("netcat_active_side_listen_address", task.NetcatActiveSideListenAddress)
+	d.Set("netcat_active_side_port_min", task.NetcatActiveSidePortMin)
+	d.Set("netcat_active_side_port_max", task.NetcatActiveSidePortMax)
+	d.Set("netcat_passive_side_connect_address", task.NetcatPassiveSideConnectAddress)
+	d.Set("sudo", task.Sudo)
+
+	if err := d.Set("source_datasets", flattenStringList(task.SourceDatasets)); err != nil {
+		return err
+	}
+
+	d.Set("target_dataset", task.TargetDataset)
+	d.Set("recursive", task.Recursive)
+
+	if err := d.Set("exclude", flattenStringList(task.Exclude)); err != nil {
+		return err
+	}
+
+	d.Set("properties", task.Properties)
+
+	if err := d.Set("properties_exclude", flattenStringList(task.PropertiesExclude)); err != nil {
+		return err
+	}
+
+	if err := d.Set("properties_override", task.PropertiesOverride); err != nil {
+		return err
+	}
+
+	d.Set("replicate", task.Replicate)
+	d.Set("encryption", task.Encryption)
+	d.Set("encryption_key", task.EncryptionKey)
+	d.Set("encryption_key_format", task.EncryptionKeyFormat)
+	d.Set("encryption_key_location", task.EncryptionKeyLocation)
+
+	taskIDs := make([]interface{}, 0, len(task.PeriodicSnapshotTasks))
+
+	for _, t := range task.PeriodicSnapshotTasks {
+		taskIDs = append(taskIDs, t.ID)
+	}
+
+	if err := d.Set("periodic_snapshot_tasks", taskIDs); err != nil {
+		return err
+	}
+
+	if err := d.Set("naming_schema", flattenStringList(task.NamingSchema)); err != nil {
+		return err
+	}
+
+	if err := d.Set("also_include_naming_schema", flattenStringList(task.AlsoIncludeNamingSchema)); err != nil {
+		return err
+	}
+
+	d.Set("auto", task.Auto)
+
+	for attr, s := range map[string]*taskSchedule{"schedule": task.Schedule, "restrict_schedule": task.RestrictSchedule} {
+		var value []interface{}
+
+		if s != nil {
+			value = flattenTaskSchedule(*s)
+		}
+
+		if err := d.Set(attr, value); err != nil {
+			return err
+		}
+	}
+
+	d.Set("only_matching_schedule", task.OnlyMatchingSchedule)
+	d.Set("allow_from_scratch", task.AllowFromScratch)
+	d.Set("readonly", task.Readonly)
+	d.Set("hold_pending_snapshots", task.HoldPendingSnapshots)
+	d.Set("retention_policy", task.RetentionPolicy)
+	d.Set("lifetime_value", task.LifetimeValue)
+	d.Set("lifetime_unit", task.LifetimeUnit)
+	d.Set("compression", task.Compression)
+	d.Set("speed_limit", task.SpeedLimit)
+	d.Set("large_block", task.LargeBlock)
+	d.Set("embed", task.Embed)
+	d.Set("compressed", task.Compressed)
+	d.Set("retries", task.Retries)
+	d.Set("logging_level", task.LoggingLevel)
+	d.Set("enabled", task.Enabled)
+
+	if task.State != nil {
+		d.Set("state", task.State.State)
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestAccResourceTruenasReplicationTask_basic(t *testing.T) {
+	suffix := acctest.RandStringFromCharSet(5, acctest.CharSetAlphaNum)
+	datasetName := fmt.Sprintf("%s-%s", testResourcePrefix, suffix)
+	resourceName := "truenas_replication_task.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckResourceTruenasReplicationTaskDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckResourceTruenasReplicationTaskConfig(testPoolName, datasetName, "NONE", false),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "name", datasetName),
+					resource.TestCheckResourceAttr(resourceName, "direction", "PUSH"),
+					resource.TestCheckResourceAttr(resourceName, "transport", "LOCAL"),
+					resource.TestCheckResourceAttr(resourceName, "source_datasets.0", fmt.Sprintf("%s/%s", testPoolName, datasetName)),
+					resource.TestCheckResourceAttr(resourceName, "target_dataset", fmt.Sprintf("%s/%s-backup", testPoolName, datasetName)),
+					resource.TestCheckResourceAttr(resourceName, "periodic_snapshot_tasks.#", "1"),
+					resource.TestCheckResourceAttr(resourceName, "retention_policy", "NONE"),
+				),
+			},
+			{
+				Config: testAccCheckResourceTruenasReplicationTaskConfig(testPoolName, datasetName, "CUSTOM", false),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "retention_policy", "CUSTOM"),
+					resource.TestCheckResourceAttr(resourceName, "lifetime_value", "1"),
+					resource.TestCheckResourceAttr(resourceName, "lifetime_unit", "MONTH"),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasReplicationTask_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_replication_task.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_replication_task", "replication"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasReplicationTaskConfig(fakePoolName, "data", "NONE", true),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "transport", "LOCAL"),
+					resource.TestCheckResourceAttrPair(resourceName, "periodic_snapshot_tasks.0", "truenas_periodic_snapshot_task.test", "task_id"),
+					resource.TestCheckResourceAttr(resourceName, "recursive", "true"),
+					resource.TestCheckResourceAttr(resourceName, "readonly", "SET"),
+					resource.TestCheckResourceAttr(resourceName, "state", "FINISHED"),
+					func(s *terraform.State) error {
+						f.mu.Lock()
+						defer f.mu.Unlock()
+
+						if _, ok := f.datasets["Tank/data-backup"]; !ok {
+							return fmt.Errorf("target dataset was not created by replication run")
+						}
+						return nil
+					},
+				),
+			},
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasReplicationTaskConfig(fakePoolName, "data", "CUSTOM", true),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "retention_policy", "CUSTOM"),
+					resource.TestCheckResourceAttr(resourceName, "lifetime_value", "1"),
+					resource.TestCheckResourceAttr(resourceName, "lifetime_unit", "MONTH"),
+				),
+			},
+			{
+				ResourceName:            resourceName,
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"run_on_create"},
+			},
+		},
+	})
+}
+
+func TestResourceTrueNASReplicationTask_customizeDiff(t *testing.T) {
+	raw := map[string]interface{}{
+		"name":            "test",
+		"direction":       "PUSH",
+		"transport":       "SSH",
+		"source_datasets": []interface{}{"Tank/data"},
+		"target_dataset":  "Backup/data",
+		"ssh_credentials": 1,
+		"compression":     "LZ4",
+	}
+
+	assert.NoError(t, testCustomizeDiff(t, "truenas_replication_task", raw, &Client{}))
+
+	raw["transport"] = "LOCAL"
+	assert.EqualError(t, testCustomizeDiff(t, "truenas_replication_task", raw, &Client{}),
+		"ssh_credentials can not be set for LOCAL transport")
+
+	delete(raw, "ssh_credentials")
+	assert.EqualError(t, testCustomizeDiff(t, "truenas_replication_task", raw, &Client{}),
+		"compression is only supported by SSH transport")
+
+	raw["transport"] = "SSH+NETCAT"
+	assert.EqualError(t, testCustomizeDiff(t, "truenas_replication_task", raw, &Client{}),
+		"ssh_credentials are required for SSH+NETCAT transport")
+
+	raw["transport"] = "LOCAL"
+	raw["retention_policy"] = "CUSTOM"
+	delete(raw, "compression")
+	assert.EqualError(t, testCustomizeDiff(t, "truenas_replication_task", raw, &Client{}),
+		"lifetime_value and lifetime_unit are required for CUSTOM retention policy")
+}
+
+func testAccCheckResourceTruenasReplicationTaskConfig(pool string, datasetName string, retentionPolicy string, runOnCreate bool) string {
+	lifetime := ""
+
+	if retentionPolicy == "CUSTOM" {
+		lifetime = `
+		lifetime_value = 1
+		lifetime_unit = "MONTH"`
+	}
+
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "test" {
+		name = "%s"
+		pool = "%s"
+	}
+
+	resource "truenas_periodic_snapshot_task" "test" {
+		dataset = truenas_dataset.test.id
+		recursive = true
+		naming_schema = "repl-%%Y%%m%%d-%%H%%M"
+
+		schedule {
+			minute = "0"
+		}
+	}
+
+	resource "truenas_replication_task" "test" {
+		name = "%s"
+		direction = "PUSH"
+		transport = "LOCAL"
+		source_datasets = [truenas_dataset.test.id]
+		target_dataset = "${truenas_dataset.test.id}-backup"
+		recursive = true
+		periodic_snapshot_tasks = [truenas_periodic_snapshot_task.test.task_id]
+		retention_policy = "%s"%s
+		run_on_create = %t
+	}
+	`, datasetName, pool, datasetName, retentionPolicy, lifetime, runOnCreate)
+}
+
+func testAccCheckResourceTruenasReplicationTaskDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*Client)
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "truenas_replication_task" {
+			continue
+		}
+
+		id, err := strconv.Atoi(rs.Primary.ID)
+
+		if err != nil {
+			return fmt.Errorf("could not convert ID of replication task: %s", rs.Primary.ID)
+		}
+
+		err = client.invoke(context.Background(), "replication.get_instance", []interface{}{id}, nil)
+
+		if err == nil {
+			return fmt.Errorf("replication task (%s) still exists", rs.Primary.ID)
+		}
+
+		if !isNotFoundError(nil, err) {
+			return fmt.Errorf("Error occured while checking for absence of replication task (%s): %s", rs.Primary.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -10,6 +10,25 @@ import (
 	"strconv"
 )
 
+// middleware reloads NFS service on every share change, serialize share operations
+const shareNFSLockKey = "sharing/nfs"
+
+var shareNFSAPIAttrs = apiAttrMap{
+	"paths":         "paths",
+	"comment":       "comment",
+	"networks":      "networks",
+	"hosts":         "hosts",
+	"alldirs":       "alldirs",
+	"ro":            "ro",
+	"quiet":         "quiet",
+	"maproot_user":  "maproot_user",
+	"maproot_group": "maproot_group",
+	"mapall_user":   "mapall_user",
+	"mapall_group":  "mapall_group",
+	"security":      "security",
+	"enabled":       "enabled",
+}
+
 func resourceTrueNASShareNFS() *schema.Resource {
 	return &schema.Resource{
 		Description:   "Creating a Network File System (NFS) share on TrueNAS gives the benefit of making lots of data easily available for anyone with share access. Depending how the share is configured, users accessing the share can be restricted to read or write privileges. To create a new share, make sure a dataset is available with all the data for sharing.",
@@ -17,6 +36,7 @@ func resourceTrueNASShareNFS() *schema.Resource {
 		ReadContext:   resourceTrueNASShareNFSRead,
 		UpdateContext: resourceTrueNASShareNFSUpdate,
 		DeleteContext: resourceTrueNASShareNFSDelete,
+		CustomizeDiff: resourceTrueNASShareNFSCustomizeDiff,
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
@@ -114,25 +134,48 @@ func resourceTrueNASShareNFS() *schema.Resource {
 	}
 }
 
+// resourceTrueNASShareNFSCustomizeDiff validates attributes against capabilities of connected system,
+// starting with SCALE 22.12 NFS share has a single path and no alldirs or quiet options
+func resourceTrueNASShareNFSCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	system := systemFromMeta(m)
+
+	if system == nil || !system.isSCALE() || !system.Release.atLeast(22, 12) {
+		return nil
+	}
+
+	if paths := d.Get("paths").(*schema.Set); paths.Len() > 1 {
+		return unsupportedError(system, "paths", paths.List(), "NFS share can only have a single path")
+	}
+
+	for _, attr := range []string{"alldirs", "quiet"} {
+		if d.Get(attr).(bool) {
+			return unsupportedError(system, attr, true, "option was removed from NFS shares")
+		}
+	}
+
+	return nil
+}
+
 func resourceTrueNASShareNFSRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id, err := strconv.Atoi(d.Id())
 
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	resp, http, err := c.SharingApi.GetShareNFS(ctx, int32(id)).Execute()
+	var resp api.ShareNFS
 
-	if err != nil {
+	if err := c.invoke(ctx, "sharing.nfs.get_instance", []interface{}{id}, &resp); err != nil {
 		// gracefully handle manual deletions
-		d.SetId("")
-		if http.StatusCode == 404 {
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS NFS share (%d) not found, removing from state", id)
+			d.SetId("")
 			return nil
 		}
-		return diag.Errorf("error getting nfs share: %s", err)
+		return apiErrorDiags(err, "error getting NFS share", nil)
 	}
 
 	if resp.Comment != nil {
@@ -189,18 +232,17 @@ func resourceTrueNASShareNFSRead(ctx context.Context, d *schema.ResourceData, m
 }
 
 func resourceTrueNASShareNFSCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
+
+	c.lock(shareNFSLockKey)
+	defer c.unlock(shareNFSLockKey)
 
 	input := expandShareNFS(d)
 
-	resp, _, err := c.SharingApi.CreateShareNFS(ctx).CreateShareNFSParams(input).Execute()
+	var resp api.ShareNFS
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error creating NFS share: %s\n%s", err, body)
+	if err := c.invoke(ctx, "sharing.nfs.create", []interface{}{input}, &resp); err != nil {
+		return apiErrorDiags(err, "error creating NFS share", shareNFSAPIAttrs)
 	}
 
 	d.SetId(strconv.Itoa(int(resp.Id)))
@@ -211,7 +253,11 @@ func resourceTrueNASShareNFSCreate(ctx context.Context, d *schema.ResourceData,
 func resourceTrueNASShareNFSDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
+
+	c.lock(shareNFSLockKey)
+	defer c.unlock(shareNFSLockKey)
+
 	id, err := strconv.Atoi(d.Id())
 
 	if err != nil {
@@ -220,14 +266,8 @@ func resourceTrueNASShareNFSDelete(ctx context.Context, d *schema.ResourceData,
 
 	log.Printf("[DEBUG] Deleting TrueNAS NFS share: %s", strconv.Itoa(id))
 
-	_, err = c.SharingApi.RemoveShareNFS(ctx, int32(id)).Execute()
-
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error deleting NFS share: %s\n%s", err, body)
+	if err := c.invoke(ctx, "sharing.nfs.delete", []interface{}{id}, nil); err != nil {
+		return apiErrorDiags(err, "error deleting NFS share", nil)
 	}
 
 	log.Printf("[INFO] TrueNAS NFS share (%s) deleted", strconv.Itoa(id))
@@ -237,7 +277,11 @@ func resourceTrueNASShareNFSDelete(ctx context.Context, d *schema.ResourceData,
 }
 
 func resourceTrueNASShareNFSUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
+
+	c.lock(shareNFSLockKey)
+	defer c.unlock(shareNFSLockKey)
+
 	share := expandShareNFS(d)
 
 	id, err := strconv.Atoi(d.Id())
@@ -246,14 +290,8 @@ func resourceTrueNASShareNFSUpdate(ctx context.Context, d *schema.ResourceData,
 		return diag.FromErr(err)
 	}
 
-	_, _, err = c.SharingApi.UpdateShareNFS(ctx, int32(id)).CreateShareNFSParams(share).Execute()
-
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error updating NFS share: %s\n%s", err, body)
+	if err := c.invoke(ctx, "sharing.nfs.update", []interface{}{id, share}, nil); err != nil {
+		return apiErrorDiags(err, "error updating NFS share", shareNFSAPIAttrs)
 	}
 
 	return resourceTrueNASShareNFSRead(ctx, d, m)
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -59,6 +60,48 @@ func TestAccResourceTruenasShareNFS_basic(t *testing.T) {
 	})
 }
 
+func TestUnitResourceTruenasShareNFS_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_share_nfs.nfs"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(
+			testAccCheckFakeDestroy(f, "truenas_share_nfs", "sharing/nfs"),
+			testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasShareNFSConfig(fakePoolName, "nfs"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckTypeSetElemAttr(resourceName, "paths.*", "/mnt/Tank/nfs"),
+					resource.TestCheckResourceAttr(resourceName, "comment", "Testing NFS share"),
+					resource.TestCheckTypeSetElemAttr(resourceName, "hosts.*", "google.com"),
+					resource.TestCheckResourceAttr(resourceName, "ro", "true"),
+					resource.TestCheckTypeSetElemAttr(resourceName, "networks.*", "10.128.0.0/9"),
+				),
+			},
+			{
+				Config: f.providerConfig() + strings.NewReplacer(
+					"Testing NFS share", "Updated NFS share",
+					"ro = true", "ro = false",
+				).Replace(testAccCheckResourceTruenasShareNFSConfig(fakePoolName, "nfs")),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "comment", "Updated NFS share"),
+					resource.TestCheckResourceAttr(resourceName, "ro", "false"),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
 func testAccCheckResourceTruenasShareNFSConfig(pool string, datasetName string) string {
 	return fmt.Sprintf(`
 	resource "truenas_dataset" "test" {
@@ -105,7 +148,7 @@ func testAccCheckTruenasShareNFSResourceExists(n string, share *api.ShareNFS) re
 			return fmt.Errorf("no nfs share ID is set")
 		}
 
-		client := testAccProvider.Meta().(*api.APIClient)
+		client := testAccProvider.Meta().(*Client)
 
 		id, err := strconv.Atoi(rs.Primary.ID)
 
@@ -189,7 +232,7 @@ func testAccCheckTruenasShareNFSResourceAttributes(t *testing.T, n string, share
 }
 
 func testAccCheckResourceTruenasShareNFSDestroy(s *terraform.State) error {
-	client := testAccProvider.Meta().(*api.APIClient)
+	client := testAccProvider.Meta().(*Client)
 
 	for _, rs := range s.RootModule().Resources {
 		if rs.Type != "truenas_share_nfs" {
@@ -219,7 +262,7 @@ func testAccCheckResourceTruenasShareNFSDestroy(s *terraform.State) error {
 }
 
 func testAccCheckResourceTruenasShareNFSDatasetDestroy(s *terraform.State) error {
-	client := testAccProvider.Meta().(*api.APIClient)
+	client := testAccProvider.Meta().(*Client)
 
 	for _, rs := range s.RootModule().Resources {
 		if rs.Type != "truenas_dataset" {
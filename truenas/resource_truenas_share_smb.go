@@ -10,8 +10,37 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"log"
 	"strconv"
+	"strings"
 )
 
+// middleware reloads SMB service on every share change, serialize share operations
+const shareSMBLockKey = "sharing/smb"
+
+var shareSMBAPIAttrs = apiAttrMap{
+	"purpose":            "purpose",
+	"path":               "path",
+	"path_suffix":        "path_suffix",
+	"home":               "home",
+	"name":               "name",
+	"comment":            "comment",
+	"ro":                 "ro",
+	"browsable":          "browsable",
+	"timemachine":        "timemachine",
+	"recyclebin":         "recyclebin",
+	"guestok":            "guestok",
+	"abe":                "abe",
+	"hostsallow":         "hostsallow",
+	"hostsdeny":          "hostsdeny",
+	"aapl_name_mangling": "aapl_name_mangling",
+	"acl":                "acl",
+	"durablehandle":      "durablehandle",
+	"shadowcopy":         "shadowcopy",
+	"streams":            "streams",
+	"fsrvp":              "fsrvp",
+	"auxsmbconf":         "auxsmbconf",
+	"enabled":            "enabled",
+}
+
 func resourceTrueNASShareSMB() *schema.Resource {
 	return &schema.Resource{
 		Description:   "SMB (also known as CIFS) is the native file sharing system in Windows. SMB shares can connect to any major operating system, including Windows, MacOS, and Linux. SMB can be used in TrueNAS to share files among single or multiple users or devices.",
@@ -19,6 +48,7 @@ func resourceTrueNASShareSMB() *schema.Resource {
 		ReadContext:   resourceTrueNASShareSMBRead,
 		UpdateContext: resourceTrueNASShareSMBUpdate,
 		DeleteContext: resourceTrueNASShareSMBDelete,
+		CustomizeDiff: resourceTrueNASShareSMBCustomizeDiff,
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
@@ -160,25 +190,51 @@ func resourceTrueNASShareSMB() *schema.Resource {
 	}
 }
 
+// resourceTrueNASShareSMBCustomizeDiff validates share purpose against presets supported by connected system
+func resourceTrueNASShareSMBCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	system := systemFromMeta(m)
+	purpose := d.Get("purpose").(string)
+
+	if system == nil || purpose == "" || !d.NewValueKnown("purpose") {
+		return nil
+	}
+
+	presets, err := m.(*Client).smbPresetNames(ctx)
+
+	if err != nil {
+		log.Printf("[WARN] Unable to get SMB share presets, skipping purpose validation: %s", err)
+		return nil
+	}
+
+	for _, preset := range presets {
+		if preset == purpose {
+			return nil
+		}
+	}
+
+	return unsupportedError(system, "purpose", purpose, fmt.Sprintf("supported presets: %s", strings.Join(presets, ", ")))
+}
+
 func resourceTrueNASShareSMBRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id, err := strconv.Atoi(d.Id())
 
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	resp, http, err := c.SharingApi.GetShareSMB(ctx, int32(id)).Execute()
+	var resp api.ShareSMB
 
-	if err != nil {
+	if err := c.invoke(ctx, "sharing.smb.get_instance", []interface{}{id}, &resp); err != nil {
 		// gracefully handle manual deletions
-		d.SetId("")
-		if http.StatusCode == 404 {
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS SMB share (%d) not found, removing from state", id)
+			d.SetId("")
 			return nil
 		}
-		return diag.Errorf("error getting smb share: %s", err)
+		return apiErrorDiags(err, "error getting SMB share", nil)
 	}
 
 	d.Set("path", resp.Path)
@@ -242,18 +298,21 @@ func resourceTrueNASShareSMBRead(ctx context.Context, d *schema.ResourceData, m
 }
 
 func resourceTrueNASShareSMBCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 
-	input, err := expandShareSMB(d)
+	c.lock(shareSMBLockKey)
+	defer c.unlock(shareSMBLockKey)
 
-	resp, _, err := c.SharingApi.CreateShareSMB(ctx).CreateShareSMBParams(input).Execute()
+	input, err := expandShareSMB(d)
 
 	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error creating SMB share: %s\n%s", err, body)
+		return diag.FromErr(err)
+	}
+
+	var resp api.ShareSMB
+
+	if err := c.invoke(ctx, "sharing.smb.create", []interface{}{input}, &resp); err != nil {
+		return apiErrorDiags(err, "error creating SMB share", shareSMBAPIAttrs)
 	}
 
 	d.SetId(strconv.Itoa(int(resp.Id)))
@@ -264,7 +323,11 @@ func resourceTrueNASShareSMBCreate(ctx context.Context, d *schema.ResourceData,
 func resourceTrueNASShareSMBDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
+
+	c.lock(shareSMBLockKey)
+	defer c.unlock(shareSMBLockKey)
+
 	id, err := strconv.Atoi(d.Id())
 
 	if err != nil {
@@ -273,14 +336,8 @@ func resourceTrueNASShareSMBDelete(ctx context.Context, d *schema.ResourceData,
 
 	log.Printf("[DEBUG] Deleting TrueNAS SMB share: %s", strconv.Itoa(id))
 
-	_, err = c.SharingApi.RemoveShareSMB(ctx, int32(id)).Execute()
-
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error deleting SMB share: %s\n%s", err, body)
+	if err := c.invoke(ctx, "sharing.smb.delete", []interface{}{id}, nil); err != nil {
+		return apiErrorDiags(err, "error deleting SMB share", nil)
 	}
 
 	log.Printf("[INFO] TrueNAS SMB share (%s) deleted", strconv.Itoa(id))
@@ -290,7 +347,11 @@ func resourceTrueNASShareSMBDelete(ctx context.Context, d *schema.ResourceData,
 }
 
 func resourceTrueNASShareSMBUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
+
+	c.lock(shareSMBLockKey)
+	defer c.unlock(shareSMBLockKey)
+
 	share, err := expandShareSMB(d)
 
 	if err != nil {
@@ -303,14 +364,8 @@ func resourceTrueNASShareSMBUpdate(ctx context.Context, d *schema.ResourceData,
 		return diag.FromErr(err)
 	}
 
-	_, _, err = c.SharingApi.UpdateShareSMB(ctx, int32(id)).CreateShareSMBParams(share).Execute()
-
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error updating SMB share: %s\n%s", err, body)
+	if err := c.invoke(ctx, "sharing.smb.update", []interface{}{id, share}, nil); err != nil {
+		return apiErrorDiags(err, "error updating SMB share", shareSMBAPIAttrs)
 	}
 
 	return resourceTrueNASShareSMBRead(ctx, d, m)
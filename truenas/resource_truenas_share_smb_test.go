@@ -8,7 +8,9 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 	"github.com/stretchr/testify/assert"
+	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -89,6 +91,63 @@ func Test_lockedSMBProfileParamIsRejected(t *testing.T) {
 	}
 }
 
+func TestUnitResourceTruenasShareSMB_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_share_smb.smb"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(
+			testAccCheckFakeDestroy(f, "truenas_share_smb", "sharing/smb"),
+			testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasShareSMBConfig(fakePoolName, "smb"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "path", "/mnt/Tank/smb"),
+					resource.TestCheckResourceAttr(resourceName, "name", "smb"),
+					resource.TestCheckResourceAttr(resourceName, "comment", "Testing SMB share"),
+					resource.TestCheckTypeSetElemAttr(resourceName, "hostsdeny.*", "ALL"),
+					resource.TestCheckResourceAttr(resourceName, "ro", "true"),
+					resource.TestCheckResourceAttrSet(resourceName, "vuid"),
+				),
+			},
+			{
+				Config: f.providerConfig() + strings.NewReplacer(
+					"Testing SMB share", "Updated SMB share",
+					"ro = true", "ro = false",
+				).Replace(testAccCheckResourceTruenasShareSMBConfig(fakePoolName, "smb")),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "comment", "Updated SMB share"),
+					resource.TestCheckResourceAttr(resourceName, "ro", "false"),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				Config: f.providerConfig() + fmt.Sprintf(`
+				resource "truenas_dataset" "test" {
+					name = "smb"
+					pool = "%s"
+				}
+
+				resource "truenas_share_smb" "smb" {
+					path = truenas_dataset.test.mount_point
+					purpose = "WORM_DROPBOX"
+				}
+				`, fakePoolName),
+				ExpectError: regexp.MustCompile("purpose = WORM_DROPBOX is not supported by TrueNAS SCALE 22.12.0"),
+			},
+		},
+	})
+}
+
 func testAccCheckResourceTruenasShareSMBConfig(pool string, datasetName string) string {
 	return fmt.Sprintf(`
 	resource "truenas_dataset" "test" {
@@ -139,7 +198,7 @@ func testAccCheckTruenasShareSMBResourceExists(n string, share *api.ShareSMB) re
 			return fmt.Errorf("no smb share ID is set")
 		}
 
-		client := testAccProvider.Meta().(*api.APIClient)
+		client := testAccProvider.Meta().(*Client)
 
 		id, err := strconv.Atoi(rs.Primary.ID)
 
@@ -258,7 +317,7 @@ func testAccCheckTruenasShareSMBResourceAttributes(t *testing.T, n string, share
 }
 
 func testAccCheckResourceTruenasShareSMBDestroy(s *terraform.State) error {
-	client := testAccProvider.Meta().(*api.APIClient)
+	client := testAccProvider.Meta().(*Client)
 
 	for _, rs := range s.RootModule().Resources {
 		if rs.Type != "truenas_share_smb" {
@@ -288,7 +347,7 @@ func testAccCheckResourceTruenasShareSMBDestroy(s *terraform.State) error {
 }
 
 func testAccCheckResourceTruenasShareSMBDatasetDestroy(s *terraform.State) error {
-	client := testAccProvider.Meta().(*api.APIClient)
+	client := testAccProvider.Meta().(*Client)
 
 	for _, rs := range s.RootModule().Resources {
 		if rs.Type != "truenas_dataset" {
@@ -0,0 +1,246 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	api "github.com/dariusbakunas/truenas-go-sdk"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var snapshotAPIAttrs = apiAttrMap{
+	"dataset":     "dataset",
+	"name":        "name",
+	"recursive":   "recursive",
+	"vmware_sync": "vmware_sync",
+}
+
+// snapshot is zfs.snapshot.query result
+type snapshot struct {
+	ID           string                        `json:"id"`
+	Name         string                        `json:"name"`
+	Pool         string                        `json:"pool"`
+	Dataset      string                        `json:"dataset"`
+	SnapshotName string                        `json:"snapshot_name"`
+	Properties   map[string]api.CompositeValue `json:"properties"`
+}
+
+// rawProperty returns raw value of ZFS property, or empty string if it is not reported
+func (s *snapshot) rawProperty(name string) string {
+	if p, ok := s.Properties[name]; ok {
+		return p.Rawvalue
+	}
+
+	return ""
+}
+
+// parseSnapshotID splits snapshot ID in format pool/dataset@name into dataset and snapshot name
+func parseSnapshotID(id string) (string, string, error) {
+	s := strings.SplitN(id, "@", 2)
+
+	if len(s) != 2 || s[0] == "" || s[1] == "" {
+		return "", "", fmt.Errorf("invalid snapshot ID %q, expected format: pool/dataset@name", id)
+	}
+
+	return s[0], s[1], nil
+}
+
+func resourceTrueNASSnapshot() *schema.Resource {
+	return &schema.Resource{
+		Description:   "Manage one-off ZFS snapshot of a dataset or zvol, eg. to take a checkpoint before risky changes",
+		CreateContext: resourceTrueNASSnapshotCreate,
+		ReadContext:   resourceTrueNASSnapshotRead,
+		UpdateContext: resourceTrueNASSnapshotUpdate,
+		DeleteContext: resourceTrueNASSnapshotDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceTrueNASSnapshotImport,
+		},
+		Schema: map[string]*schema.Schema{
+			"snapshot_id": &schema.Schema{
+				Description: "Snapshot ID, eg. Tank/data@checkpoint",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"dataset": &schema.Schema{
+				Description: "Dataset or zvol to snapshot, eg. Tank/data",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			"name": &schema.Schema{
+				Description:  "Snapshot name",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringDoesNotContainAny("@/"),
+			},
+			"recursive": &schema.Schema{
+				Description: "Snapshot all child datasets as well, child snapshots are also deleted when snapshot is destroyed",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+			},
+			"vmware_sync": &schema.Schema{
+				Description: "Sync VMware VMs stored on the dataset before taking snapshot, requires configured VMware integration",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+			},
+			"defer_delete": &schema.Schema{
+				Description: "Defer snapshot deletion if it is in use (eg. has clones or holds), snapshot is destroyed once it is released",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"used_bytes": &schema.Schema{
+				Description: "Space consumed by snapshot in bytes",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"referenced_bytes": &schema.Schema{
+				Description: "Amount of data accessible by snapshot in bytes",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"creation_time": &schema.Schema{
+				Description: "Snapshot creation time in RFC3339 format",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceTrueNASSnapshotCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	c := m.(*Client)
+
+	input := map[string]interface{}{
+		"dataset":     d.Get("dataset").(string),
+		"name":        d.Get("name").(string),
+		"recursive":   d.Get("recursive").(bool),
+		"vmware_sync": d.Get("vmware_sync").(bool),
+	}
+
+	log.Printf("[DEBUG] Creating TrueNAS snapshot: %+v", input)
+
+	var resp snapshot
+
+	if err := c.invoke(ctx, "zfs.snapshot.create", []interface{}{input}, &resp); err != nil {
+		return apiErrorDiags(err, "error creating snapshot", snapshotAPIAttrs)
+	}
+
+	d.SetId(resp.ID)
+
+	log.Printf("[INFO] TrueNAS snapshot (%s) created", resp.ID)
+
+	return resourceTrueNASSnapshotRead(ctx, d, m)
+}
+
+func resourceTrueNASSnapshotRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	c := m.(*Client)
+	id := d.Id()
+
+	var resp snapshot
+
+	if err := c.invoke(ctx, "zfs.snapshot.get_instance", []interface{}{id}, &resp); err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS snapshot (%s) not found, removing from state", id)
+			d.SetId("")
+			return nil
+		}
+		return apiErrorDiags(err, "error getting snapshot", nil)
+	}
+
+	dataset, name, err := parseSnapshotID(resp.ID)
+
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.Set("snapshot_id", resp.ID)
+	d.Set("dataset", dataset)
+	d.Set("name", name)
+
+	if used := resp.rawProperty("used"); used != "" {
+		sz, err := strconv.Atoi(used)
+
+		if err != nil {
+			return diag.Errorf("error parsing used: %s", err)
+		}
+
+		d.Set("used_bytes", sz)
+	}
+
+	if referenced := resp.rawProperty("referenced"); referenced != "" {
+		sz, err := strconv.Atoi(referenced)
+
+		if err != nil {
+			return diag.Errorf("error parsing referenced: %s", err)
+		}
+
+		d.Set("referenced_bytes", sz)
+	}
+
+	if creation := resp.rawProperty("creation"); creation != "" {
+		ts, err := strconv.ParseInt(creation, 10, 64)
+
+		if err != nil {
+			return diag.Errorf("error parsing creation: %s", err)
+		}
+
+		d.Set("creation_time", time.Unix(ts, 0).UTC().Format(time.RFC3339))
+	}
+
+	return diags
+}
+
+// resourceTrueNASSnapshotUpdate only handles delete options, everything else forces new snapshot
+func resourceTrueNASSnapshotUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	return resourceTrueNASSnapshotRead(ctx, d, m)
+}
+
+func resourceTrueNASSnapshotDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	c := m.(*Client)
+	id := d.Id()
+
+	options := map[string]interface{}{
+		"defer":     d.Get("defer_delete").(bool),
+		"recursive": d.Get("recursive").(bool),
+	}
+
+	log.Printf("[DEBUG] Deleting TrueNAS snapshot: %s", id)
+
+	if err := c.invoke(ctx, "zfs.snapshot.delete", []interface{}{id, options}, nil); err != nil {
+		return apiErrorDiags(err, "error deleting snapshot", nil)
+	}
+
+	log.Printf("[INFO] TrueNAS snapshot (%s) deleted", id)
+	d.SetId("")
+
+	return diags
+}
+
+// resourceTrueNASSnapshotImport validates snapshot ID and sets options that can not be read back to defaults
+func resourceTrueNASSnapshotImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	if _, _, err := parseSnapshotID(d.Id()); err != nil {
+		return nil, err
+	}
+
+	d.Set("recursive", false)
+	d.Set("vmware_sync", false)
+	d.Set("defer_delete", false)
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,144 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestAccResourceTruenasSnapshot_basic(t *testing.T) {
+	suffix := acctest.RandStringFromCharSet(5, acctest.CharSetAlphaNum)
+	datasetName := fmt.Sprintf("%s-%s", testResourcePrefix, suffix)
+	resourceName := "truenas_snapshot.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckResourceTruenasSnapshotDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCheckResourceTruenasSnapshotConfig(testPoolName, datasetName),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", fmt.Sprintf("%s/%s@checkpoint", testPoolName, datasetName)),
+					resource.TestCheckResourceAttr(resourceName, "dataset", fmt.Sprintf("%s/%s", testPoolName, datasetName)),
+					resource.TestCheckResourceAttr(resourceName, "name", "checkpoint"),
+					resource.TestCheckResourceAttrSet(resourceName, "referenced_bytes"),
+					resource.TestCheckResourceAttrSet(resourceName, "creation_time"),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasSnapshot_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_snapshot.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(
+			testAccCheckFakeDestroy(f, "truenas_snapshot", "zfs/snapshot"),
+			testAccCheckFakeDestroy(f, "truenas_dataset", "pool/dataset"),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasSnapshotConfig(fakePoolName, "data"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/data@checkpoint"),
+					resource.TestCheckResourceAttr(resourceName, "snapshot_id", "Tank/data@checkpoint"),
+					resource.TestCheckResourceAttr(resourceName, "dataset", "Tank/data"),
+					resource.TestCheckResourceAttr(resourceName, "name", "checkpoint"),
+					resource.TestCheckResourceAttr(resourceName, "used_bytes", "0"),
+					resource.TestCheckResourceAttr(resourceName, "referenced_bytes", "98304"),
+					resource.TestMatchResourceAttr(resourceName, "creation_time", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T`)),
+				),
+			},
+			{
+				Config: f.providerConfig() + testAccCheckResourceTruenasSnapshotConfig(fakePoolName, "data") + `
+				resource "truenas_snapshot" "recursive" {
+					dataset = truenas_dataset.test.id
+					name = "recursive"
+					recursive = true
+					defer_delete = true
+				}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("truenas_snapshot.recursive", "recursive", "true"),
+					resource.TestCheckResourceAttr("truenas_snapshot.recursive", "defer_delete", "true"),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				ResourceName:  resourceName,
+				ImportState:   true,
+				ImportStateId: "Tank/data",
+				ExpectError:   regexp.MustCompile("expected format: pool/dataset@name"),
+			},
+		},
+	})
+}
+
+func Test_parseSnapshotID(t *testing.T) {
+	dataset, name, err := parseSnapshotID("Tank/parent/data@auto-2022-12-01")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Tank/parent/data", dataset)
+	assert.Equal(t, "auto-2022-12-01", name)
+
+	for _, id := range []string{"Tank/data", "@snap", "Tank/data@"} {
+		_, _, err := parseSnapshotID(id)
+		assert.Error(t, err, id)
+	}
+}
+
+func testAccCheckResourceTruenasSnapshotConfig(pool string, datasetName string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "test" {
+		name = "%s"
+		pool = "%s"
+	}
+
+	resource "truenas_snapshot" "test" {
+		dataset = truenas_dataset.test.id
+		name = "checkpoint"
+	}
+	`, datasetName, pool)
+}
+
+func testAccCheckResourceTruenasSnapshotDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*Client)
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "truenas_snapshot" {
+			continue
+		}
+
+		err := client.invoke(context.Background(), "zfs.snapshot.get_instance", []interface{}{rs.Primary.ID}, nil)
+
+		if err == nil {
+			return fmt.Errorf("snapshot (%s) still exists", rs.Primary.ID)
+		}
+
+		if !isNotFoundError(nil, err) {
+			return fmt.Errorf("Error occured while checking for absence of snapshot (%s): %s", rs.Primary.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -6,14 +6,30 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"log"
 	"strconv"
 )
 
+var vmAPIAttrs = apiAttrMap{
+	"name":             "name",
+	"description":      "description",
+	"bootloader":       "bootloader",
+	"autostart":        "autostart",
+	"time":             "time",
+	"shutdown_timeout": "shutdown_timeout",
+	"vcpus":            "vcpus",
+	"cores":            "cores",
+	"threads":          "threads",
+	"memory":           "memory",
+	"devices":          "device",
+}
+
 func resourceTrueNASVM() *schema.Resource {
 	return &schema.Resource{
 		ReadContext:   resourceTrueNASVMRead,
 		CreateContext: resourceTrueNASVMCreate,
 		DeleteContext: resourceTrueNASVMDelete,
+		CustomizeDiff: resourceTrueNASVMCustomizeDiff,
 		UpdateContext: resourceTrueNASVMUpdate,
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
@@ -145,8 +161,23 @@ func resourceTrueNASVM() *schema.Resource {
 	}
 }
 
+// resourceTrueNASVMCustomizeDiff validates attributes against capabilities of connected system
+func resourceTrueNASVMCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	system := systemFromMeta(m)
+
+	if system == nil {
+		return nil
+	}
+
+	if bootloader := d.Get("bootloader").(string); bootloader == "GRUB" && system.isSCALE() {
+		return unsupportedError(system, "bootloader", bootloader, "GRUB bootloader is only available on TrueNAS CORE")
+	}
+
+	return nil
+}
+
 func resourceTrueNASVMRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 
 	id, err := strconv.Atoi(d.Id())
 
@@ -154,10 +185,16 @@ func resourceTrueNASVMRead(ctx context.Context, d *schema.ResourceData, m interf
 		return diag.FromErr(err)
 	}
 
-	resp, _, err := c.VmApi.GetVM(ctx, int32(id)).Execute()
+	var resp api.VM
 
-	if err != nil {
-		return diag.Errorf("error getting VM: %s", err)
+	if err := c.invoke(ctx, "vm.get_instance", []interface{}{id}, &resp); err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS VM (%d) not found, removing from state", id)
+			d.SetId("")
+			return nil
+		}
+		return apiErrorDiags(err, "error getting VM", nil)
 	}
 
 	d.Set("name", resp.Name)
@@ -215,7 +252,7 @@ func resourceTrueNASVMRead(ctx context.Context, d *schema.ResourceData, m interf
 }
 
 func resourceTrueNASVMCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 
 	input := api.CreateVMParams{
 		Name: getStringPtr(d.Get("name").(string)),
@@ -267,14 +304,10 @@ func resourceTrueNASVMCreate(ctx context.Context, d *schema.ResourceData, m inte
 		input.Devices = dv
 	}
 
-	resp, _, err := c.VmApi.CreateVM(ctx).CreateVMParams(input).Execute()
+	var resp api.VM
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error creating VM: %s\n%s", err, body)
+	if err := c.invoke(ctx, "vm.create", []interface{}{input}, &resp); err != nil {
+		return apiErrorDiags(err, "error creating VM", vmAPIAttrs)
 	}
 
 	d.SetId(strconv.Itoa(int(resp.Id)))
@@ -282,7 +315,7 @@ func resourceTrueNASVMCreate(ctx context.Context, d *schema.ResourceData, m inte
 }
 
 func resourceTrueNASVMDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 
 	id, err := strconv.Atoi(d.Id())
 
@@ -290,15 +323,8 @@ func resourceTrueNASVMDelete(ctx context.Context, d *schema.ResourceData, m inte
 		return diag.FromErr(err)
 	}
 
-	_, err = c.VmApi.DeleteVM(ctx, int32(id)).Execute()
-
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-
-		return diag.Errorf("error deleting VM: %s\n%s", err, body)
+	if err := c.invokeOptionalJob(ctx, "vm.delete", []interface{}{id}); err != nil {
+		return apiErrorDiags(err, "error deleting VM", nil)
 	}
 
 	d.SetId("")
@@ -307,7 +333,7 @@ func resourceTrueNASVMDelete(ctx context.Context, d *schema.ResourceData, m inte
 }
 
 func resourceTrueNASVMUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 
 	id, err := strconv.Atoi(d.Id())
 
@@ -363,25 +389,8 @@ func resourceTrueNASVMUpdate(ctx context.Context, d *schema.ResourceData, m inte
 		}
 	}
 
-	_, _, err = c.VmApi.UpdateVM(ctx, int32(id)).UpdateVMParams(input).Execute()
-
-	// TODO: handle error response like:
-	//{{
-	//	"vm_update.name": [
-	//{
-	//"message": "Only alphanumeric characters are allowed.",
-	//"errno": 22
-	//}
-	//]
-	//}}
-
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-
-		return diag.Errorf("error updating VM: %s\n%s", err, body)
+	if err := c.invoke(ctx, "vm.update", []interface{}{id, input}, nil); err != nil {
+		return apiErrorDiags(err, "error updating VM", vmAPIAttrs)
 	}
 
 	return resourceTrueNASVMRead(ctx, d, m)
@@ -0,0 +1,64 @@
+package truenas
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"testing"
+)
+
+func TestUnitResourceTruenasVM_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_vm.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_vm", "vm"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasVMConfig("Test VM", 2),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "name", "test"),
+					resource.TestCheckResourceAttr(resourceName, "description", "Test VM"),
+					resource.TestCheckResourceAttr(resourceName, "vcpus", "2"),
+					resource.TestCheckResourceAttr(resourceName, "bootloader", "UEFI"),
+					resource.TestCheckResourceAttr(resourceName, "device.#", "1"),
+					resource.TestCheckResourceAttr(resourceName, "status.0.state", "STOPPED"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasVMConfig("Updated VM", 4),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "description", "Updated VM"),
+					resource.TestCheckResourceAttr(resourceName, "vcpus", "4"),
+				),
+			},
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func testUnitResourceTruenasVMConfig(description string, vcpus int) string {
+	return fmt.Sprintf(`
+	resource "truenas_vm" "test" {
+		name = "test"
+		description = "%s"
+		vcpus = %d
+		memory = 1073741824
+
+		device {
+			type = "NIC"
+			attributes = {
+				type = "VIRTIO"
+				mac = "00:a0:98:39:5b:78"
+				nic_attach = "br0"
+			}
+		}
+	}
+	`, description, vcpus)
+}
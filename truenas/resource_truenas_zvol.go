@@ -7,10 +7,31 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"log"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
+var zvolAPIAttrs = apiAttrMap{
+	"name":                           "name",
+	"new_name":                       "name",
+	"comments":                       "comments",
+	"compression":                    "compression",
+	"deduplication":                  "deduplication",
+	"encryption_options.algorithm":   "encryption_algorithm",
+	"encryption_options.key":         "encryption_key",
+	"encryption_options.passphrase":  "passphrase",
+	"encryption_options.pbkdf2iters": "pbkdf2iters",
+	"force_size":                     "force_size",
+	"inherit_encryption":             "inherit_encryption",
+	"readonly":                       "readonly",
+	"sync":                           "sync",
+	"user_properties":                "user_properties",
+	"user_properties_update":         "user_properties",
+	"volblocksize":                   "blocksize",
+	"volsize":                        "volsize",
+}
+
 func resourceTrueNASZVOL() *schema.Resource {
 	return &schema.Resource{
 		Description:   "Manage ZFS Volume (zvol), use of TF `prevent_destroy` (https://www.terraform.io/docs/language/meta-arguments/lifecycle.html#prevent_destroy) flag is recommended to avoid accidental deletion",
@@ -18,6 +39,7 @@ func resourceTrueNASZVOL() *schema.Resource {
 		ReadContext:   resourceTrueNASZVOLRead,
 		UpdateContext: resourceTrueNASZVOLUpdate,
 		DeleteContext: resourceTrueNASZVOLDelete,
+		CustomizeDiff: resourceTrueNASZVOLCustomizeDiff,
 		Importer: &schema.ResourceImporter{
 			StateContext: schema.ImportStatePassthroughContext,
 		},
@@ -33,6 +55,18 @@ func resourceTrueNASZVOL() *schema.Resource {
 				ForceNew:     true,
 				ValidateFunc: validation.StringInSlice([]string{"4K", "8K", "16K", "32K", "64K", "128K"}, false),
 				Default:      "32K",
+				// clones keep blocksize of origin
+				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+					return d.Get("clone_from_snapshot").(string) != "" && d.Id() != ""
+				},
+			},
+			"clone_from_snapshot": &schema.Schema{
+				Description:   "Create zvol as a clone of snapshot, eg. Tank/vol@base. Blocksize and encryption are inherited from the snapshot",
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"encrypted", "encryption_algorithm", "encryption_key", "generate_key", "passphrase"},
+				ValidateFunc:  validation.StringMatch(regexp.MustCompile("^[^@]+@[^@]+$"), "snapshot must be in format pool/dataset@name"),
 			},
 			"comments": &schema.Schema{
 				Description: "Any notes about this volume.",
@@ -45,6 +79,13 @@ func resourceTrueNASZVOL() *schema.Resource {
 				Required:     true,
 				ValidateFunc: validation.StringInSlice(supportedCompression, false),
 			},
+			"checksum": &schema.Schema{
+				Description:  "Checksum algorithm used to verify data integrity",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(checksumAlgorithms), false),
+			},
 			"copies": &schema.Schema{
 				Type:     schema.TypeInt,
 				Computed: true,
@@ -58,8 +99,19 @@ func resourceTrueNASZVOL() *schema.Resource {
 			},
 			"encrypted": &schema.Schema{
 				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
 				Computed: true,
 			},
+			"encryption_key": &schema.Schema{
+				Description:   "Hex encoded encryption key, changing it changes the key of existing zvol in place",
+				Type:          schema.TypeString,
+				ConflictsWith: []string{"passphrase"},
+				ValidateFunc:  validation.StringMatch(regexp.MustCompile("^[a-fA-F0-9]+$"), "key must be in hexadecimal format"),
+				Optional:      true,
+				Computed:      true,
+				Sensitive:     true,
+			},
 			"encryption_algorithm": &schema.Schema{
 				Type:         schema.TypeString,
 				Optional:     true,
@@ -77,6 +129,12 @@ func resourceTrueNASZVOL() *schema.Resource {
 				Optional:    true,
 				Default:     false,
 			},
+			"generate_key": &schema.Schema{
+				Description: "Generate encryption key, switching it to true on existing zvol replaces its key with a generated one, switching it off keeps current key",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+			},
 			"key_format": &schema.Schema{
 				Type:     schema.TypeString,
 				Computed: true,
@@ -86,32 +144,56 @@ func resourceTrueNASZVOL() *schema.Resource {
 				Computed: true,
 			},
 			"locked": &schema.Schema{
-				Type:     schema.TypeBool,
-				Computed: true,
+				Description: "Lock or unlock passphrase encrypted zvol, `passphrase` is used to unlock it. Only encryption roots can be locked",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+			},
+			"origin": &schema.Schema{
+				Description: "Snapshot the zvol was cloned from, empty once clone is promoted",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"promote": &schema.Schema{
+				Description:  "Promote clone, so that it no longer depends on origin snapshot. Origin zvol becomes a clone of the promoted zvol, promotion can not be reverted",
+				Type:         schema.TypeBool,
+				Optional:     true,
+				RequiredWith: []string{"clone_from_snapshot"},
+			},
+			"log_bias": &schema.Schema{
+				Description:  "Synchronous write handling: `latency` uses log devices, `throughput` writes to pool directly",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(logBiasModes), false),
 			},
 			"name": &schema.Schema{
-				Description:  "Unique identifier for the volume. Cannot be changed after the zvol is created.",
+				Description:  "Volume name, changing it renames the zvol in place",
 				Type:         schema.TypeString,
 				Required:     true,
 				ValidateFunc: validation.StringDoesNotContainAny("/"),
-				ForceNew:     true,
 			},
 			"inherit_encryption": &schema.Schema{
 				Type:        schema.TypeBool,
-				Description: "Use the encryption properties of the root dataset.",
-				ForceNew:    true,
+				Description: "Use the encryption properties of the root dataset. Setting it to `false` on existing zvol makes it encryption root with its own key or passphrase",
 				Optional:    true,
 				Default:     false,
 			},
 			"parent": &schema.Schema{
-				Description: "Parent dataset",
+				Description: "Parent dataset, changing it moves the zvol",
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     "",
-				ForceNew:    true,
+			},
+			"passphrase": &schema.Schema{
+				Description: "Encryption passphrase, changing it changes the key of existing zvol in place",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
 			},
 			"pbkdf2iters": &schema.Schema{
 				Type:     schema.TypeInt,
+				Optional: true,
 				Computed: true,
 			},
 			"pool": &schema.Schema{
@@ -120,6 +202,21 @@ func resourceTrueNASZVOL() *schema.Resource {
 				ValidateFunc: validation.StringDoesNotContainAny("/"),
 				ForceNew:     true,
 			},
+			"primary_cache": &schema.Schema{
+				Description:  "What is cached in ARC: `all`, `metadata` or `none`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(cacheModes), false),
+			},
+			"property_sources": &schema.Schema{
+				Description: "Source of ZFS properties keyed by property name: `local`, `inherited` or `default`",
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 			"readonly": &schema.Schema{
 				Type:         schema.TypeString,
 				Description:  "Set to prevent the zvol from being modified",
@@ -135,6 +232,20 @@ func resourceTrueNASZVOL() *schema.Resource {
 				Type:     schema.TypeInt,
 				Computed: true,
 			},
+			"secondary_cache": &schema.Schema{
+				Description:  "What is cached in L2ARC: `all`, `metadata` or `none`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(cacheModes), false),
+			},
+			"snap_dev": &schema.Schema{
+				Description:  "Controls whether snapshot devices of the volume are `hidden` or `visible`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(snapDevModes), false),
+			},
 			"sync": &schema.Schema{
 				Type:         schema.TypeString,
 				Description:  "Sets the data write synchronization. `inherit` takes the sync settings from the parent dataset, `standard` uses the settings that have been requested by the client software, `always` waits for data writes to complete, and `disabled` never waits for writes to complete.",
@@ -142,6 +253,22 @@ func resourceTrueNASZVOL() *schema.Resource {
 				Default:      "standard",
 				ValidateFunc: validation.StringInSlice([]string{"always", "standard", "disabled", "inherit"}, false),
 			},
+			"user_properties": &schema.Schema{
+				Description:  "ZFS user properties, eg. `org:owner`. Only properties declared here are managed, removing property from the map removes it from the zvol. Declared properties are always set locally, even if parent dataset has the same value",
+				Type:         schema.TypeMap,
+				Optional:     true,
+				ValidateFunc: validateUserProperties,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"vol_mode": &schema.Schema{
+				Description:  "How the volume is exposed to the OS: `default`, `full`, `geom`, `dev` or `none`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(withInherit(volModes), false),
+			},
 			"volsize": &schema.Schema{
 				Description: "Volume size in bytes, should be multiples of block size",
 				Type:        schema.TypeInt,
@@ -154,13 +281,19 @@ func resourceTrueNASZVOL() *schema.Resource {
 func resourceTrueNASZVOLRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id := d.Id()
 
-	resp, _, err := c.DatasetApi.GetDataset(ctx, id).Execute()
+	var resp api.Dataset
 
-	if err != nil {
-		return diag.Errorf("error getting zvol: %s", err)
+	if err := c.invoke(ctx, "pool.dataset.get_instance", []interface{}{id}, &resp); err != nil {
+		// gracefully handle manual deletions
+		if isNotFoundError(nil, err) {
+			log.Printf("[WARN] TrueNAS zvol (%s) not found, removing from state", id)
+			d.SetId("")
+			return nil
+		}
+		return apiErrorDiags(err, "error getting zvol", nil)
 	}
 
 	if resp.Type != "VOLUME" {
@@ -181,6 +314,10 @@ func resourceTrueNASZVOLRead(ctx context.Context, d *schema.ResourceData, m inte
 		d.Set("encryption_root", resp.EncryptionRoot)
 	}
 
+	if resp.Origin != nil {
+		d.Set("origin", derefString(resp.Origin.Value))
+	}
+
 	if resp.KeyLoaded != nil {
 		d.Set("key_loaded", resp.KeyLoaded)
 	}
@@ -237,7 +374,7 @@ func resourceTrueNASZVOLRead(ctx context.Context, d *schema.ResourceData, m inte
 	}
 
 	if resp.Readonly != nil {
-		d.Set("readonly", strings.ToLower(*resp.Readonly.Value))
+		setInheritableProperty(d, "readonly", resp.Readonly, strings.ToLower(*resp.Readonly.Value))
 	}
 
 	if resp.EncryptionAlgorithm != nil && resp.EncryptionAlgorithm.Value != nil {
@@ -274,45 +411,95 @@ func resourceTrueNASZVOLRead(ctx context.Context, d *schema.ResourceData, m inte
 
 	d.Set("zvol_id", id)
 
+	flattenDatasetProperties(d, zvolExtraProperties, &resp)
+	flattenUserProperties(d, &resp)
+
 	return diags
 }
 
 func resourceTrueNASZVOLCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 
 	input := expandZvol(d)
 
-	resp, _, err := c.DatasetApi.CreateDataset(ctx).CreateDatasetParams(input).Execute()
+	if snapshot, ok := d.GetOk("clone_from_snapshot"); ok {
+		return resourceTrueNASZVOLClone(ctx, d, m, snapshot.(string), input)
+	}
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error creating zvol: %s\n%s", err, body)
+	var resp api.Dataset
+
+	if err := c.invoke(ctx, "pool.dataset.create", []interface{}{input}, &resp); err != nil {
+		return apiErrorDiags(err, "error creating zvol", zvolAPIAttrs)
 	}
 
 	d.SetId(resp.Id)
 
+	if d.Get("locked").(bool) {
+		if err := lockDataset(ctx, c, resp.Id); err != nil {
+			return apiErrorDiags(err, "error locking zvol", nil)
+		}
+	}
+
+	return resourceTrueNASZVOLRead(ctx, d, m)
+}
+
+// resourceTrueNASZVOLClone clones snapshot and sets properties that can be changed on existing zvol
+func resourceTrueNASZVOLClone(ctx context.Context, d *schema.ResourceData, m interface{}, snapshot string, input api.CreateDatasetParams) diag.Diagnostics {
+	c := m.(*Client)
+
+	if err := cloneSnapshot(ctx, c, snapshot, input.Name); err != nil {
+		return apiErrorDiags(err, "error cloning snapshot", zvolAPIAttrs)
+	}
+
+	d.SetId(input.Name)
+
+	update := api.UpdateDatasetParams{
+		Comments:      input.Comments,
+		Compression:   input.Compression,
+		Deduplication: input.Deduplication,
+		ForceSize:     input.ForceSize,
+		Readonly:      input.Readonly,
+		Sync:          input.Sync,
+		Volsize:       input.Volsize,
+
+		AdditionalProperties: input.AdditionalProperties,
+	}
+
+	// update takes user properties as user_properties_update, entries have the same format
+	if props, ok := update.AdditionalProperties["user_properties"]; ok {
+		delete(update.AdditionalProperties, "user_properties")
+		update.AdditionalProperties["user_properties_update"] = props
+	}
+
+	if err := c.invoke(ctx, "pool.dataset.update", []interface{}{input.Name, update}, nil); err != nil {
+		return apiErrorDiags(err, "error updating zvol clone", zvolAPIAttrs)
+	}
+
+	if d.Get("promote").(bool) {
+		if err := promoteDataset(ctx, c, input.Name); err != nil {
+			return apiErrorDiags(err, "error promoting zvol", nil)
+		}
+	}
+
+	if d.Get("locked").(bool) {
+		if err := lockDataset(ctx, c, input.Name); err != nil {
+			return apiErrorDiags(err, "error locking zvol", nil)
+		}
+	}
+
 	return resourceTrueNASZVOLRead(ctx, d, m)
 }
 
 func resourceTrueNASZVOLDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	c := m.(*api.APIClient)
+	c := m.(*Client)
 	id := d.Id()
 
 	log.Printf("[DEBUG] Deleting TrueNAS zvol: %s", id)
 
-	_, err := c.DatasetApi.DeleteDataset(ctx, id).Execute()
-
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error deleting dataset: %s\n%s", err, body)
+	if err := c.invokeOptionalJob(ctx, "pool.dataset.delete", []interface{}{id}); err != nil {
+		return apiErrorDiags(err, "error deleting dataset", nil)
 	}
 
 	log.Printf("[INFO] TrueNAS zvol (%s) deleted", id)
@@ -321,9 +508,52 @@ func resourceTrueNASZVOLDelete(ctx context.Context, d *schema.ResourceData, m in
 	return diags
 }
 
+// resourceTrueNASZVOLUpdate unlocks zvol first, so that its key and properties can be changed, and locks it last
 func resourceTrueNASZVOLUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	c := m.(*api.APIClient)
+	c := m.(*Client)
+
+	if d.HasChanges("parent", "name") {
+		newID := datasetPath{
+			Pool:   d.Get("pool").(string),
+			Parent: d.Get("parent").(string),
+			Name:   d.Get("name").(string),
+		}.String()
+
+		if err := renameDataset(ctx, c, d.Id(), newID); err != nil {
+			return apiErrorDiags(err, "error renaming zvol", zvolAPIAttrs)
+		}
+
+		d.SetId(newID)
+	}
 
+	// clone can not be demoted, so only promote = true is applied
+	if d.HasChange("promote") && d.Get("promote").(bool) {
+		if err := promoteDataset(ctx, c, d.Id()); err != nil {
+			return apiErrorDiags(err, "error promoting zvol", nil)
+		}
+	}
+
+	if diags := updateDatasetEncryption(ctx, c, d, zvolAPIAttrs); diags.HasError() {
+		return diags
+	}
+
+	if d.HasChangesExcept(append([]string{"locked", "parent", "name", "promote"}, datasetKeyAttrs...)...) {
+		if diags := updateZvolProperties(ctx, c, d); diags.HasError() {
+			return diags
+		}
+	}
+
+	if d.HasChange("locked") && d.Get("locked").(bool) {
+		if err := lockDataset(ctx, c, d.Id()); err != nil {
+			return apiErrorDiags(err, "error locking zvol", nil)
+		}
+	}
+
+	return resourceTrueNASZVOLRead(ctx, d, m)
+}
+
+// updateZvolProperties updates changed zvol properties, except name and encryption
+func updateZvolProperties(ctx context.Context, c *Client, d *schema.ResourceData) diag.Diagnostics {
 	input := api.UpdateDatasetParams{}
 
 	if d.HasChange("comments") {
@@ -362,17 +592,27 @@ func resourceTrueNASZVOLUpdate(ctx context.Context, d *schema.ResourceData, m in
 		input.ForceSize = getBoolPtr(d.Get("force_size").(bool))
 	}
 
-	_, _, err := c.DatasetApi.UpdateDataset(ctx, d.Id()).UpdateDatasetParams(input).Execute()
+	input.AdditionalProperties = expandDatasetProperties(d, zvolExtraProperties, true)
 
-	if err != nil {
-		var body []byte
-		if apiErr, ok := err.(*api.GenericOpenAPIError); ok {
-			body = apiErr.Body()
-		}
-		return diag.Errorf("error updating zvol: %s\n%s", err, body)
+	if props := expandUserPropertiesUpdate(d); len(props) > 0 {
+		input.AdditionalProperties["user_properties_update"] = props
 	}
 
-	return resourceTrueNASZVOLRead(ctx, d, m)
+	if err := c.invoke(ctx, "pool.dataset.update", []interface{}{d.Id(), input}, nil); err != nil {
+		return apiErrorDiags(err, "error updating zvol", zvolAPIAttrs)
+	}
+
+	return nil
+}
+
+// resourceTrueNASZVOLCustomizeDiff validates encryption changes, that depend on zvol lock state, and plans
+// new zvol_id of renamed zvol
+func resourceTrueNASZVOLCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if err := customizeDiffDatasetEncryption(d); err != nil {
+		return err
+	}
+
+	return customizeDiffRenamed(d, "zvol_id", "encryption_root")
 }
 
 func expandZvol(d *schema.ResourceData) api.CreateDatasetParams {
@@ -403,6 +643,11 @@ func expandZvol(d *schema.ResourceData) api.CreateDatasetParams {
 		input.ForceSize = getBoolPtr(forceSize.(bool))
 	}
 
+	if encrypted, ok := d.GetOk("encrypted"); ok {
+		input.Encryption = getBoolPtr(encrypted.(bool))
+		input.EncryptionOptions = expandDatasetEncryptionOptions(d)
+	}
+
 	if inheritEncryption, ok := d.GetOk("inherit_encryption"); ok {
 		input.InheritEncryption = getBoolPtr(inheritEncryption.(bool))
 	}
@@ -423,5 +668,11 @@ func expandZvol(d *schema.ResourceData) api.CreateDatasetParams {
 		input.Volblocksize = getStringPtr(blockSize.(string))
 	}
 
+	input.AdditionalProperties = expandDatasetProperties(d, zvolExtraProperties, false)
+
+	if props := expandUserProperties(d); len(props) > 0 {
+		input.AdditionalProperties["user_properties"] = props
+	}
+
 	return input
 }
@@ -0,0 +1,369 @@
+package truenas
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"regexp"
+	"testing"
+)
+
+func TestUnitResourceTruenasZVOL_basic(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_zvol.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_zvol", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLConfig(fakePoolName, 1073741824),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/vol"),
+					resource.TestCheckResourceAttr(resourceName, "zvol_id", "Tank/vol"),
+					resource.TestCheckResourceAttr(resourceName, "volsize", "1073741824"),
+					resource.TestCheckResourceAttr(resourceName, "blocksize", "32K"),
+					resource.TestCheckResourceAttr(resourceName, "compression", "lz4"),
+					resource.TestCheckResourceAttr(resourceName, "copies", "1"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLConfig(fakePoolName, 2147483648),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "volsize", "2147483648"),
+				),
+			},
+			{
+				ResourceName:            resourceName,
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"force_size", "inherit_encryption"},
+			},
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasZVOLConfig(fakePoolName, 1073741824),
+				ExpectError: regexp.MustCompile("zvol volume size can only be increased"),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasZVOL_rename(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_zvol.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_zvol", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLRenameConfig("", "vol"),
+				Check:  resource.TestCheckResourceAttr(resourceName, "id", "Tank/vol"),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLRenameConfig("vms", "disk0"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/vms/disk0"),
+					resource.TestCheckResourceAttr(resourceName, "zvol_id", "Tank/vms/disk0"),
+					resource.TestCheckResourceAttr(resourceName, "parent", "vms"),
+					resource.TestCheckResourceAttr(resourceName, "volsize", "1073741824"),
+				),
+			},
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasZVOLRenameConfig("missing", "disk0"),
+				ExpectError: regexp.MustCompile("Tank/missing does not exist"),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasZVOL_clone(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_zvol.test"
+
+	testFakeCloneOrigin(t, f, map[string]interface{}{"name": "Tank/golden", "type": "VOLUME", "volsize": 1073741824, "volblocksize": "16K"}, "base")
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_zvol", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLCloneConfig(2147483648, ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "id", "Tank/vm0"),
+					resource.TestCheckResourceAttr(resourceName, "origin", "Tank/golden@base"),
+					resource.TestCheckResourceAttr(resourceName, "volsize", "2147483648"),
+					resource.TestCheckResourceAttr(resourceName, "blocksize", "16K"),
+					resource.TestCheckResourceAttr(resourceName, "comments", "Cloned zvol"),
+				),
+			},
+			{
+				Config:      f.providerConfig() + testUnitResourceTruenasZVOLCloneConfig(2147483648, `encryption_algorithm = "AES-256-GCM"`),
+				ExpectError: regexp.MustCompile(`"clone_from_snapshot": conflicts with encryption_algorithm`),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLCloneConfig(2147483648, "promote = true"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "origin", ""),
+					testAccCheckFakeSnapshotExists(f, "Tank/vm0@base"),
+				),
+			},
+			{
+				// promoted clone can only be destroyed once former origin zvol is gone
+				PreConfig: func() {
+					f.mu.Lock()
+					defer f.mu.Unlock()
+
+					delete(f.datasets, "Tank/golden")
+					delete(f.snapshots, "Tank/vm0@base")
+				},
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLCloneConfig(2147483648, "promote = true"),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasZVOL_properties(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_zvol.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_zvol", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLPropertiesConfig(`
+					checksum = "blake3"
+					log_bias = "throughput"
+					primary_cache = "metadata"
+					secondary_cache = "inherit"
+					snap_dev = "visible"
+					vol_mode = "dev"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "checksum", "blake3"),
+					resource.TestCheckResourceAttr(resourceName, "log_bias", "throughput"),
+					resource.TestCheckResourceAttr(resourceName, "primary_cache", "metadata"),
+					resource.TestCheckResourceAttr(resourceName, "secondary_cache", "inherit"),
+					resource.TestCheckResourceAttr(resourceName, "snap_dev", "visible"),
+					resource.TestCheckResourceAttr(resourceName, "vol_mode", "dev"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.volmode", "local"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.secondarycache", "default"),
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLPropertiesConfig(`
+					checksum = "blake3"
+					log_bias = "inherit"
+					primary_cache = "metadata"
+					secondary_cache = "all"
+					snap_dev = "hidden"
+					vol_mode = "inherit"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "log_bias", "inherit"),
+					resource.TestCheckResourceAttr(resourceName, "secondary_cache", "all"),
+					resource.TestCheckResourceAttr(resourceName, "snap_dev", "hidden"),
+					resource.TestCheckResourceAttr(resourceName, "vol_mode", "inherit"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.logbias", "default"),
+					resource.TestCheckResourceAttr(resourceName, "property_sources.secondarycache", "local"),
+					testAccCheckFakeDatasetProperty(f, "Tank/vol", "volmode", "DEFAULT"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasZVOL_userProperties(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_zvol.test"
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_zvol", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLPropertiesConfig(`
+					user_properties = {
+						"org:owner" = "ops"
+					}
+				`),
+				Check: resource.TestCheckResourceAttr(resourceName, "user_properties.org:owner", "ops"),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLPropertiesConfig(`
+					user_properties = {
+						"org:backup-tier" = "silver"
+					}
+				`) + `
+				data "truenas_zvol" "test" {
+					zvol_id = truenas_zvol.test.id
+					depends_on = [truenas_zvol.test]
+				}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "user_properties.%", "1"),
+					resource.TestCheckResourceAttr(resourceName, "user_properties.org:backup-tier", "silver"),
+					resource.TestCheckResourceAttr("data.truenas_zvol.test", "user_properties.%", "1"),
+					resource.TestCheckResourceAttr("data.truenas_zvol.test", "user_properties.org:backup-tier", "silver"),
+				),
+			},
+		},
+	})
+}
+
+func TestUnitResourceTruenasZVOL_encryption(t *testing.T) {
+	testFakePreCheck(t)
+
+	f := newFakeTrueNAS(t)
+	resourceName := "truenas_zvol.test"
+
+	var original *fakeDataset
+
+	// key changes must not replace the zvol, otherwise its data is lost
+	checkNotReplaced := func(s *terraform.State) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if f.datasets["Tank/vol"] != original {
+			return fmt.Errorf("zvol Tank/vol was replaced")
+		}
+
+		return nil
+	}
+
+	resource.UnitTest(t, resource.TestCase{
+		ProviderFactories: testFakeProviderFactories(),
+		CheckDestroy:      testAccCheckFakeDestroy(f, "truenas_zvol", "pool/dataset"),
+		Steps: []resource.TestStep{
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLPropertiesConfig(`
+					encrypted = true
+					passphrase = "first-passphrase"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "encrypted", "true"),
+					resource.TestCheckResourceAttr(resourceName, "encryption_root", "Tank/vol"),
+					resource.TestCheckResourceAttr(resourceName, "key_format", "passphrase"),
+					resource.TestCheckResourceAttr(resourceName, "locked", "false"),
+					func(s *terraform.State) error {
+						f.mu.Lock()
+						defer f.mu.Unlock()
+
+						original = f.datasets["Tank/vol"]
+
+						return nil
+					},
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLPropertiesConfig(`
+					encrypted = true
+					passphrase = "second-passphrase"
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckFakeDatasetKey(f, "Tank/vol", "second-passphrase"),
+					checkNotReplaced,
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLPropertiesConfig(`
+					encrypted = true
+					passphrase = "second-passphrase"
+					locked = true
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "locked", "true"),
+					resource.TestCheckResourceAttr(resourceName, "key_loaded", "false"),
+					checkNotReplaced,
+				),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLPropertiesConfig(`
+					encrypted = true
+					passphrase = "third-passphrase"
+					locked = true
+				`),
+				ExpectError: regexp.MustCompile("dataset Tank/vol is locked, set locked = false to change passphrase"),
+			},
+			{
+				Config: f.providerConfig() + testUnitResourceTruenasZVOLPropertiesConfig(`
+					encrypted = true
+					passphrase = "third-passphrase"
+					locked = false
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(resourceName, "locked", "false"),
+					testAccCheckFakeDatasetKey(f, "Tank/vol", "third-passphrase"),
+					checkNotReplaced,
+				),
+			},
+		},
+	})
+}
+
+func testUnitResourceTruenasZVOLConfig(pool string, volsize int) string {
+	return fmt.Sprintf(`
+	resource "truenas_zvol" "test" {
+		name = "vol"
+		pool = "%s"
+		comments = "Test zvol"
+		compression = "lz4"
+		volsize = %d
+	}
+	`, pool, volsize)
+}
+
+func testUnitResourceTruenasZVOLRenameConfig(parent string, name string) string {
+	return fmt.Sprintf(`
+	resource "truenas_dataset" "vms" {
+		name = "vms"
+		pool = "Tank"
+	}
+
+	resource "truenas_zvol" "test" {
+		name = "%s"
+		pool = "Tank"
+		parent = "%s"
+		compression = "lz4"
+		volsize = 1073741824
+
+		depends_on = [truenas_dataset.vms]
+	}
+	`, name, parent)
+}
+
+func testUnitResourceTruenasZVOLCloneConfig(volsize int, options string) string {
+	return fmt.Sprintf(`
+	resource "truenas_zvol" "test" {
+		name = "vm0"
+		pool = "Tank"
+		comments = "Cloned zvol"
+		compression = "lz4"
+		volsize = %d
+		clone_from_snapshot = "Tank/golden@base"
+		%s
+	}
+	`, volsize, options)
+}
+
+func testUnitResourceTruenasZVOLPropertiesConfig(options string) string {
+	return fmt.Sprintf(`
+	resource "truenas_zvol" "test" {
+		name = "vol"
+		pool = "Tank"
+		compression = "lz4"
+		volsize = 1073741824
+		%s
+	}
+	`, options)
+}
@@ -0,0 +1,232 @@
+package truenas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// apiCaller calls TrueNAS middleware methods (eg. pool.snapshottask.create) with positional params,
+// it is implemented by both REST and websocket transports, so code using it works with either
+type apiCaller interface {
+	Call(ctx context.Context, method string, params []interface{}, out interface{}) error
+}
+
+// invoke calls middleware method using configured transport, result is decoded into out, unless out is nil
+func (c *Client) invoke(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	return c.caller.Call(ctx, method, params, out)
+}
+
+// restEndpoint describes middleware method that REST API v2.0 exposes in a way
+// that can not be derived from method name alone
+type restEndpoint struct {
+	// HTTPMethod overrides default HTTP method, that is GET for methods without params and POST otherwise
+	HTTPMethod string
+	// ItemMethod is set for methods operating on a single item, first param is item id
+	// and becomes part of the path, eg. POST /pool/dataset/id/{id}/set_quota
+	ItemMethod bool
+	// Query is set for methods accepting query-filters, eg. core.get_jobs
+	Query bool
+	// ArgNames are used to build request body for methods accepting more than one param
+	ArgNames []string
+}
+
+var restEndpoints = map[string]restEndpoint{
+	"auth.generate_token":                               {ArgNames: []string{"ttl", "attrs"}},
+	"core.get_jobs":                                     {Query: true},
+	"filesystem.getacl":                                 {ArgNames: []string{"path", "simplified"}},
+	"pool.dataset.change_key":                           {HTTPMethod: http.MethodPost, ItemMethod: true},
+	"pool.dataset.export_key":                           {HTTPMethod: http.MethodPost, ItemMethod: true},
+	"pool.dataset.get_quota":                            {HTTPMethod: http.MethodPost, ItemMethod: true, ArgNames: []string{"quota_type", "filters", "options"}},
+	"pool.dataset.inherit_parent_encryption_properties": {HTTPMethod: http.MethodPost, ItemMethod: true},
+	"pool.dataset.lock":                                 {HTTPMethod: http.MethodPost, ItemMethod: true},
+	"pool.dataset.permission":                           {HTTPMethod: http.MethodPost, ItemMethod: true},
+	"pool.dataset.promote":                              {HTTPMethod: http.MethodPost, ItemMethod: true},
+	"pool.dataset.rename":                               {HTTPMethod: http.MethodPost, ItemMethod: true},
+	"pool.dataset.set_quota":                            {HTTPMethod: http.MethodPost, ItemMethod: true},
+	"pool.dataset.unlock":                               {HTTPMethod: http.MethodPost, ItemMethod: true},
+	"pool.export":                                       {HTTPMethod: http.MethodPost, ItemMethod: true},
+	"replication.run":                                   {HTTPMethod: http.MethodPost, ItemMethod: true},
+	"zfs.snapshot.rollback":                             {ArgNames: []string{"id", "options"}},
+}
+
+// restCaller maps middleware methods to REST API v2.0 endpoints. CRUD methods follow REST
+// conventions, eg. pool.snapshottask.update(id, data) -> PUT /pool/snapshottask/id/{id},
+// other methods are called as POST /namespace/method
+type restCaller struct {
+	client *Client
+}
+
+func (r *restCaller) Call(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	httpMethod, path, body, err := restRequest(method, params)
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.call(ctx, httpMethod, path, body, out)
+
+	// REST API responds with 404 to missing items, websocket reports them with ENOENT
+	var e *apiError
+
+	if resp != nil && resp.StatusCode == http.StatusNotFound && errors.As(err, &e) && e.Errno == 0 {
+		e.Errno = errnoENOENT
+	}
+
+	return err
+}
+
+// restRequest builds REST request for middleware method call
+func restRequest(method string, params []interface{}) (string, string, interface{}, error) {
+	i := strings.LastIndex(method, ".")
+
+	if i < 0 {
+		return "", "", nil, fmt.Errorf("invalid method name: %s", method)
+	}
+
+	namespace := strings.ReplaceAll(method[:i], ".", "/")
+	name := method[i+1:]
+	endpoint, custom := restEndpoints[method]
+
+	itemPath := func() (string, error) {
+		if len(params) == 0 {
+			return "", fmt.Errorf("%s requires item id", method)
+		}
+
+		return fmt.Sprintf("%s/id/%s", namespace, url.PathEscape(fmt.Sprint(params[0]))), nil
+	}
+
+	if !custom {
+		switch name {
+		case "create":
+			return http.MethodPost, namespace, paramAt(params, 0), nil
+		case "update":
+			path, err := itemPath()
+			return http.MethodPut, path, paramAt(params, 1), err
+		case "delete":
+			path, err := itemPath()
+			return http.MethodDelete, path, paramAt(params, 1), err
+		case "get_instance":
+			path, err := itemPath()
+			return http.MethodGet, path, nil, err
+		case "query":
+			endpoint.Query = true
+			name = ""
+		}
+	}
+
+	path := namespace
+
+	if name != "" {
+		path = namespace + "/" + name
+	}
+
+	if endpoint.Query {
+		query, err := restQuery(params)
+
+		if err != nil {
+			return "", "", nil, fmt.Errorf("%s: %s", method, err)
+		}
+
+		return http.MethodGet, path + query, nil, nil
+	}
+
+	args := params
+
+	if endpoint.ItemMethod {
+		itemPath, err := itemPath()
+
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		path = itemPath + "/" + name
+		args = params[1:]
+	}
+
+	var body interface{}
+
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && len(endpoint.ArgNames) == 0:
+		body = args[0]
+	case len(args) <= len(endpoint.ArgNames):
+		named := make(map[string]interface{}, len(args))
+
+		for i, arg := range args {
+			named[endpoint.ArgNames[i]] = arg
+		}
+
+		body = named
+	default:
+		return "", "", nil, fmt.Errorf("%s: REST argument names are not known", method)
+	}
+
+	httpMethod := endpoint.HTTPMethod
+
+	if httpMethod == "" {
+		httpMethod = http.MethodPost
+
+		if body == nil {
+			httpMethod = http.MethodGet
+		}
+	}
+
+	return httpMethod, path, body, nil
+}
+
+// restQuery converts query-filters into query string, REST API only supports equality filters
+func restQuery(params []interface{}) (string, error) {
+	if len(params) > 1 && params[1] != nil {
+		return "", fmt.Errorf("query options are not supported by REST transport")
+	}
+
+	if len(params) == 0 || params[0] == nil {
+		return "", nil
+	}
+
+	data, err := json.Marshal(params[0])
+
+	if err != nil {
+		return "", err
+	}
+
+	var filters [][]interface{}
+
+	if err := json.Unmarshal(data, &filters); err != nil {
+		return "", fmt.Errorf("invalid query filters: %s", err)
+	}
+
+	values := url.Values{}
+
+	for _, f := range filters {
+		if len(f) != 3 || f[1] != "=" {
+			return "", fmt.Errorf("query filter %v is not supported by REST transport", f)
+		}
+
+		values.Add(fmt.Sprint(f[0]), fmt.Sprint(f[2]))
+	}
+
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	return "?" + values.Encode(), nil
+}
+
+func paramAt(params []interface{}, i int) interface{} {
+	if i < len(params) {
+		return params[i]
+	}
+
+	return nil
+}
+
+// queryFilter builds middleware query-filters param matching single field
+func queryFilter(field string, value interface{}) [][]interface{} {
+	return [][]interface{}{{field, "=", value}}
+}
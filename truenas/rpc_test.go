@@ -0,0 +1,169 @@
+package truenas
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_restRequest(t *testing.T) {
+	testcases := []struct {
+		method     string
+		params     []interface{}
+		httpMethod string
+		path       string
+		body       interface{}
+	}{
+		{
+			method:     "pool.snapshottask.create",
+			params:     []interface{}{map[string]interface{}{"dataset": "Tank"}},
+			httpMethod: http.MethodPost,
+			path:       "pool/snapshottask",
+			body:       map[string]interface{}{"dataset": "Tank"},
+		},
+		{
+			method:     "pool.snapshottask.update",
+			params:     []interface{}{5, map[string]interface{}{"enabled": false}},
+			httpMethod: http.MethodPut,
+			path:       "pool/snapshottask/id/5",
+			body:       map[string]interface{}{"enabled": false},
+		},
+		{
+			method:     "pool.dataset.delete",
+			params:     []interface{}{"Tank/data"},
+			httpMethod: http.MethodDelete,
+			path:       "pool/dataset/id/Tank%2Fdata",
+		},
+		{
+			method:     "zfs.snapshot.get_instance",
+			params:     []interface{}{"Tank/data@snap"},
+			httpMethod: http.MethodGet,
+			path:       "zfs/snapshot/id/Tank%2Fdata@snap",
+		},
+		{
+			method:     "pool.query",
+			params:     []interface{}{queryFilter("name", "Tank")},
+			httpMethod: http.MethodGet,
+			path:       "pool?name=Tank",
+		},
+		{
+			method:     "core.get_jobs",
+			params:     []interface{}{queryFilter("id", 42)},
+			httpMethod: http.MethodGet,
+			path:       "core/get_jobs?id=42",
+		},
+		{
+			method:     "auth.generate_token",
+			params:     []interface{}{600},
+			httpMethod: http.MethodPost,
+			path:       "auth/generate_token",
+			body:       map[string]interface{}{"ttl": 600},
+		},
+		{
+			method:     "replication.run",
+			params:     []interface{}{3},
+			httpMethod: http.MethodPost,
+			path:       "replication/id/3/run",
+		},
+		{
+			method:     "pool.dataset.permission",
+			params:     []interface{}{"Tank/data", map[string]interface{}{"mode": "0755"}},
+			httpMethod: http.MethodPost,
+			path:       "pool/dataset/id/Tank%2Fdata/permission",
+			body:       map[string]interface{}{"mode": "0755"},
+		},
+		{
+			method:     "pool.dataset.promote",
+			params:     []interface{}{"Tank/clone"},
+			httpMethod: http.MethodPost,
+			path:       "pool/dataset/id/Tank%2Fclone/promote",
+		},
+		{
+			method:     "zfs.snapshot.rollback",
+			params:     []interface{}{"Tank/data@base", map[string]interface{}{"recursive": true}},
+			httpMethod: http.MethodPost,
+			path:       "zfs/snapshot/rollback",
+			body:       map[string]interface{}{"id": "Tank/data@base", "options": map[string]interface{}{"recursive": true}},
+		},
+		{
+			method:     "zfs.snapshot.clone",
+			params:     []interface{}{map[string]interface{}{"snapshot": "Tank/data@base", "dataset_dst": "Tank/clone"}},
+			httpMethod: http.MethodPost,
+			path:       "zfs/snapshot/clone",
+			body:       map[string]interface{}{"snapshot": "Tank/data@base", "dataset_dst": "Tank/clone"},
+		},
+		{
+			method:     "pool.dataset.rename",
+			params:     []interface{}{"Tank/data", map[string]interface{}{"new_name": "Tank/archive/data"}},
+			httpMethod: http.MethodPost,
+			path:       "pool/dataset/id/Tank%2Fdata/rename",
+			body:       map[string]interface{}{"new_name": "Tank/archive/data"},
+		},
+		{
+			method:     "pool.dataset.get_quota",
+			params:     []interface{}{"Tank/home", "USER"},
+			httpMethod: http.MethodPost,
+			path:       "pool/dataset/id/Tank%2Fhome/get_quota",
+			body:       map[string]interface{}{"quota_type": "USER"},
+		},
+		{
+			method:     "pool.dataset.set_quota",
+			params:     []interface{}{"Tank/home", []interface{}{map[string]interface{}{"quota_type": "USER", "id": "568", "quota_value": 1024}}},
+			httpMethod: http.MethodPost,
+			path:       "pool/dataset/id/Tank%2Fhome/set_quota",
+			body:       []interface{}{map[string]interface{}{"quota_type": "USER", "id": "568", "quota_value": 1024}},
+		},
+		{
+			method:     "pool.dataset.inherit_parent_encryption_properties",
+			params:     []interface{}{"Tank/secret/data"},
+			httpMethod: http.MethodPost,
+			path:       "pool/dataset/id/Tank%2Fsecret%2Fdata/inherit_parent_encryption_properties",
+		},
+		{
+			method:     "filesystem.getacl",
+			params:     []interface{}{"/mnt/Tank/data", true},
+			httpMethod: http.MethodPost,
+			path:       "filesystem/getacl",
+			body:       map[string]interface{}{"path": "/mnt/Tank/data", "simplified": true},
+		},
+		{
+			method:     "system.info",
+			httpMethod: http.MethodGet,
+			path:       "system/info",
+		},
+	}
+
+	for _, c := range testcases {
+		httpMethod, path, body, err := restRequest(c.method, c.params)
+
+		assert.NoError(t, err, c.method)
+		assert.Equal(t, c.httpMethod, httpMethod, c.method)
+		assert.Equal(t, c.path, path, c.method)
+		assert.Equal(t, c.body, body, c.method)
+	}
+}
+
+func Test_restRequest_unsupported(t *testing.T) {
+	_, _, _, err := restRequest("pool.query", []interface{}{[][]interface{}{{"name", "^", "Ta"}}})
+	assert.Error(t, err)
+
+	_, _, _, err = restRequest("pool.query", []interface{}{nil, map[string]interface{}{"extra": true}})
+	assert.Error(t, err)
+
+	_, _, _, err = restRequest("pool.dataset.get_instance", nil)
+	assert.Error(t, err)
+
+	_, _, _, err = restRequest("test.method", []interface{}{1, 2})
+	assert.Error(t, err)
+}
+
+func TestRestCaller_notFound(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	err := c.invoke(context.Background(), "sharing.smb.get_instance", []interface{}{1}, nil)
+
+	assert.True(t, isNotFoundError(nil, err), "REST 404 must be reported as ENOENT")
+}
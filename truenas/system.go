@@ -0,0 +1,184 @@
+package truenas
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TrueNAS product types, see system.product_type
+const (
+	productTypeCORE            = "CORE"
+	productTypeEnterprise      = "ENTERPRISE"
+	productTypeSCALE           = "SCALE"
+	productTypeSCALEEnterprise = "SCALE_ENTERPRISE"
+)
+
+var systemReleasePattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// systemInfoResponse is system.info result
+type systemInfoResponse struct {
+	Version            string  `json:"version"`
+	Hostname           string  `json:"hostname"`
+	Model              string  `json:"model"`
+	Cores              int     `json:"cores"`
+	PhysicalCores      int     `json:"physical_cores"`
+	Physmem            int64   `json:"physmem"`
+	UptimeSeconds      float64 `json:"uptime_seconds"`
+	Timezone           string  `json:"timezone"`
+	SystemManufacturer *string `json:"system_manufacturer"`
+	SystemProduct      *string `json:"system_product"`
+	SystemSerial       *string `json:"system_serial"`
+	EccMemory          bool    `json:"ecc_memory"`
+}
+
+// systemRelease is numeric part of TrueNAS version, eg. 22.12.1 for TrueNAS-SCALE-22.12.1
+type systemRelease struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+func parseSystemRelease(version string) (systemRelease, error) {
+	m := systemReleasePattern.FindStringSubmatch(version)
+
+	if m == nil {
+		return systemRelease{}, fmt.Errorf("unable to parse TrueNAS version: %s", version)
+	}
+
+	r := systemRelease{}
+	r.Major, _ = strconv.Atoi(m[1])
+	r.Minor, _ = strconv.Atoi(m[2])
+
+	if m[3] != "" {
+		r.Patch, _ = strconv.Atoi(m[3])
+	}
+
+	return r, nil
+}
+
+func (r systemRelease) atLeast(major, minor int) bool {
+	return r.Major > major || (r.Major == major && r.Minor >= minor)
+}
+
+func (r systemRelease) String() string {
+	return fmt.Sprintf("%d.%d.%d", r.Major, r.Minor, r.Patch)
+}
+
+// systemInfo describes TrueNAS system the provider is connected to, it is detected once
+// when provider is configured and used to validate resource attributes at plan time
+type systemInfo struct {
+	Version     string
+	ProductType string
+	Release     systemRelease
+}
+
+func (s *systemInfo) isSCALE() bool {
+	if s.ProductType != "" {
+		return s.ProductType == productTypeSCALE || s.ProductType == productTypeSCALEEnterprise
+	}
+
+	// CORE releases are numbered 12.x, 13.x, while SCALE starts with 20.x
+	return s.Release.Major >= 20
+}
+
+func (s *systemInfo) String() string {
+	flavour := "CORE"
+
+	if s.isSCALE() {
+		flavour = "SCALE"
+	}
+
+	return fmt.Sprintf("TrueNAS %s %s", flavour, s.Release)
+}
+
+func (c *Client) getSystemInfo(ctx context.Context) (*systemInfoResponse, error) {
+	var info systemInfoResponse
+
+	if err := c.invoke(ctx, "system.info", nil, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func (c *Client) getProductType(ctx context.Context) (string, error) {
+	var productType string
+
+	if err := c.invoke(ctx, "system.product_type", nil, &productType); err != nil {
+		return "", err
+	}
+
+	return productType, nil
+}
+
+// detectSystem queries TrueNAS version and product type
+func (c *Client) detectSystem(ctx context.Context) (*systemInfo, error) {
+	info, err := c.getSystemInfo(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := parseSystemRelease(info.Version)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// older releases do not have system.product_type, version is enough to tell CORE from SCALE
+	productType, _ := c.getProductType(ctx)
+
+	return &systemInfo{
+		Version:     info.Version,
+		ProductType: productType,
+		Release:     release,
+	}, nil
+}
+
+// smbPresetNames returns SMB share purpose presets supported by the system, result is cached
+func (c *Client) smbPresetNames(ctx context.Context) ([]string, error) {
+	c.capMu.Lock()
+	defer c.capMu.Unlock()
+
+	if c.smbPresets != nil {
+		return c.smbPresets, nil
+	}
+
+	var presets map[string]interface{}
+
+	if err := c.invoke(ctx, "sharing.smb.presets", nil, &presets); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(presets))
+
+	for name := range presets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	c.smbPresets = names
+
+	return names, nil
+}
+
+// systemFromMeta returns detected system, or nil if provider could not detect it,
+// in that case capability checks are skipped
+func systemFromMeta(m interface{}) *systemInfo {
+	c, ok := m.(*Client)
+
+	if !ok {
+		return nil
+	}
+
+	return c.system
+}
+
+// unsupportedError reports attribute value that is not supported by connected system
+func unsupportedError(system *systemInfo, attr string, value interface{}, reason string) error {
+	return fmt.Errorf("%s = %v is not supported by %s: %s", attr, value, system, strings.TrimSpace(reason))
+}
@@ -0,0 +1,108 @@
+package truenas
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_parseSystemRelease(t *testing.T) {
+	testcases := map[string]systemRelease{
+		"TrueNAS-13.0-U3.1":       {Major: 13, Minor: 0},
+		"TrueNAS-SCALE-22.12.0":   {Major: 22, Minor: 12, Patch: 0},
+		"TrueNAS-SCALE-22.02.4":   {Major: 22, Minor: 2, Patch: 4},
+		"TrueNAS-SCALE-23.10.0.1": {Major: 23, Minor: 10, Patch: 0},
+		"25.04.1":                 {Major: 25, Minor: 4, Patch: 1},
+	}
+
+	for version, expected := range testcases {
+		actual, err := parseSystemRelease(version)
+
+		assert.NoError(t, err, version)
+		assert.Equal(t, expected, actual, version)
+	}
+
+	_, err := parseSystemRelease("TrueNAS-MASTER")
+	assert.Error(t, err)
+}
+
+func Test_systemInfo(t *testing.T) {
+	core := &systemInfo{Version: "TrueNAS-13.0-U3.1", ProductType: productTypeCORE, Release: systemRelease{Major: 13}}
+	scale := &systemInfo{Version: "TrueNAS-SCALE-22.12.0", Release: systemRelease{Major: 22, Minor: 12}}
+
+	assert.False(t, core.isSCALE())
+	assert.True(t, scale.isSCALE(), "SCALE must be detected from release if product type is not known")
+	assert.Equal(t, "TrueNAS SCALE 22.12.0", scale.String())
+	assert.True(t, scale.Release.atLeast(22, 12))
+	assert.True(t, scale.Release.atLeast(21, 14))
+	assert.False(t, scale.Release.atLeast(23, 10))
+}
+
+func TestClient_detectSystem(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2.0/system/info":
+			w.Write([]byte(`{"version": "TrueNAS-SCALE-22.12.0", "hostname": "nas"}`))
+		case "/api/v2.0/system/product_type":
+			w.Write([]byte(`"SCALE"`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	system, err := c.detectSystem(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, &systemInfo{
+		Version:     "TrueNAS-SCALE-22.12.0",
+		ProductType: productTypeSCALE,
+		Release:     systemRelease{Major: 22, Minor: 12},
+	}, system)
+}
+
+func testCustomizeDiff(t *testing.T, name string, raw map[string]interface{}, m interface{}) error {
+	_, err := Provider().ResourcesMap[name].Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), m)
+	return err
+}
+
+func TestResourceTrueNASVM_customizeDiff(t *testing.T) {
+	core := &Client{system: &systemInfo{ProductType: productTypeCORE, Release: systemRelease{Major: 13}}}
+	scale := &Client{system: &systemInfo{ProductType: productTypeSCALE, Release: systemRelease{Major: 22, Minor: 12}}}
+	raw := map[string]interface{}{"name": "test", "bootloader": "GRUB"}
+
+	assert.NoError(t, testCustomizeDiff(t, "truenas_vm", raw, core))
+	assert.NoError(t, testCustomizeDiff(t, "truenas_vm", raw, &Client{}), "unknown system must not be validated")
+	assert.EqualError(t, testCustomizeDiff(t, "truenas_vm", raw, scale),
+		"bootloader = GRUB is not supported by TrueNAS SCALE 22.12.0: GRUB bootloader is only available on TrueNAS CORE")
+}
+
+func TestResourceTrueNASShareNFS_customizeDiff(t *testing.T) {
+	bluefin := &Client{system: &systemInfo{ProductType: productTypeSCALE, Release: systemRelease{Major: 22, Minor: 12}}}
+	angelfish := &Client{system: &systemInfo{ProductType: productTypeSCALE, Release: systemRelease{Major: 22, Minor: 2}}}
+
+	multiPath := map[string]interface{}{"paths": []interface{}{"/mnt/Tank/a", "/mnt/Tank/b"}}
+	allDirs := map[string]interface{}{"paths": []interface{}{"/mnt/Tank/a"}, "alldirs": true}
+
+	assert.NoError(t, testCustomizeDiff(t, "truenas_share_nfs", multiPath, angelfish))
+	assert.Error(t, testCustomizeDiff(t, "truenas_share_nfs", multiPath, bluefin))
+	assert.EqualError(t, testCustomizeDiff(t, "truenas_share_nfs", allDirs, bluefin),
+		"alldirs = true is not supported by TrueNAS SCALE 22.12.0: option was removed from NFS shares")
+}
+
+func TestResourceTrueNASShareSMB_customizeDiff(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2.0/sharing/smb/presets", r.URL.Path)
+		w.Write([]byte(`{"NO_PRESET": {}, "DEFAULT_SHARE": {}, "MULTI_PROTOCOL_NFS": {}}`))
+	}))
+
+	c.system = &systemInfo{ProductType: productTypeSCALE, Release: systemRelease{Major: 22, Minor: 12}}
+
+	raw := map[string]interface{}{"path": "/mnt/Tank/smb", "name": "smb", "purpose": "DEFAULT_SHARE"}
+	assert.NoError(t, testCustomizeDiff(t, "truenas_share_smb", raw, c))
+
+	raw["purpose"] = "WORM_DROPBOX"
+	assert.EqualError(t, testCustomizeDiff(t, "truenas_share_smb", raw, c),
+		"purpose = WORM_DROPBOX is not supported by TrueNAS SCALE 22.12.0: supported presets: DEFAULT_SHARE, MULTI_PROTOCOL_NFS, NO_PRESET")
+}
@@ -0,0 +1,344 @@
+package truenas
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsOptions holds provider TLS settings used to connect to TrueNAS API
+type tlsOptions struct {
+	CACertPEM          string
+	CACertFile         string
+	ClientCert         string
+	ClientKey          string
+	InsecureSkipVerify bool
+}
+
+// newTLSConfig builds TLS client configuration, custom CA certificates are added
+// on top of system certificate pool
+func newTLSConfig(opts tlsOptions) (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CACertPEM != "" && opts.CACertFile != "" {
+		return nil, errors.New("only one of ca_cert_pem or ca_cert_file can be set")
+	}
+
+	caPEM := []byte(opts.CACertPEM)
+
+	if opts.CACertFile != "" {
+		var err error
+		caPEM, err = os.ReadFile(opts.CACertFile)
+
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA certificate file: %s", err)
+		}
+	}
+
+	if len(caPEM) > 0 {
+		pool, err := x509.SystemCertPool()
+
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no valid PEM encoded CA certificates found")
+		}
+
+		cfg.RootCAs = pool
+	}
+
+	if (opts.ClientCert == "") != (opts.ClientKey == "") {
+		return nil, errors.New("client_cert and client_key must be set together")
+	}
+
+	if opts.ClientCert != "" {
+		cert, err := tls.X509KeyPair([]byte(opts.ClientCert), []byte(opts.ClientKey))
+
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %s", err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// newTransport creates base HTTP transport for API client, that is later
+// wrapped by oauth2 token source
+func newTransport(opts tlsOptions) (*http.Transport, error) {
+	tlsConfig, err := newTLSConfig(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsConfig
+
+	return t, nil
+}
+
+// retryOptions controls how transient API failures are retried
+type retryOptions struct {
+	MaxRetries int
+	MinWait    time.Duration
+	MaxWait    time.Duration
+}
+
+// retryTransport retries requests that failed due to transient middleware errors,
+// eg. 502/503 responses while middlewared restarts, or EBUSY validation errors.
+// Idempotent requests are retried on any transient failure, while POST requests
+// only when it is known that middleware did not process them
+type retryTransport struct {
+	base http.RoundTripper
+	opts retryOptions
+}
+
+func newRetryTransport(base http.RoundTripper, opts retryOptions) *retryTransport {
+	return &retryTransport{
+		base: base,
+		opts: opts,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		r := req
+
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+
+			if err != nil {
+				return nil, err
+			}
+
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(r)
+
+		if attempt >= t.opts.MaxRetries || !isRetryable(req, resp, err) {
+			return resp, err
+		}
+
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			// body cannot be rewound
+			return resp, err
+		}
+
+		wait := t.backoff(attempt)
+
+		// do not retry past context deadline, that comes from resource timeouts
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return resp, err
+		}
+
+		if err != nil {
+			log.Printf("[DEBUG] %s %s failed: %s, retrying in %s", req.Method, req.URL.Path, err, wait)
+		} else {
+			log.Printf("[DEBUG] %s %s failed with %s, retrying in %s", req.Method, req.URL.Path, resp.Status, wait)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns exponential wait time for given attempt with jitter applied
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	wait := t.opts.MinWait << uint(attempt)
+
+	if wait > t.opts.MaxWait || wait <= 0 {
+		wait = t.opts.MaxWait
+	}
+
+	if wait <= t.opts.MinWait {
+		return wait
+	}
+
+	// wait at least half of the backoff, randomize the rest
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(wait-half)))
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// isRetryable decides if request can be safely retried, response body is restored
+// if it had to be read to check middleware errno
+func isRetryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		if isCertificateError(err) {
+			return false
+		}
+
+		if isIdempotent(req.Method) {
+			return true
+		}
+
+		// connection was never established, request did not reach middleware
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		// non-idempotent request might have reached middleware before proxy gave up
+		return isIdempotent(req.Method)
+	case http.StatusServiceUnavailable:
+		if isIdempotent(req.Method) {
+			return true
+		}
+
+		// nginx responds with 503 while middlewared is unavailable, JSON error body
+		// means that middleware itself handled the request
+		body, readErr := peekBody(resp)
+		return readErr == nil && !json.Valid(body)
+	case http.StatusUnprocessableEntity:
+		body, readErr := peekBody(resp)
+
+		if readErr != nil {
+			return false
+		}
+
+		// middleware rejected request because resource is busy
+		return decodeAPIErrorBody(resp.Status, body).hasErrno(errnoEBUSY)
+	}
+
+	return false
+}
+
+// peekBody reads response body and restores it, so that it can be read again by the caller
+func peekBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, err
+}
+
+// isCertificateError checks if request failed due to server certificate verification,
+// retrying such requests does not help
+func isCertificateError(err error) bool {
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	return errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// limitTransport limits number of concurrent API requests, since all resources and
+// data sources share the same client, they are all throttled together regardless
+// of terraform -parallelism
+type limitTransport struct {
+	base http.RoundTripper
+	sem  chan struct{}
+}
+
+func newLimitTransport(base http.RoundTripper, limit int) *limitTransport {
+	return &limitTransport{
+		base: base,
+		sem:  make(chan struct{}, limit),
+	}
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case t.sem <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	resp, err := t.base.RoundTrip(req)
+
+	if err != nil {
+		t.release()
+		return resp, err
+	}
+
+	// request slot is held until response body is consumed
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: t.release}
+	return resp, nil
+}
+
+func (t *limitTransport) release() {
+	<-t.sem
+}
+
+// limitCaller applies limitTransport request limit to websocket calls, so that
+// max_concurrent_requests covers both transports
+type limitCaller struct {
+	base apiCaller
+	sem  chan struct{}
+}
+
+func newLimitCaller(base apiCaller, limit *limitTransport) *limitCaller {
+	return &limitCaller{
+		base: base,
+		sem:  limit.sem,
+	}
+}
+
+func (l *limitCaller) Call(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	defer func() { <-l.sem }()
+
+	return l.base.Call(ctx, method, params, out)
+}
+
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,424 @@
+package truenas
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestTLSServer(t *testing.T, clientCAs *x509.CertPool) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id": 1, "name": "Tank", "path": "/mnt/Tank"}]`))
+	})
+
+	srv := httptest.NewUnstartedServer(handler)
+
+	if clientCAs != nil {
+		srv.TLS = &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  clientCAs,
+		}
+	}
+
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func testServerCAPEM(srv *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+}
+
+// newTestClientCert generates self-signed client certificate, returns PEM encoded certificate and key
+func newTestClientCert(t *testing.T) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "terraform"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return string(certPEM), string(keyPEM), cert
+}
+
+func testProviderListPools(t *testing.T, raw map[string]interface{}) error {
+	d := schema.TestResourceDataRaw(t, Provider().Schema, raw)
+
+	m, diags := providerConfigure(context.Background(), d)
+
+	if diags.HasError() {
+		t.Fatalf("unexpected configure error: %v", diags)
+	}
+
+	_, _, err := m.(*Client).PoolApi.ListPools(context.Background()).Execute()
+	return err
+}
+
+func TestProvider_tlsUnknownCA(t *testing.T) {
+	srv := newTestTLSServer(t, nil)
+
+	err := testProviderListPools(t, map[string]interface{}{
+		"api_key":  "test-key",
+		"base_url": srv.URL,
+	})
+
+	assert.Error(t, err)
+}
+
+func TestProvider_tlsCACertPEM(t *testing.T) {
+	srv := newTestTLSServer(t, nil)
+
+	err := testProviderListPools(t, map[string]interface{}{
+		"api_key":     "test-key",
+		"base_url":    srv.URL,
+		"ca_cert_pem": testServerCAPEM(srv),
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestProvider_tlsCACertFile(t *testing.T) {
+	srv := newTestTLSServer(t, nil)
+	path := filepath.Join(t.TempDir(), "ca.pem")
+
+	if err := os.WriteFile(path, []byte(testServerCAPEM(srv)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := testProviderListPools(t, map[string]interface{}{
+		"api_key":      "test-key",
+		"base_url":     srv.URL,
+		"ca_cert_file": path,
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestProvider_tlsInsecureSkipVerify(t *testing.T) {
+	srv := newTestTLSServer(t, nil)
+
+	err := testProviderListPools(t, map[string]interface{}{
+		"api_key":              "test-key",
+		"base_url":             srv.URL,
+		"insecure_skip_verify": true,
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestProvider_tlsClientCert(t *testing.T) {
+	certPEM, keyPEM, cert := newTestClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+
+	srv := newTestTLSServer(t, clientCAs)
+
+	err := testProviderListPools(t, map[string]interface{}{
+		"api_key":     "test-key",
+		"base_url":    srv.URL,
+		"ca_cert_pem": testServerCAPEM(srv),
+		"max_retries": 0,
+	})
+
+	assert.Error(t, err, "server should reject client without certificate")
+
+	err = testProviderListPools(t, map[string]interface{}{
+		"api_key":     "test-key",
+		"base_url":    srv.URL,
+		"ca_cert_pem": testServerCAPEM(srv),
+		"client_cert": certPEM,
+		"client_key":  keyPEM,
+	})
+
+	assert.NoError(t, err)
+}
+
+func Test_newTLSConfig_invalid(t *testing.T) {
+	testcases := []tlsOptions{
+		{CACertPEM: "not a certificate"},
+		{CACertFile: filepath.Join(t.TempDir(), "missing.pem")},
+		{CACertPEM: "pem", CACertFile: "ca.pem"},
+		{ClientCert: "cert"},
+		{ClientCert: "cert", ClientKey: "key"},
+	}
+
+	for _, c := range testcases {
+		_, err := newTLSConfig(c)
+		assert.Error(t, err, "%+v", c)
+	}
+}
+
+func newTestRetryClient(maxRetries int, minWait time.Duration) *http.Client {
+	return &http.Client{
+		Transport: newRetryTransport(http.DefaultTransport, retryOptions{
+			MaxRetries: maxRetries,
+			MinWait:    minWait,
+			MaxWait:    4 * minWait,
+		}),
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	testcases := []struct {
+		name             string
+		method           string
+		responses        []int
+		body             string
+		expectedAttempts int
+		expectedStatus   int
+	}{
+		{name: "GET retried on 503", method: http.MethodGet, responses: []int{503, 502, 200}, expectedAttempts: 3, expectedStatus: 200},
+		{name: "DELETE retried on 504", method: http.MethodDelete, responses: []int{504, 200}, expectedAttempts: 2, expectedStatus: 200},
+		{name: "POST retried on 503", method: http.MethodPost, responses: []int{503, 200}, expectedAttempts: 2, expectedStatus: 200},
+		{name: "POST retried on nginx 503", method: http.MethodPost, responses: []int{503, 200}, body: "<html>503 Service Temporarily Unavailable</html>", expectedAttempts: 2, expectedStatus: 200},
+		{name: "POST not retried on middleware 503", method: http.MethodPost, responses: []int{503, 200}, body: `{"message": "Failover is in progress"}`, expectedAttempts: 1, expectedStatus: 503},
+		{name: "POST retried on 429", method: http.MethodPost, responses: []int{429, 200}, expectedAttempts: 2, expectedStatus: 200},
+		{name: "POST not retried on 502", method: http.MethodPost, responses: []int{502, 200}, expectedAttempts: 1, expectedStatus: 502},
+		{name: "POST not retried on 504", method: http.MethodPost, responses: []int{504, 200}, expectedAttempts: 1, expectedStatus: 504},
+		{name: "POST not retried on 500", method: http.MethodPost, responses: []int{500, 200}, expectedAttempts: 1, expectedStatus: 500},
+		{name: "POST retried on EBUSY", method: http.MethodPost, responses: []int{422, 200}, body: `{"message": "Dataset is busy", "errno": 16}`, expectedAttempts: 2, expectedStatus: 200},
+		{name: "POST not retried on EINVAL", method: http.MethodPost, responses: []int{422, 200}, body: `{"pool_dataset_create.name": [{"message": "Invalid name", "errno": 22}]}`, expectedAttempts: 1, expectedStatus: 422},
+		{name: "retries exhausted", method: http.MethodGet, responses: []int{503, 503, 503, 503}, expectedAttempts: 3, expectedStatus: 503},
+	}
+
+	for _, c := range testcases {
+		t.Run(c.name, func(t *testing.T) {
+			attempts := 0
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reqBody, _ := io.ReadAll(r.Body)
+
+				if r.Method == http.MethodPost {
+					assert.Equal(t, `{"name": "Tank/test"}`, string(reqBody), "request body must be re-sent on retry")
+				}
+
+				status := c.responses[attempts]
+				attempts++
+
+				w.WriteHeader(status)
+
+				if status != http.StatusOK {
+					w.Write([]byte(c.body))
+				}
+			}))
+			defer srv.Close()
+
+			req, _ := http.NewRequest(c.method, srv.URL, strings.NewReader(`{"name": "Tank/test"}`))
+			resp, err := newTestRetryClient(2, time.Millisecond).Do(req)
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.expectedStatus, resp.StatusCode)
+			assert.Equal(t, c.expectedAttempts, attempts)
+
+			if resp.StatusCode != http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				assert.Equal(t, c.body, string(body), "response body must be readable after errno check")
+			}
+		})
+	}
+}
+
+func TestRetryTransport_contextDeadline(t *testing.T) {
+	attempts := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+
+	start := time.Now()
+	resp, err := newTestRetryClient(10, time.Second).Do(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRetryTransport_backoff(t *testing.T) {
+	tr := newRetryTransport(http.DefaultTransport, retryOptions{
+		MaxRetries: 10,
+		MinWait:    time.Second,
+		MaxWait:    10 * time.Second,
+	})
+
+	assert.Equal(t, time.Second, tr.backoff(0))
+
+	for attempt := 1; attempt < 10; attempt++ {
+		wait := tr.backoff(attempt)
+		assert.GreaterOrEqual(t, wait, time.Second)
+		assert.LessOrEqual(t, wait, 10*time.Second)
+	}
+}
+
+func TestLimitTransport(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: newLimitTransport(http.DefaultTransport, 2)}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := client.Get(srv.URL)
+
+			if assert.NoError(t, err) {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 2, maxInFlight)
+}
+
+func TestLimitTransport_contextCancelled(t *testing.T) {
+	tr := newLimitTransport(http.DefaultTransport, 1)
+	// occupy the only slot
+	tr.sem <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1", nil)
+	_, err := tr.RoundTrip(req)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+type slowCaller struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (s *slowCaller) Call(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+
+	return nil
+}
+
+func TestLimitCaller(t *testing.T) {
+	tr := newLimitTransport(http.DefaultTransport, 2)
+	// REST request holds one of the slots shared with websocket calls
+	tr.sem <- struct{}{}
+
+	base := &slowCaller{}
+	caller := newLimitCaller(base, tr)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, caller.Call(context.Background(), "core.ping", nil, nil))
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 1, base.maxInFlight)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// occupy the remaining slot
+	tr.sem <- struct{}{}
+
+	assert.ErrorIs(t, caller.Call(ctx, "core.ping", nil, nil), context.DeadlineExceeded)
+}
@@ -0,0 +1,448 @@
+package truenas
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/net/websocket"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// wsProtocol is a message format spoken by middleware websocket endpoint
+type wsProtocol int
+
+const (
+	// wsProtocolJSONRPC is JSON-RPC 2.0, served at /api/current on newer TrueNAS releases
+	wsProtocolJSONRPC wsProtocol = iota
+	// wsProtocolDDP is legacy DDP based protocol, served at /websocket
+	wsProtocolDDP
+)
+
+const wsDialTimeout = 30 * time.Second
+
+// wsCredentials are used to log in after websocket connection is established
+type wsCredentials struct {
+	APIKey   string
+	Username string
+	Password string
+}
+
+// websocketURL derives middleware websocket endpoint from REST API base URL,
+// eg. https://your.nas/api/v2.0 -> wss://your.nas/api/current
+func websocketURL(baseURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("unsupported URL scheme: %s", u.Scheme)
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+
+	if strings.HasSuffix(path, "/api/v2.0") {
+		path = strings.TrimSuffix(path, "/api/v2.0")
+	}
+
+	u.Path = path + "/api/current"
+
+	return u.String(), nil
+}
+
+// websocketCaller calls middleware methods over a single websocket connection, that is opened
+// lazily and re-established (and re-authenticated) if it breaks
+type websocketCaller struct {
+	url       string
+	protocol  wsProtocol
+	tlsConfig *tls.Config
+	creds     wsCredentials
+
+	mu   sync.Mutex
+	conn *wsConn
+}
+
+func newWebsocketCaller(wsURL string, tlsConfig *tls.Config, creds wsCredentials) (*websocketCaller, error) {
+	u, err := url.Parse(wsURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket URL: %s", err)
+	}
+
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil, fmt.Errorf("invalid websocket URL scheme: %s", u.Scheme)
+	}
+
+	protocol := wsProtocolJSONRPC
+
+	if strings.HasSuffix(strings.TrimSuffix(u.Path, "/"), "/websocket") {
+		protocol = wsProtocolDDP
+	}
+
+	return &websocketCaller{
+		url:       wsURL,
+		protocol:  protocol,
+		tlsConfig: tlsConfig,
+		creds:     creds,
+	}, nil
+}
+
+func (w *websocketCaller) Call(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	conn, err := w.connect(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	result, err := conn.call(ctx, method, params)
+
+	if err != nil {
+		return err
+	}
+
+	if out != nil && len(result) > 0 {
+		if err := json.Unmarshal(result, out); err != nil {
+			return fmt.Errorf("error decoding %s result: %s", method, err)
+		}
+	}
+
+	return nil
+}
+
+// connect returns authenticated connection, opening a new one if necessary
+func (w *websocketCaller) connect(ctx context.Context) (*wsConn, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil && w.conn.alive() {
+		return w.conn, nil
+	}
+
+	u, err := url.Parse(w.url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	origin := &url.URL{Scheme: strings.Replace(u.Scheme, "ws", "http", 1), Host: u.Host}
+
+	config, err := websocket.NewConfig(w.url, origin.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	config.TlsConfig = w.tlsConfig
+	config.Dialer = &net.Dialer{Timeout: wsDialTimeout}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		config.Dialer.Deadline = deadline
+	}
+
+	log.Printf("[DEBUG] Connecting to TrueNAS websocket API: %s", w.url)
+
+	ws, err := websocket.DialConfig(config)
+
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %s", w.url, err)
+	}
+
+	conn := &wsConn{
+		ws:       ws,
+		protocol: w.protocol,
+		pending:  make(map[string]chan wsResponse),
+		done:     make(chan struct{}),
+	}
+
+	if w.protocol == wsProtocolDDP {
+		if err := conn.handshake(); err != nil {
+			ws.Close()
+			return nil, err
+		}
+	}
+
+	go conn.readLoop()
+
+	if err := w.login(ctx, conn); err != nil {
+		conn.close(err)
+		return nil, err
+	}
+
+	w.conn = conn
+	return conn, nil
+}
+
+func (w *websocketCaller) login(ctx context.Context, conn *wsConn) error {
+	var result json.RawMessage
+	var err error
+
+	if w.creds.APIKey != "" {
+		result, err = conn.call(ctx, "auth.login_with_api_key", []interface{}{w.creds.APIKey})
+	} else {
+		result, err = conn.call(ctx, "auth.login", []interface{}{w.creds.Username, w.creds.Password})
+	}
+
+	if err != nil {
+		return fmt.Errorf("error authenticating: %s", err)
+	}
+
+	var ok bool
+
+	if err := json.Unmarshal(result, &ok); err != nil || !ok {
+		return errors.New("authentication failed, check credentials")
+	}
+
+	return nil
+}
+
+// wsResponse is a decoded method call result
+type wsResponse struct {
+	Result json.RawMessage
+	Err    error
+}
+
+// wsMessage is any message received from middleware, both JSON-RPC 2.0 and DDP
+type wsMessage struct {
+	Msg    string          `json:"msg"`
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// wsError is middleware call error, for JSON-RPC 2.0 it is nested in error data
+type wsError struct {
+	Errno   int             `json:"error"`
+	Type    *string         `json:"type"`
+	Reason  string          `json:"reason"`
+	Extra   json.RawMessage `json:"extra"`
+	Message string          `json:"message"`
+	Data    *wsError        `json:"data"`
+}
+
+// wsConn is a single websocket connection, concurrent calls are matched with results by request id
+type wsConn struct {
+	ws       *websocket.Conn
+	protocol wsProtocol
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[string]chan wsResponse
+	err     error
+	done    chan struct{}
+}
+
+// handshake establishes DDP session
+func (c *wsConn) handshake() error {
+	c.ws.SetDeadline(time.Now().Add(wsDialTimeout))
+	defer c.ws.SetDeadline(time.Time{})
+
+	err := websocket.JSON.Send(c.ws, map[string]interface{}{
+		"msg":     "connect",
+		"version": "1",
+		"support": []string{"1"},
+	})
+
+	if err != nil {
+		return fmt.Errorf("error sending connect message: %s", err)
+	}
+
+	for {
+		var msg wsMessage
+
+		if err := websocket.JSON.Receive(c.ws, &msg); err != nil {
+			return fmt.Errorf("error receiving connect response: %s", err)
+		}
+
+		switch msg.Msg {
+		case "connected":
+			return nil
+		case "failed":
+			return errors.New("middleware rejected websocket session")
+		}
+	}
+}
+
+func (c *wsConn) alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *wsConn) call(ctx context.Context, method string, params []interface{}) (json.RawMessage, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	ch := make(chan wsResponse, 1)
+
+	c.mu.Lock()
+
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+
+	c.nextID++
+	n := c.nextID
+	id := strconv.FormatInt(n, 10)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	var req interface{}
+
+	if c.protocol == wsProtocolDDP {
+		req = map[string]interface{}{"msg": "method", "id": id, "method": method, "params": params}
+	} else {
+		req = map[string]interface{}{"jsonrpc": "2.0", "id": n, "method": method, "params": params}
+	}
+
+	if err := c.send(req); err != nil {
+		c.close(err)
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp.Result, resp.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, c.err
+	}
+}
+
+func (c *wsConn) send(msg interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return websocket.JSON.Send(c.ws, msg)
+}
+
+func (c *wsConn) readLoop() {
+	for {
+		var msg wsMessage
+
+		if err := websocket.JSON.Receive(c.ws, &msg); err != nil {
+			c.close(fmt.Errorf("websocket connection closed: %s", err))
+			return
+		}
+
+		if c.protocol == wsProtocolDDP {
+			switch msg.Msg {
+			case "ping":
+				pong := map[string]interface{}{"msg": "pong"}
+
+				if len(msg.ID) > 0 {
+					pong["id"] = msg.ID
+				}
+
+				c.send(pong)
+				continue
+			case "result":
+			default:
+				// collection events are not used
+				continue
+			}
+		}
+
+		// JSON-RPC notifications have no id
+		id := strings.Trim(string(msg.ID), `"`)
+
+		if id == "" || id == "null" {
+			continue
+		}
+
+		resp := wsResponse{Result: msg.Result}
+
+		if len(msg.Error) > 0 && string(msg.Error) != "null" {
+			resp.Err = decodeWSError(msg.Error)
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[id]
+		c.mu.Unlock()
+
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+// close fails all pending calls with err, connection can not be used afterwards
+func (c *wsConn) close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return
+	}
+
+	c.err = err
+	close(c.done)
+	c.ws.Close()
+}
+
+// decodeWSError converts middleware call error into apiError, so it can be handled the
+// same way as REST API errors, eg. with isNotFoundError or apiErrorDiags
+func decodeWSError(raw json.RawMessage) *apiError {
+	var e wsError
+
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return &apiError{Message: strings.TrimSpace(string(raw)), Body: raw}
+	}
+
+	message := e.Message
+
+	if e.Data != nil {
+		// JSON-RPC 2.0 error, middleware error is passed as error data
+		e = *e.Data
+	}
+
+	result := &apiError{
+		Message: e.Reason,
+		Errno:   e.Errno,
+		Body:    raw,
+	}
+
+	if result.Message == "" {
+		result.Message = message
+	}
+
+	if e.Type != nil && *e.Type == "VALIDATION" {
+		if fields := validationErrorFields(e.Extra); len(fields) > 0 {
+			result.Message = ""
+			result.Fields = fields
+		}
+	}
+
+	if result.Errno == 0 {
+		result.Errno = errnoFromMessage(result.Message)
+	}
+
+	return result
+}
@@ -0,0 +1,324 @@
+package truenas
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testMiddleware is a websocket stand-in for TrueNAS middleware, it speaks JSON-RPC 2.0
+// at /api/current and DDP at /websocket
+type testMiddleware struct {
+	t           *testing.T
+	connections int32
+	// dropAfter closes connection after given number of calls, if set
+	dropAfter int
+	// handle responds to authenticated method calls, returns either result or middleware error
+	handle func(method string, params []interface{}) (interface{}, map[string]interface{})
+}
+
+func newTestMiddleware(t *testing.T, handle func(method string, params []interface{}) (interface{}, map[string]interface{})) (*testMiddleware, *httptest.Server) {
+	m := &testMiddleware{t: t, handle: handle}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/current", websocket.Handler(func(ws *websocket.Conn) { m.serve(ws, false) }))
+	mux.Handle("/websocket", websocket.Handler(func(ws *websocket.Conn) { m.serve(ws, true) }))
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return m, srv
+}
+
+func (m *testMiddleware) serve(ws *websocket.Conn, ddp bool) {
+	atomic.AddInt32(&m.connections, 1)
+	authenticated := false
+	calls := 0
+
+	if ddp {
+		var connect map[string]interface{}
+
+		if err := websocket.JSON.Receive(ws, &connect); err != nil || connect["msg"] != "connect" {
+			m.t.Errorf("expected DDP connect message, got %v", connect)
+			return
+		}
+
+		websocket.JSON.Send(ws, map[string]interface{}{"msg": "connected", "session": "test"})
+		// middleware pings clients, which must respond with pong
+		websocket.JSON.Send(ws, map[string]interface{}{"msg": "ping", "id": "ping-1"})
+	}
+
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		var req map[string]interface{}
+
+		if err := websocket.JSON.Receive(ws, &req); err != nil {
+			return
+		}
+
+		if ddp && req["msg"] == "pong" {
+			continue
+		}
+
+		if ddp {
+			assert.Equal(m.t, "method", req["msg"])
+		} else {
+			assert.Equal(m.t, "2.0", req["jsonrpc"])
+		}
+
+		method, _ := req["method"].(string)
+		params, _ := req["params"].([]interface{})
+
+		var result interface{}
+		var callErr map[string]interface{}
+
+		switch {
+		case method == "auth.login_with_api_key":
+			authenticated = len(params) == 1 && params[0] == "test-key"
+			result = authenticated
+		case method == "auth.login":
+			authenticated = len(params) == 2 && params[0] == "root" && params[1] == "secret"
+			result = authenticated
+		case !authenticated:
+			callErr = map[string]interface{}{"error": 13, "errname": "EACCES", "reason": "Not authenticated"}
+		default:
+			result, callErr = m.handle(method, params)
+		}
+
+		calls++
+
+		if m.dropAfter > 0 && calls > m.dropAfter {
+			ws.Close()
+			return
+		}
+
+		resp := map[string]interface{}{}
+
+		if ddp {
+			resp["msg"] = "result"
+			resp["id"] = req["id"]
+
+			if callErr != nil {
+				resp["error"] = callErr
+			} else {
+				resp["result"] = result
+			}
+		} else {
+			resp["jsonrpc"] = "2.0"
+			resp["id"] = req["id"]
+
+			if callErr != nil {
+				resp["error"] = map[string]interface{}{"code": -32001, "message": "Method call error", "data": callErr}
+			} else {
+				resp["result"] = result
+			}
+		}
+
+		// respond out of order to make sure results are matched by id
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if method == "test.slow" {
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			writeMu.Lock()
+			defer writeMu.Unlock()
+
+			if !ddp {
+				// notifications without id must be ignored
+				websocket.JSON.Send(ws, map[string]interface{}{"jsonrpc": "2.0", "method": "collection_update", "params": map[string]interface{}{}})
+			}
+
+			websocket.JSON.Send(ws, resp)
+		}()
+	}
+}
+
+func testMiddlewareHandler(method string, params []interface{}) (interface{}, map[string]interface{}) {
+	switch method {
+	case "test.echo", "test.slow":
+		return params, nil
+	case "pool.dataset.get_instance":
+		return nil, map[string]interface{}{"error": 2, "errname": "ENOENT", "reason": "Tank/missing does not exist"}
+	case "pool.snapshottask.create":
+		return nil, map[string]interface{}{
+			"error":   22,
+			"errname": "EINVAL",
+			"type":    "VALIDATION",
+			"reason":  "[EINVAL] pool_snapshottask_create.naming_schema: Invalid naming schema",
+			"extra":   [][]interface{}{{"pool_snapshottask_create.naming_schema", "Invalid naming schema", 22}},
+		}
+	case "pool.create":
+		return 7, nil
+	case "core.get_jobs":
+		return []interface{}{map[string]interface{}{"id": 7, "method": "pool.create", "state": "SUCCESS", "result": map[string]interface{}{"name": "Tank"}}}, nil
+	}
+
+	return nil, map[string]interface{}{"error": 22, "errname": "EINVAL", "reason": "Method does not exist"}
+}
+
+func newTestWebsocketCaller(t *testing.T, srv *httptest.Server, path string, creds wsCredentials) *websocketCaller {
+	caller, err := newWebsocketCaller("ws"+strings.TrimPrefix(srv.URL, "http")+path, nil, creds)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return caller
+}
+
+func TestWebsocketCaller(t *testing.T) {
+	for _, path := range []string{"/api/current", "/websocket"} {
+		t.Run(path, func(t *testing.T) {
+			m, srv := newTestMiddleware(t, testMiddlewareHandler)
+			caller := newTestWebsocketCaller(t, srv, path, wsCredentials{APIKey: "test-key"})
+
+			var wg sync.WaitGroup
+
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+
+					method := "test.echo"
+
+					if i%2 == 0 {
+						method = "test.slow"
+					}
+
+					var out []int
+					err := caller.Call(context.Background(), method, []interface{}{i}, &out)
+
+					assert.NoError(t, err)
+					assert.Equal(t, []int{i}, out)
+				}(i)
+			}
+
+			wg.Wait()
+
+			assert.Equal(t, int32(1), atomic.LoadInt32(&m.connections), "connection must be reused")
+		})
+	}
+}
+
+func TestWebsocketCaller_errors(t *testing.T) {
+	for _, path := range []string{"/api/current", "/websocket"} {
+		t.Run(path, func(t *testing.T) {
+			_, srv := newTestMiddleware(t, testMiddlewareHandler)
+			caller := newTestWebsocketCaller(t, srv, path, wsCredentials{Username: "root", Password: "secret"})
+
+			err := caller.Call(context.Background(), "pool.dataset.get_instance", []interface{}{"Tank/missing"}, nil)
+
+			assert.EqualError(t, err, "[ENOENT] Tank/missing does not exist")
+			assert.True(t, isNotFoundError(nil, err))
+
+			err = caller.Call(context.Background(), "pool.snapshottask.create", []interface{}{map[string]interface{}{}}, nil)
+
+			assert.Equal(t, diag.Diagnostics{
+				{
+					Severity:      diag.Error,
+					Summary:       "error creating periodic snapshot task: Invalid naming schema",
+					Detail:        "pool_snapshottask_create.naming_schema: Invalid naming schema",
+					AttributePath: cty.GetAttrPath("naming_schema"),
+				},
+			}, apiErrorDiags(err, "error creating periodic snapshot task", apiAttrMap{"naming_schema": "naming_schema"}))
+		})
+	}
+}
+
+func TestWebsocketCaller_authFailed(t *testing.T) {
+	m, srv := newTestMiddleware(t, testMiddlewareHandler)
+	caller := newTestWebsocketCaller(t, srv, "/api/current", wsCredentials{APIKey: "wrong-key"})
+
+	err := caller.Call(context.Background(), "test.echo", nil, nil)
+
+	assert.EqualError(t, err, "authentication failed, check credentials")
+
+	// failed connection must not be reused
+	err = caller.Call(context.Background(), "test.echo", nil, nil)
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&m.connections))
+}
+
+func TestWebsocketCaller_reconnect(t *testing.T) {
+	m, srv := newTestMiddleware(t, testMiddlewareHandler)
+	// login and one call per connection
+	m.dropAfter = 2
+
+	caller := newTestWebsocketCaller(t, srv, "/api/current", wsCredentials{APIKey: "test-key"})
+
+	assert.NoError(t, caller.Call(context.Background(), "test.echo", nil, nil))
+
+	err := caller.Call(context.Background(), "test.echo", nil, nil)
+	assert.Error(t, err, "call in flight fails when connection is closed")
+
+	assert.NoError(t, caller.Call(context.Background(), "test.echo", nil, nil))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&m.connections))
+}
+
+func TestWebsocketCaller_contextCancelled(t *testing.T) {
+	_, srv := newTestMiddleware(t, func(method string, params []interface{}) (interface{}, map[string]interface{}) {
+		time.Sleep(200 * time.Millisecond)
+		return true, nil
+	})
+
+	caller := newTestWebsocketCaller(t, srv, "/api/current", wsCredentials{APIKey: "test-key"})
+	assert.NoError(t, caller.Call(context.Background(), "test.echo", nil, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, caller.Call(ctx, "test.echo", nil, nil), context.DeadlineExceeded)
+}
+
+func TestClient_invokeJob_websocket(t *testing.T) {
+	_, srv := newTestMiddleware(t, testMiddlewareHandler)
+
+	c := newTestClient(t, http.NotFoundHandler())
+	c.caller = newTestWebsocketCaller(t, srv, "/websocket", wsCredentials{APIKey: "test-key"})
+
+	var out map[string]interface{}
+
+	assert.NoError(t, c.invokeJob(context.Background(), "pool.create", []interface{}{map[string]interface{}{"name": "Tank"}}, &out))
+	assert.Equal(t, map[string]interface{}{"name": "Tank"}, out)
+}
+
+func Test_websocketURL(t *testing.T) {
+	testcases := map[string]string{
+		"https://nas.local/api/v2.0":  "wss://nas.local/api/current",
+		"http://10.0.0.2/api/v2.0/":   "ws://10.0.0.2/api/current",
+		"https://nas.local:8443/nas/": "wss://nas.local:8443/nas/api/current",
+	}
+
+	for baseURL, expected := range testcases {
+		actual, err := websocketURL(baseURL)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	_, err := websocketURL("ftp://nas.local")
+	assert.Error(t, err)
+}
+
+func Test_decodeWSError(t *testing.T) {
+	e := decodeWSError(json.RawMessage(`{"code": -32601, "message": "Method not found"}`))
+
+	assert.Equal(t, "Method not found", e.Error())
+}